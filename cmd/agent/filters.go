@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/config"
+	"github.com/emresahna/heimdall/internal/enrichment"
+)
+
+func applyCaptureFilters(coll *collector.Collector, cfg config.AgentConfig) error {
+	if cfg.ExcludeSelf {
+		pid := uint64(os.Getpid())
+		if err := coll.AddFilter(collector.FilterPID, pid, collector.FilterDeny); err != nil {
+			return fmt.Errorf("exclude self: %w", err)
+		}
+	}
+	for _, port := range cfg.IncludePorts {
+		if err := coll.AddFilter(collector.FilterPort, uint64(port), collector.FilterAllow); err != nil {
+			return fmt.Errorf("include port %d: %w", port, err)
+		}
+	}
+	for _, port := range cfg.ExcludePorts {
+		if err := coll.AddFilter(collector.FilterPort, uint64(port), collector.FilterDeny); err != nil {
+			return fmt.Errorf("exclude port %d: %w", port, err)
+		}
+	}
+	return nil
+}
+
+// namespaceFilter keeps a deny entry for the cgroup of every container that
+// runs in an excluded namespace, following pods as they come and go.
+func namespaceFilter(coll *collector.Collector, namespaces []string) enrichment.PodListener {
+	excluded := make(map[string]struct{}, len(namespaces))
+	for _, ns := range namespaces {
+		excluded[ns] = struct{}{}
+	}
+
+	var mu sync.Mutex
+	filtered := make(map[string]uint64)
+
+	return func(metas []enrichment.PodMeta, deleted bool) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, meta := range metas {
+			if _, ok := excluded[meta.Namespace]; !ok {
+				continue
+			}
+
+			cgroupID, seen := filtered[meta.ContainerID]
+			if deleted {
+				if seen {
+					if err := coll.RemoveFilter(collector.FilterCgroup, cgroupID); err != nil {
+						log.Printf("remove namespace filter: %v", err)
+					}
+					delete(filtered, meta.ContainerID)
+				}
+				continue
+			}
+			if seen {
+				continue
+			}
+
+			cgroupID, ok := enrichment.CgroupIDForContainer(meta.ContainerID)
+			if !ok {
+				continue
+			}
+			if err := coll.AddFilter(collector.FilterCgroup, cgroupID, collector.FilterDeny); err != nil {
+				log.Printf("add namespace filter: %v", err)
+				continue
+			}
+			filtered[meta.ContainerID] = cgroupID
+		}
+	}
+}
@@ -11,7 +11,10 @@ import (
 	"github.com/emresahna/heimdall/internal/config"
 	"github.com/emresahna/heimdall/internal/correlation"
 	"github.com/emresahna/heimdall/internal/enrichment"
+	"github.com/emresahna/heimdall/internal/payload"
 	"github.com/emresahna/heimdall/internal/pipeline"
+	"github.com/emresahna/heimdall/internal/respparse"
+	"github.com/emresahna/heimdall/internal/route"
 	pb "github.com/emresahna/heimdall/internal/sender"
 	"github.com/emresahna/heimdall/internal/transport"
 	"google.golang.org/grpc"
@@ -34,15 +37,24 @@ func main() {
 	}
 	defer conn.Close()
 
-	coll, err := collector.New()
+	source, coll, err := openSource(cfg.Agent)
 	if err != nil {
 		log.Fatalf("collector error: %v", err)
 	}
-	defer coll.Close()
+	defer source.Close()
+
+	if coll != nil {
+		if err := applyCaptureFilters(coll, cfg.Agent); err != nil {
+			log.Fatalf("capture filter error: %v", err)
+		}
+	}
 
 	client := pb.NewLogServiceClient(conn)
 	sender := transport.NewGRPCSender(client)
 	diagnostics := pipeline.NewDiagnostics()
+	if coll != nil {
+		diagnostics.TrackCollector(coll.Stats)
+	}
 	batcher := pipeline.NewBatcher(
 		cfg.Agent.BatchSize,
 		cfg.Agent.FlushInterval,
@@ -55,11 +67,33 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	enricher, err := enrichment.NewEnricher(ctx, cfg.Agent.K8sEnrich, cfg.Agent.NodeName)
+	var podListeners []enrichment.PodListener
+	if len(cfg.Agent.ExcludeNamespaces) > 0 {
+		switch {
+		case !cfg.Agent.K8sEnrich:
+			log.Fatal("AGENT_EXCLUDE_NAMESPACES requires AGENT_K8S_ENRICH")
+		case coll == nil:
+			log.Printf("AGENT_EXCLUDE_NAMESPACES only applies to live capture, ignoring")
+		default:
+			podListeners = append(podListeners, namespaceFilter(coll, cfg.Agent.ExcludeNamespaces))
+		}
+	}
+
+	enricher, err := enrichment.NewEnricher(
+		ctx,
+		cfg.Agent.K8sEnrich,
+		cfg.Agent.NodeName,
+		podListeners...,
+	)
 	if err != nil {
 		log.Fatalf("enricher error: %v", err)
 	}
 
+	routeRules, err := route.ParseRules(cfg.Agent.RouteTemplates)
+	if err != nil {
+		log.Fatalf("AGENT_ROUTE_TEMPLATES error: %v", err)
+	}
+
 	processor := pipeline.NewProcessor(
 		ctx,
 		correlator,
@@ -68,18 +102,51 @@ func main() {
 		cfg.Agent.NodeName,
 		cfg.Agent.HTTPSampleBytes,
 		diagnostics,
+		pipeline.Options{
+			RedisKeys:     respparse.KeyMode(cfg.Agent.RedisKeys),
+			MemcachedKeys: respparse.KeyMode(cfg.Agent.MemcachedKeys),
+			Routes:        route.NewNormalizer(routeRules),
+			HTTPHeaders:   cfg.Agent.HTTPHeaders,
+			Payloads: payload.NewSampler(payload.Config{
+				Namespaces:   cfg.Agent.PayloadNamespaces,
+				Routes:       cfg.Agent.PayloadRoutes,
+				ContentTypes: cfg.Agent.PayloadContentTypes,
+				MaxBytes:     cfg.Agent.PayloadMaxBytes,
+				RedactKeys:   cfg.Agent.PayloadRedactKeys,
+			}),
+			Redactor:    payload.NewRedactor(cfg.Agent.PayloadRedactKeys),
+			EmitAborted: cfg.Agent.EmitAborted,
+			EventClock:  cfg.Agent.Mode == modeReplay || cfg.Agent.Mode == modePcap,
+		},
 	)
 
+	handler := processor.HandleEvent
+	if cfg.Agent.Mode == modeRecord {
+		recorder, err := collector.NewRecorder(cfg.Agent.RecordFile)
+		if err != nil {
+			log.Fatalf("recorder error: %v", err)
+		}
+		defer func() {
+			if err := recorder.Close(); err != nil {
+				log.Printf("close recording: %v", err)
+			}
+		}()
+		log.Printf("recording events to %s", cfg.Agent.RecordFile)
+		handler = recordingHandler(recorder, processor.HandleEvent)
+	}
+
 	go batcher.Run(ctx)
 	go processor.RunMaintenance(ctx, cfg.Agent.CorrelatorTTL)
 	go pipeline.StartDiagnosticsReporter(ctx, diagnostics, cfg.Agent.DiagnosticsInterval)
 	go func() {
-		if err := coll.Run(ctx, processor.HandleEvent); err != nil {
+		if err := source.Run(ctx, handler); err != nil {
 			log.Printf("collector stopped: %v", err)
+		} else if coll == nil {
+			log.Printf("%s input finished", cfg.Agent.Mode)
 		}
 	}()
 
 	<-ctx.Done()
-	coll.Close()
+	source.Close()
 	log.Println("agent shutting down")
 }
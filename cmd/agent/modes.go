@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/config"
+)
+
+const (
+	modeLive   = "live"
+	modeRecord = "record"
+	modeReplay = "replay"
+	modePcap   = "pcap"
+)
+
+// openSource returns the event source for the configured mode. The live
+// collector is also returned so callers can manage filters and stats; it is
+// nil when reading from a file.
+func openSource(cfg config.AgentConfig) (collector.Source, *collector.Collector, error) {
+	switch cfg.Mode {
+	case modeLive, modeRecord:
+		coll, err := collector.New(cfg.HTTPSampleBytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return coll, coll, nil
+	case modeReplay:
+		replay, err := collector.NewReplay(cfg.RecordFile, cfg.ReplaySpeed)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("replaying events from %s at speed %g", cfg.RecordFile, cfg.ReplaySpeed)
+		return replay, nil, nil
+	case modePcap:
+		capture, err := collector.NewPcapReplay(cfg.PcapFile, cfg.ReplaySpeed, cfg.CorrelatorTTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("reading packet capture %s at speed %g", cfg.PcapFile, cfg.ReplaySpeed)
+		return capture, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown AGENT_MODE %q", cfg.Mode)
+	}
+}
+
+// recordingHandler writes every event to recorder before passing it on.
+func recordingHandler(recorder *collector.Recorder, next func(collector.Event)) func(collector.Event) {
+	var once sync.Once
+	return func(ev collector.Event) {
+		if err := recorder.Record(ev); err != nil {
+			once.Do(func() {
+				log.Printf("record event error: %v", err)
+			})
+		}
+		next(ev)
+	}
+}
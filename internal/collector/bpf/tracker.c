@@ -3,6 +3,8 @@
 #include "vmlinux.h"
 #include <bpf/bpf_helpers.h>
 #include <bpf/bpf_tracing.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_endian.h>
 
 typedef unsigned int u32;
 typedef unsigned long long u64;
@@ -10,27 +12,245 @@ typedef int s32;
 
 char __license[] SEC("license") = "Dual MIT/GPL";
 
-#define MAX_DATA 128
+// Upper bound for captured payload bytes. The effective size is capture_bytes,
+// set by the agent at load time; records carry only the bytes captured.
+#define MAX_DATA 4096
 #define EVENT_REQUEST 1
 #define EVENT_RESPONSE 2
+// A traced socket was closed or shut down for reading; carries no data.
+#define EVENT_CLOSE 3
 
+// Bytes inspected to recognise a protocol at the start of a buffer.
+#define PREFIX_LEN 16
+
+#define PROTO_HTTP 1
+#define PROTO_HTTP2 2
+#define PROTO_REDIS 3
+#define PROTO_POSTGRES 4
+#define PROTO_MYSQL 5
+#define PROTO_KAFKA 6
+#define PROTO_DNS 7
+#define PROTO_MONGO 8
+#define PROTO_MEMCACHED 9
+
+#define MONGO_OP_QUERY 2004
+#define MONGO_OP_MSG 2013
+
+#define DNS_PORT 53
+// Messages of a sendmmsg call that are inspected.
+#define MAX_MMSG 4
+
+#define SHUT_WR 1
+
+#define FLAG_TLS 1
+#define FLAG_INGRESS 2
+
+#define HOOK_WRITE 1
+#define HOOK_SENDTO 2
+#define HOOK_WRITEV 3
+#define HOOK_SENDMSG 4
+#define HOOK_SENDMMSG 5
+#define HOOK_READ 6
+#define HOOK_RECVFROM 7
+#define HOOK_READV 8
+#define HOOK_RECVMSG 9
+#define HOOK_RECVMMSG 10
+#define HOOK_SSL 11
+#define HOOK_GO_TLS 12
+#define HOOK_CLOSE 14
+#define HOOK_SHUTDOWN 15
+
+#define FILTER_ALLOW 1
+#define FILTER_DENY 2
+
+#define FILTER_KIND_CGROUP 0
+#define FILTER_KIND_PID 1
+#define FILTER_KIND_PORT 2
+#define FILTER_KINDS 3
+
+#define STAT_RESERVE_FAILED 0
+#define STAT_READ_FAILED 1
+#define STAT_TRUNCATED 2
+#define STAT_FILTERED 3
+#define STAT_MAX 4
+
+// One slot per program in probe_hits; the order is mirrored by probeNames in
+// stats.go.
+enum probe_id {
+	PROBE_WRITE_ENTRY,
+	PROBE_SENDTO_ENTRY,
+	PROBE_WRITEV_ENTRY,
+	PROBE_SENDMSG_ENTRY,
+	PROBE_SENDMMSG_ENTRY,
+	PROBE_READ_ENTRY,
+	PROBE_READ_EXIT,
+	PROBE_RECV_ENTRY,
+	PROBE_RECV_EXIT,
+	PROBE_READV_ENTRY,
+	PROBE_READV_EXIT,
+	PROBE_RECVMSG_ENTRY,
+	PROBE_RECVMSG_EXIT,
+	PROBE_RECVMMSG_ENTRY,
+	PROBE_RECVMMSG_EXIT,
+	PROBE_SSL_WRITE_ENTRY,
+	PROBE_SSL_WRITE_EX_ENTRY,
+	PROBE_SSL_WRITE_EXIT,
+	PROBE_SSL_READ_ENTRY,
+	PROBE_SSL_READ_EX_ENTRY,
+	PROBE_SSL_READ_EXIT,
+	PROBE_GO_TLS_WRITE_ENTRY,
+	PROBE_GO_TLS_READ_ENTRY,
+	PROBE_GO_TLS_READ_EXIT,
+	PROBE_CLOSE_ENTRY,
+	PROBE_SHUTDOWN_ENTRY,
+	PROBE_MAX,
+};
+
+#define AF_INET 2
+#define AF_INET6 10
+#define S_IFMT 00170000
+#define S_IFSOCK 0140000
+
+// Uprobe contexts point at the user register file. vmlinux.h describes an
+// arm64 kernel, so the x86-64 layout (the uapi struct pt_regs) is declared
+// here. C arguments follow the platform ABI; Go (go1.17+) passes integer
+// arguments and results in registers and keeps the current goroutine in a
+// dedicated register.
+#if defined(__TARGET_ARCH_x86)
+struct x86_regs_t {
+	u64 r15, r14, r13, r12, bp, bx, r11, r10, r9, r8;
+	u64 ax, cx, dx, si, di, orig_ax, ip, cs, flags, sp, ss;
+};
+#define REGS(x) ((const struct x86_regs_t *)(x))
+#define C_PARAM1(x) (REGS(x)->di)
+#define C_PARAM2(x) (REGS(x)->si)
+#define C_PARAM4(x) (REGS(x)->cx)
+#define C_RET(x) (REGS(x)->ax)
+#define GO_PARAM1(x) (REGS(x)->ax)
+#define GO_PARAM2(x) (REGS(x)->bx)
+#define GO_PARAM3(x) (REGS(x)->cx)
+#define GO_G(x) (REGS(x)->r14)
+#elif defined(__TARGET_ARCH_arm64)
+#define REGS(x) ((const struct user_pt_regs *)(x))
+#define C_PARAM1(x) (REGS(x)->regs[0])
+#define C_PARAM2(x) (REGS(x)->regs[1])
+#define C_PARAM4(x) (REGS(x)->regs[3])
+#define C_RET(x) (REGS(x)->regs[0])
+#define GO_PARAM1(x) (REGS(x)->regs[0])
+#define GO_PARAM2(x) (REGS(x)->regs[1])
+#define GO_PARAM3(x) (REGS(x)->regs[2])
+#define GO_G(x) (REGS(x)->regs[28])
+#else
+#error "unsupported target architecture"
+#endif
+
+// crypto/tls.Conn starts with its net.Conn interface; the data word points at
+// a *net.TCPConn whose first field is the *netFD. poll.FD.Sysfd follows the
+// 16-byte fdMutex at the start of netFD.
+#define GO_TLS_CONN_DATA_OFFSET 8
+#define GO_NETFD_SYSFD_OFFSET 16
+
+// sock_ino is the inode of the socket behind fd, which tells a new socket
+// apart from an earlier one that had the same fd number.
 struct event_t {
 	u64 ts_ns;
 	u64 cgroup_id;
+	u64 sock_ino;
 	u32 pid;
 	u32 tid;
 	s32 fd;
 	u32 data_len;
+	// buf_len is the size of the buffer the data was copied from, which
+	// exceeds data_len when the capture was truncated.
+	u32 buf_len;
 	u8 event_type;
-	u8 _pad[3];
+	u8 flags;
+	u8 hook;
+	u8 protocol;
+	u16 family;
+	u16 lport;
+	u16 rport;
+	u8 _pad2[2];
+	u8 laddr[16];
+	u8 raddr[16];
 	char data[MAX_DATA];
-} __attribute__((preserve_access_index));
+};
+
+struct tuple_t {
+	u64 ino;
+	u16 family;
+	u16 lport;
+	u16 rport;
+	u8 laddr[16];
+	u8 raddr[16];
+};
 
+// For readv/recvmsg/recvmmsg buf points at the iovec, msghdr or mmsghdr
+// array rather than at the data; hook tells the exit handler which.
+// addr is the source address buffer of recvfrom, filled in by the kernel.
 struct read_args_t {
+	u64 buf;
+	u64 addr;
+	s32 fd;
+	u8 hook;
+};
+
+struct ssl_args_t {
+	u64 ssl;
+	u64 buf;
+	u64 out_len;
+	s32 fd;
+};
+
+struct conn_key_t {
+	u32 pid;
+	s32 fd;
+};
+
+// Protocol of a connection whose messages cannot be recognised one by one.
+// request_ingress records whether requests were read (server side) or
+// written (client side) when the protocol was detected.
+struct conn_info_t {
+	u8 protocol;
+	u8 request_ingress;
+};
+
+struct go_tls_key_t {
+	u32 pid;
+	u32 _pad;
+	u64 goid;
+};
+
+struct go_tls_args_t {
 	u64 buf;
 	s32 fd;
 };
 
+const volatile u32 capture_bytes = 1024;
+
+// Events are assembled here and copied into the ring buffer with only the
+// captured part of data, keeping records small when payloads are short.
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, u32);
+	__type(value, struct event_t);
+} event_scratch SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, STAT_MAX);
+	__type(key, u32);
+	__type(value, u64);
+} stats SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, PROBE_MAX);
+	__type(key, u32);
+	__type(value, u64);
+} probe_hits SEC(".maps");
+
 struct {
 	__uint(type, BPF_MAP_TYPE_RINGBUF);
 	__uint(max_entries, 1 << 24);
@@ -43,6 +263,95 @@ struct {
 	__type(value, struct read_args_t);
 } pending_reads SEC(".maps");
 
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, 65535);
+	__type(key, u32);
+	__type(value, struct ssl_args_t);
+} ssl_calls SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 65535);
+	__type(key, u64);
+	__type(value, s32);
+} ssl_fds SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, 65535);
+	__type(key, struct go_tls_key_t);
+	__type(value, struct go_tls_args_t);
+} go_tls_reads SEC(".maps");
+
+// Capture filters are maintained by the collector. A deny entry always drops
+// the event; once a kind has any allow entry (counted per kind in
+// filter_allow_counts) only matching events of that kind are captured.
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, FILTER_KINDS);
+	__type(key, u32);
+	__type(value, u32);
+} filter_allow_counts SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, 4096);
+	__type(key, u64);
+	__type(value, u8);
+} cgroup_filters SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 65535);
+	__type(key, struct conn_key_t);
+	__type(value, struct conn_info_t);
+} conn_protos SEC(".maps");
+
+// Connections of unknown protocol whose last chunk was a lone 4-byte length,
+// keyed like conn_protos; the value is the direction of that chunk. Java
+// Kafka clients and brokers read and write the size of a message separately
+// from its header.
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 16384);
+	__type(key, struct conn_key_t);
+	__type(value, u8);
+} length_prefixes SEC(".maps");
+
+// Sockets that produced an event, by pid and fd, with their inode. Closing
+// one of them emits EVENT_CLOSE so the agent can settle its pending
+// requests; other closes are ignored.
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 65535);
+	__type(key, struct conn_key_t);
+	__type(value, u64);
+} traced_socks SEC(".maps");
+
+// Sockets a Go program reads and writes through crypto/tls, by pid and fd.
+// The syscalls on them carry ciphertext.
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, 16384);
+	__type(key, struct conn_key_t);
+	__type(value, u8);
+} go_tls_socks SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, 4096);
+	__type(key, u32);
+	__type(value, u8);
+} pid_filters SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, 1024);
+	__type(key, u16);
+	__type(value, u8);
+} port_filters SEC(".maps");
+
 static __always_inline int is_http_request(const char *buf) {
 	if (buf[0] == 'G' && buf[1] == 'E' && buf[2] == 'T' && buf[3] == ' ') return 1;
 	if (buf[0] == 'P' && buf[1] == 'O' && buf[2] == 'S' && buf[3] == 'T') return 1;
@@ -59,14 +368,406 @@ static __always_inline int is_http_response(const char *buf) {
 	return 0;
 }
 
-static __always_inline int emit_event(const char *buf, size_t count, s32 fd, u8 event_type) {
+// Both roles go through every path: a client writes requests and reads
+// responses, a server reads requests and writes responses.
+static __always_inline u8 classify_http(const char *buf) {
+	if (is_http_request(buf)) return EVENT_REQUEST;
+	if (is_http_response(buf)) return EVENT_RESPONSE;
+	return 0;
+}
+
+// The client connection preface, "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n".
+static __always_inline int is_http2_preface(const char *buf) {
+	return buf[0] == 'P' && buf[1] == 'R' && buf[2] == 'I' && buf[3] == ' ' &&
+	       buf[4] == '*' && buf[5] == ' ' && buf[6] == 'H' && buf[7] == 'T' &&
+	       buf[8] == 'T' && buf[9] == 'P' && buf[10] == '/' && buf[11] == '2';
+}
+
+static __always_inline int is_digit(char c) {
+	return c >= '0' && c <= '9';
+}
+
+// is_resp_command matches the start of a RESP array of bulk strings, the only
+// form clients use for commands: "*<n>\r\n$".
+static __always_inline int is_resp_command(const char *buf) {
+	if (buf[0] != '*' || !is_digit(buf[1])) return 0;
+	if (buf[2] == '\r' && buf[3] == '\n' && buf[4] == '$') return 1;
+	return is_digit(buf[2]) && buf[3] == '\r' && buf[4] == '\n' && buf[5] == '$';
+}
+
+static __always_inline u32 read_be32(const char *buf) {
+	return (u32)(u8)buf[0] << 24 | (u32)(u8)buf[1] << 16 | (u32)(u8)buf[2] << 8 | (u32)(u8)buf[3];
+}
+
+static __always_inline u32 read_le32(const char *buf) {
+	return (u32)(u8)buf[3] << 24 | (u32)(u8)buf[2] << 16 | (u32)(u8)buf[1] << 8 | (u32)(u8)buf[0];
+}
+
+// is_mongo_request matches the header of an OP_MSG, or of the legacy
+// OP_QUERY some drivers still use for the first hello, that is not a reply.
+static __always_inline int is_mongo_request(const char *buf) {
+	u32 len = read_le32(buf);
+	u32 op = read_le32(buf + 12);
+
+	if (len < 21 || len > 48000000 || read_le32(buf + 8) != 0) return 0;
+	return op == MONGO_OP_MSG || op == MONGO_OP_QUERY;
+}
+
+// is_postgres_frontend matches a protocol 3.0 StartupMessage, or a Query,
+// Parse or Bind message for connections opened before the agent started.
+// Requiring a sane length also rules out MySQL packets that share the first
+// byte.
+static __always_inline int is_postgres_frontend(const char *buf) {
+	u32 len;
+
+	if (buf[0] == 0 && buf[1] == 0 && buf[4] == 0 && buf[5] == 3 && buf[6] == 0 && buf[7] == 0) {
+		len = read_be32(buf);
+		return len >= 8 && len <= 10000;
+	}
+	if (buf[0] != 'Q' && buf[0] != 'P' && buf[0] != 'B') return 0;
+	len = read_be32(buf + 1);
+	if (len < 8 || len > (1 << 24)) return 0;
+	if (buf[0] == 'Q') {
+		char c = buf[5] | 0x20;
+		return (c >= 'a' && c <= 'z') || buf[5] == '(' || buf[5] == ' ';
+	}
+	return 1;
+}
+
+// is_mysql_command matches a COM_QUERY, COM_STMT_PREPARE or COM_STMT_EXECUTE
+// packet: a 3-byte length, sequence 0 and the command byte. The server
+// speaks first on new connections, so its greeting is skipped and the
+// connection is recognised by the first command.
+static __always_inline int is_mysql_command(const char *buf, size_t count) {
+	u32 len = (u32)(u8)buf[0] | (u32)(u8)buf[1] << 8 | (u32)(u8)buf[2] << 16;
+	char c;
+
+	if (buf[3] != 0 || len < 2 || len + 4 > count) return 0;
+	if (buf[4] == 0x03 || buf[4] == 0x16) {
+		c = buf[5] | 0x20;
+		return (c >= 'a' && c <= 'z') || buf[5] == '(' || buf[5] == '/' || buf[5] == ' ';
+	}
+	if (buf[4] == 0x17) {
+		return len >= 10 && buf[10] == 1 && buf[11] == 0 && buf[12] == 0 && buf[13] == 0;
+	}
+	return 0;
+}
+
+// is_memcached_text matches the storage, retrieval and delete commands of
+// the text protocol. The prefix buffer is zero-filled past count.
+static __always_inline int is_memcached_text(const char *buf) {
+	if (buf[0] == 'g' && (buf[1] == 'e' || buf[1] == 'a') && buf[2] == 't') {
+		return buf[3] == ' ' || (buf[3] == 's' && buf[4] == ' ');
+	}
+	if (buf[0] == 's' && buf[1] == 'e' && buf[2] == 't' && buf[3] == ' ') return 1;
+	if (buf[0] == 'a' && buf[1] == 'd' && buf[2] == 'd' && buf[3] == ' ') return 1;
+	if (buf[0] == 'c' && buf[1] == 'a' && buf[2] == 's' && buf[3] == ' ') return 1;
+	if (buf[0] == 'd' && buf[1] == 'e' && buf[2] == 'l' && buf[3] == 'e' && buf[4] == 't' && buf[5] == 'e' && buf[6] == ' ') return 1;
+	if (buf[0] == 'i' && buf[1] == 'n' && buf[2] == 'c' && buf[3] == 'r' && buf[4] == ' ') return 1;
+	if (buf[0] == 'd' && buf[1] == 'e' && buf[2] == 'c' && buf[3] == 'r' && buf[4] == ' ') return 1;
+	if (buf[0] == 't' && buf[1] == 'o' && buf[2] == 'u' && buf[3] == 'c' && buf[4] == 'h' && buf[5] == ' ') return 1;
+	if (buf[0] == 'r' && buf[1] == 'e' && buf[2] == 'p' && buf[3] == 'l' && buf[4] == 'a' && buf[5] == 'c' && buf[6] == 'e' && buf[7] == ' ') return 1;
+	if (buf[0] == 'a' && buf[1] == 'p' && buf[2] == 'p' && buf[3] == 'e' && buf[4] == 'n' && buf[5] == 'd' && buf[6] == ' ') return 1;
+	if (buf[0] == 'p' && buf[1] == 'r' && buf[2] == 'e' && buf[3] == 'p' && buf[4] == 'e' && buf[5] == 'n' && buf[6] == 'd' && buf[7] == ' ') return 1;
+	return 0;
+}
+
+// is_memcached_binary matches a binary protocol request header: magic,
+// a known opcode, raw data type and a body that holds the key and extras.
+static __always_inline int is_memcached_binary(const char *buf) {
+	u32 key_len = (u32)(u8)buf[2] << 8 | (u32)(u8)buf[3];
+
+	if ((u8)buf[0] != 0x80 || (u8)buf[1] > 0x1e || buf[5] != 0) return 0;
+	return read_be32(buf + 8) >= key_len + (u8)buf[4];
+}
+
+// is_kafka_header matches a request header: api_key, api_version, a
+// correlation_id and the length of the client_id string, which is -1 or
+// short.
+static __always_inline int is_kafka_header(const char *buf) {
+	if (buf[0] != 0 || (u8)buf[1] > 80 || buf[2] != 0 || (u8)buf[3] > 20) return 0;
+	if ((u8)buf[8] == 0xff) return (u8)buf[9] == 0xff;
+	return buf[8] == 0;
+}
+
+static __always_inline int is_kafka_length(const char *buf) {
+	u32 len = read_be32(buf);
+
+	return len >= 10 && len < (1 << 24);
+}
+
+// is_kafka_request matches a size-prefixed request written in one piece.
+static __always_inline int is_kafka_request(const char *buf, size_t count) {
+	return count >= 14 && is_kafka_length(buf) && is_kafka_header(buf + 4);
+}
+
+// is_dns_header matches a standard query, or its response, with a single
+// question.
+static __always_inline int is_dns_header(const char *buf) {
+	return ((u8)buf[2] & 0x78) == 0 && buf[4] == 0 && buf[5] == 1;
+}
+
+// detect_protocol recognises the first request of a protocol that is then
+// tracked per connection.
+static __always_inline u8 detect_protocol(const char *buf, size_t count) {
+	if (count >= 24 && is_http2_preface(buf)) return PROTO_HTTP2;
+	if (count >= 16 && is_mongo_request(buf)) return PROTO_MONGO;
+	if (count >= 6 && is_resp_command(buf)) return PROTO_REDIS;
+	if (count >= 8 && is_postgres_frontend(buf)) return PROTO_POSTGRES;
+	if (count >= 6 && is_mysql_command(buf, count)) return PROTO_MYSQL;
+	if (count >= 6 && is_memcached_text(buf)) return PROTO_MEMCACHED;
+	if (count >= 24 && is_memcached_binary(buf)) return PROTO_MEMCACHED;
+	if (is_kafka_request(buf, count)) return PROTO_KAFKA;
+	return 0;
+}
+
+static __always_inline int read_prefix(char *prefix, const char *buf, size_t count) {
+	u32 len = count > PREFIX_LEN ? PREFIX_LEN : (u32)count;
+
+	if (len < 4) {
+		return -1;
+	}
+	return bpf_probe_read_user(prefix, len, buf);
+}
+
+// classify returns the event type for a buffer and sets its protocol. HTTP/1
+// messages are recognised on their own; other protocols are detected on the
+// first request of a connection and remembered for the rest of it.
+static __always_inline u8 classify(s32 fd, const char *prefix, size_t count, u8 ingress, u8 *protocol) {
+	struct conn_key_t key = {};
+	struct conn_info_t info = {};
+	struct conn_info_t *known;
+	u8 *length_dir;
+	u8 after_length = 0;
+	u8 event_type;
+
+	key.pid = bpf_get_current_pid_tgid() >> 32;
+	key.fd = fd;
+
+	event_type = classify_http(prefix);
+	if (event_type) {
+		if (event_type == EVENT_REQUEST) {
+			bpf_map_delete_elem(&conn_protos, &key);
+		}
+		*protocol = PROTO_HTTP;
+		return event_type;
+	}
+
+	known = bpf_map_lookup_elem(&conn_protos, &key);
+	if (known) {
+		*protocol = known->protocol;
+		return ingress == known->request_ingress ? EVENT_REQUEST : EVENT_RESPONSE;
+	}
+
+	length_dir = bpf_map_lookup_elem(&length_prefixes, &key);
+	if (length_dir) {
+		after_length = *length_dir == ingress;
+		bpf_map_delete_elem(&length_prefixes, &key);
+	}
+
+	info.protocol = detect_protocol(prefix, count);
+	if (!info.protocol && after_length && count >= 10 && is_kafka_header(prefix)) {
+		info.protocol = PROTO_KAFKA;
+	}
+	if (!info.protocol) {
+		if (count == 4 && is_kafka_length(prefix)) {
+			bpf_map_update_elem(&length_prefixes, &key, &ingress, BPF_ANY);
+		}
+		return 0;
+	}
+	info.request_ingress = ingress;
+	bpf_map_update_elem(&conn_protos, &key, &info, BPF_ANY);
+	*protocol = info.protocol;
+	return EVENT_REQUEST;
+}
+
+static __always_inline void count_stat(u32 key) {
+	u64 *value = bpf_map_lookup_elem(&stats, &key);
+
+	if (value) {
+		*value += 1;
+	}
+}
+
+static __always_inline void count_probe(u32 probe) {
+	u64 *value = bpf_map_lookup_elem(&probe_hits, &probe);
+
+	if (value) {
+		*value += 1;
+	}
+}
+
+static __always_inline struct sock *fd_to_sock(s32 fd, u64 *ino) {
+	struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+	struct file **fds;
+	struct file *file = NULL;
+	struct socket *sock;
+	umode_t mode;
+
+	if (fd < 0) {
+		return NULL;
+	}
+
+	fds = BPF_CORE_READ(task, files, fdt, fd);
+	if (!fds) {
+		return NULL;
+	}
+	if (bpf_core_read(&file, sizeof(file), &fds[fd]) != 0 || !file) {
+		return NULL;
+	}
+
+	mode = BPF_CORE_READ(file, f_inode, i_mode);
+	if ((mode & S_IFMT) != S_IFSOCK) {
+		return NULL;
+	}
+
+	sock = (struct socket *)BPF_CORE_READ(file, private_data);
+	if (!sock) {
+		return NULL;
+	}
+	*ino = BPF_CORE_READ(file, f_inode, i_ino);
+	return BPF_CORE_READ(sock, sk);
+}
+
+static __always_inline void read_tuple(struct tuple_t *t, s32 fd) {
+	struct sock *sk = fd_to_sock(fd, &t->ino);
+	u16 family;
+
+	if (!sk) {
+		return;
+	}
+
+	family = BPF_CORE_READ(sk, __sk_common.skc_family);
+	if (family == AF_INET) {
+		bpf_core_read(t->laddr, 4, &sk->__sk_common.skc_rcv_saddr);
+		bpf_core_read(t->raddr, 4, &sk->__sk_common.skc_daddr);
+	} else if (family == AF_INET6) {
+		bpf_core_read(t->laddr, 16, &sk->__sk_common.skc_v6_rcv_saddr);
+		bpf_core_read(t->raddr, 16, &sk->__sk_common.skc_v6_daddr);
+	} else {
+		return;
+	}
+
+	t->family = family;
+	t->lport = BPF_CORE_READ(sk, __sk_common.skc_num);
+	t->rport = bpf_ntohs(BPF_CORE_READ(sk, __sk_common.skc_dport));
+}
+
+// read_peer fills in the remote end of an unconnected socket from the
+// address passed to sendto/sendmsg or returned by recvfrom/recvmsg.
+static __always_inline void read_peer(struct tuple_t *t, u64 addr) {
+	struct sockaddr_in6 sa = {};
+
+	if (!addr || t->rport != 0) {
+		return;
+	}
+	if (bpf_probe_read_user(&sa, sizeof(struct sockaddr_in), (const void *)addr) != 0) {
+		return;
+	}
+	if (sa.sin6_family == AF_INET) {
+		__builtin_memcpy(t->raddr, &((struct sockaddr_in *)&sa)->sin_addr, 4);
+	} else if (sa.sin6_family == AF_INET6) {
+		if (bpf_probe_read_user(&sa, sizeof(sa), (const void *)addr) != 0) {
+			return;
+		}
+		__builtin_memcpy(t->raddr, &sa.sin6_addr, 16);
+	} else {
+		return;
+	}
+	t->family = sa.sin6_family;
+	t->rport = bpf_ntohs(sa.sin6_port);
+}
+
+// classify_dns recognises DNS over UDP: a DNS header on a socket bound or
+// connected to port 53, or sent to or received from it. Every datagram is a
+// whole message, so no connection state is kept.
+static __always_inline u8 classify_dns(s32 fd, const char *prefix, size_t count, u64 addr) {
+	struct tuple_t t = {};
+
+	if (count < 17 || !is_dns_header(prefix)) {
+		return 0;
+	}
+	read_tuple(&t, fd);
+	read_peer(&t, addr);
+	if (t.lport != DNS_PORT && t.rport != DNS_PORT) {
+		return 0;
+	}
+	return (u8)prefix[2] & 0x80 ? EVENT_RESPONSE : EVENT_REQUEST;
+}
+
+static __always_inline int allow_list_active(u32 kind) {
+	u32 *count = bpf_map_lookup_elem(&filter_allow_counts, &kind);
+	return count && *count > 0;
+}
+
+static __always_inline int filter_passes(u8 *action, u32 kind) {
+	if (action) {
+		return *action == FILTER_ALLOW;
+	}
+	return !allow_list_active(kind);
+}
+
+static __always_inline int ports_pass(u16 lport, u16 rport) {
+	u8 *local = bpf_map_lookup_elem(&port_filters, &lport);
+	u8 *remote = bpf_map_lookup_elem(&port_filters, &rport);
+
+	if ((local && *local == FILTER_DENY) || (remote && *remote == FILTER_DENY)) {
+		return 0;
+	}
+	if ((local && *local == FILTER_ALLOW) || (remote && *remote == FILTER_ALLOW)) {
+		return 1;
+	}
+	return !allow_list_active(FILTER_KIND_PORT);
+}
+
+static __always_inline int should_capture(u32 pid, u64 cgroup_id, const struct tuple_t *t) {
+	if (!filter_passes(bpf_map_lookup_elem(&pid_filters, &pid), FILTER_KIND_PID)) {
+		return 0;
+	}
+	if (!filter_passes(bpf_map_lookup_elem(&cgroup_filters, &cgroup_id), FILTER_KIND_CGROUP)) {
+		return 0;
+	}
+	return ports_pass(t->lport, t->rport);
+}
+
+static __always_inline void track_sock(u32 pid, s32 fd, u64 ino) {
+	struct conn_key_t key = {};
+	u64 *known;
+
+	key.pid = pid;
+	key.fd = fd;
+	known = bpf_map_lookup_elem(&traced_socks, &key);
+	if (!known || *known != ino) {
+		bpf_map_update_elem(&traced_socks, &key, &ino, BPF_ANY);
+	}
+}
+
+static __always_inline int emit_event(const char *buf, size_t count, s32 fd, u64 addr, u8 event_type, u8 protocol, u8 flags, u8 hook) {
 	u64 id = bpf_get_current_pid_tgid();
 	u32 pid = id >> 32;
 	u32 tid = (u32)id;
+	u64 cgroup_id = bpf_get_current_cgroup_id();
+	struct tuple_t tuple = {};
 	u32 len;
 
-	if (count > MAX_DATA - 1) {
-		len = MAX_DATA - 1;
+	read_tuple(&tuple, fd);
+	read_peer(&tuple, addr);
+	if (!should_capture(pid, cgroup_id, &tuple)) {
+		count_stat(STAT_FILTERED);
+		return 0;
+	}
+
+	len = capture_bytes;
+	if (len > MAX_DATA) {
+		len = MAX_DATA;
+	}
+	if (count > len) {
+		count_stat(STAT_TRUNCATED);
+		// Postgres responses end with CommandComplete or ErrorResponse and
+		// ReadyForQuery; keep the end of large result sets instead of rows.
+		if (protocol == PROTO_POSTGRES && event_type == EVENT_RESPONSE) {
+			buf += count - len;
+		}
 	} else {
 		len = (u32)count;
 	}
@@ -75,194 +776,600 @@ static __always_inline int emit_event(const char *buf, size_t count, s32 fd, u8
 		return 0;
 	}
 
-	struct event_t *e = bpf_ringbuf_reserve(&events, sizeof(*e), 0);
+	u32 zero = 0;
+	struct event_t *e = bpf_map_lookup_elem(&event_scratch, &zero);
 	if (!e) {
 		return 0;
 	}
 
-	e->ts_ns = bpf_ktime_get_ns();
-	e->cgroup_id = bpf_get_current_cgroup_id();
+	e->ts_ns = bpf_ktime_get_boot_ns();
+	e->cgroup_id = cgroup_id;
+	e->sock_ino = tuple.ino;
 	e->pid = pid;
 	e->tid = tid;
 	e->fd = fd;
 	e->data_len = len;
+	e->buf_len = count > 0xffffffff ? 0xffffffff : (u32)count;
 	e->event_type = event_type;
+	e->flags = flags;
+	e->hook = hook;
+	e->protocol = protocol;
+	e->family = tuple.family;
+	e->lport = tuple.lport;
+	e->rport = tuple.rport;
+	__builtin_memcpy(e->laddr, tuple.laddr, sizeof(e->laddr));
+	__builtin_memcpy(e->raddr, tuple.raddr, sizeof(e->raddr));
 
 	if (bpf_probe_read_user(e->data, len, buf) != 0) {
-		bpf_ringbuf_discard(e, 0);
+		count_stat(STAT_READ_FAILED);
 		return 0;
 	}
-	e->data[len] = 0;
 
-	bpf_ringbuf_submit(e, 0);
+	if (bpf_ringbuf_output(&events, e, offsetof(struct event_t, data) + len, 0) != 0) {
+		count_stat(STAT_RESERVE_FAILED);
+		return 0;
+	}
+	if (tuple.ino) {
+		track_sock(pid, fd, tuple.ino);
+	}
 	return 0;
 }
 
-SEC("tracepoint/syscalls/sys_enter_write")
-int trace_write_entry(struct trace_event_raw_sys_enter *ctx) {
-	s32 fd = (s32)ctx->args[0];
-	const char *buf = (const char *)ctx->args[1];
-	size_t count = (size_t)ctx->args[2];
-	char prefix[8] = {};
+// emit_close reports that a traced socket went away and forgets what was
+// learned about its fd, which may be reused for another connection.
+static __always_inline int emit_close(s32 fd, u8 hook) {
+	u64 id = bpf_get_current_pid_tgid();
+	struct conn_key_t key = {};
+	struct event_t *e;
+	u32 zero = 0;
+	u64 *ino;
 
-	if (count < 4) {
+	key.pid = id >> 32;
+	key.fd = fd;
+	bpf_map_delete_elem(&go_tls_socks, &key);
+	ino = bpf_map_lookup_elem(&traced_socks, &key);
+	if (!ino) {
 		return 0;
 	}
-	if (bpf_probe_read_user(prefix, sizeof(prefix), buf) != 0) {
+
+	e = bpf_map_lookup_elem(&event_scratch, &zero);
+	if (!e) {
 		return 0;
 	}
-	if (!is_http_request(prefix)) {
-		return 0;
+	e->ts_ns = bpf_ktime_get_boot_ns();
+	e->cgroup_id = bpf_get_current_cgroup_id();
+	e->sock_ino = *ino;
+	e->pid = key.pid;
+	e->tid = (u32)id;
+	e->fd = fd;
+	e->data_len = 0;
+	e->buf_len = 0;
+	e->event_type = EVENT_CLOSE;
+	e->flags = 0;
+	e->hook = hook;
+	e->protocol = 0;
+	e->family = 0;
+	e->lport = 0;
+	e->rport = 0;
+	__builtin_memset(e->laddr, 0, sizeof(e->laddr));
+	__builtin_memset(e->raddr, 0, sizeof(e->raddr));
+
+	bpf_map_delete_elem(&traced_socks, &key);
+	bpf_map_delete_elem(&conn_protos, &key);
+	bpf_map_delete_elem(&length_prefixes, &key);
+
+	if (bpf_ringbuf_output(&events, e, offsetof(struct event_t, data), 0) != 0) {
+		count_stat(STAT_RESERVE_FAILED);
 	}
+	return 0;
+}
 
-	return emit_event(buf, count, fd, EVENT_REQUEST);
+// Syscalls issued while a thread is inside SSL_read/SSL_write carry the
+// ciphertext. Record their fd for the pending TLS call and skip them, along
+// with syscalls on Go TLS sockets.
+static __always_inline int track_ssl_fd(s32 fd) {
+	u64 id = bpf_get_current_pid_tgid();
+	u32 tid = (u32)id;
+	struct ssl_args_t *args = bpf_map_lookup_elem(&ssl_calls, &tid);
+	struct conn_key_t key = {};
+
+	key.pid = id >> 32;
+	key.fd = fd;
+	if (bpf_map_lookup_elem(&go_tls_socks, &key)) {
+		return 1;
+	}
+	if (!args) {
+		return 0;
+	}
+	if (args->fd < 0) {
+		args->fd = fd;
+	}
+	return 1;
 }
 
-SEC("tracepoint/syscalls/sys_enter_sendto")
-int trace_sendto_entry(struct trace_event_raw_sys_enter *ctx) {
-	s32 fd = (s32)ctx->args[0];
-	const char *buf = (const char *)ctx->args[1];
-	size_t count = (size_t)ctx->args[2];
-	char prefix[8] = {};
+static __always_inline int emit_egress(const char *buf, size_t count, s32 fd, u64 addr, u8 hook) {
+	char prefix[PREFIX_LEN] = {};
+	u8 event_type;
+	u8 protocol = 0;
 
-	if (count < 4) {
+	if (track_ssl_fd(fd)) {
 		return 0;
 	}
-	if (bpf_probe_read_user(prefix, sizeof(prefix), buf) != 0) {
+	if (read_prefix(prefix, buf, count) != 0) {
 		return 0;
 	}
-	if (!is_http_request(prefix)) {
+	event_type = classify_dns(fd, prefix, count, addr);
+	if (event_type) {
+		protocol = PROTO_DNS;
+	} else {
+		event_type = classify(fd, prefix, count, 0, &protocol);
+	}
+	if (!event_type) {
 		return 0;
 	}
 
-	return emit_event(buf, count, fd, EVENT_REQUEST);
+	return emit_event(buf, count, fd, addr, event_type, protocol, 0, hook);
 }
 
-SEC("tracepoint/syscalls/sys_enter_writev")
-int trace_writev_entry(struct trace_event_raw_sys_enter *ctx) {
-    s32 fd = (s32)ctx->args[0];
-    void *iov_ptr = (void *)ctx->args[1];
-    size_t vlen = (size_t)ctx->args[2];
-    
-    struct iovec iov;
-    char prefix[8] = {};
-
-    if (vlen == 0) {
-        return 0;
-    }
-
-    if (bpf_probe_read_user(&iov, sizeof(iov), iov_ptr) != 0) {
-        return 0;
-    }
-
-    if (iov.iov_len < 4) {
-        return 0;
-    }
-
-    if (bpf_probe_read_user(prefix, sizeof(prefix), iov.iov_base) != 0) {
-        return 0;
-    }
+// emit_iovecs emits the first iovec of a gathered write. A lone 4-byte first
+// iovec is a length prefix written next to its message, so the second one is
+// emitted after it.
+static __always_inline int emit_iovecs(const struct iovec *vec, size_t vlen, s32 fd, u64 addr, u8 hook) {
+	struct iovec iov;
 
-	int is_req = is_http_request(prefix);
-	int is_res = is_http_response(prefix);
-
-    if (is_req || is_res) {
-        return emit_event((const char *)iov.iov_base, iov.iov_len, fd, is_req ? EVENT_REQUEST : EVENT_RESPONSE);
-    }
-
-    return 0;
+	if (vlen == 0 || bpf_probe_read_user(&iov, sizeof(iov), vec) != 0) {
+		return 0;
+	}
+	emit_egress((const char *)iov.iov_base, iov.iov_len, fd, addr, hook);
+	if (iov.iov_len != 4 || vlen < 2 || bpf_probe_read_user(&iov, sizeof(iov), vec + 1) != 0) {
+		return 0;
+	}
+	return emit_egress((const char *)iov.iov_base, iov.iov_len, fd, addr, hook);
 }
 
-SEC("tracepoint/syscalls/sys_enter_read")
-int trace_read_entry(struct trace_event_raw_sys_enter *ctx) {
-	u64 id = bpf_get_current_pid_tgid();
-	u32 tid = (u32)id;
+static __always_inline int track_read(s32 fd, u64 buf, u64 addr, u8 hook) {
+	u32 tid = (u32)bpf_get_current_pid_tgid();
 	struct read_args_t args = {};
 
-	args.fd = (s32)ctx->args[0];
-	args.buf = (u64)ctx->args[1];
+	if (track_ssl_fd(fd)) {
+		return 0;
+	}
+
+	args.fd = fd;
+	args.buf = buf;
+	args.addr = addr;
+	args.hook = hook;
 	bpf_map_update_elem(&pending_reads, &tid, &args, BPF_ANY);
 	return 0;
 }
 
-SEC("tracepoint/syscalls/sys_exit_read")
-int trace_read_exit(struct trace_event_raw_sys_exit *ctx) {
-	u64 id = bpf_get_current_pid_tgid();
-	u32 tid = (u32)id;
-	struct read_args_t *args = bpf_map_lookup_elem(&pending_reads, &tid);
-	long ret = ctx->ret;
-	char prefix[8] = {};
+static __always_inline int handle_read_exit(long ret) {
+	u32 tid = (u32)bpf_get_current_pid_tgid();
+	struct read_args_t *pending = bpf_map_lookup_elem(&pending_reads, &tid);
+	struct read_args_t args;
+	struct user_msghdr hdr;
+	struct mmsghdr mmsg;
+	struct iovec iov;
+	const char *buf;
+	size_t count;
+	u64 addr;
+	char prefix[PREFIX_LEN] = {};
+	u8 event_type;
+	u8 protocol = 0;
 
-	if (!args) {
+	if (!pending) {
 		return 0;
 	}
+	args = *pending;
+	bpf_map_delete_elem(&pending_reads, &tid);
 
 	if (ret <= 0) {
-		bpf_map_delete_elem(&pending_reads, &tid);
 		return 0;
 	}
 
-	if (ret < 4) {
-		bpf_map_delete_elem(&pending_reads, &tid);
+	buf = (const char *)args.buf;
+	count = (size_t)ret;
+	addr = args.addr;
+
+	// Only the first iovec is inspected on the ingress side.
+	switch (args.hook) {
+	case HOOK_READV:
+		if (bpf_probe_read_user(&iov, sizeof(iov), (const void *)args.buf) != 0) {
+			return 0;
+		}
+		break;
+	case HOOK_RECVMSG:
+		if (bpf_probe_read_user(&hdr, sizeof(hdr), (const void *)args.buf) != 0 || hdr.msg_iovlen == 0) {
+			return 0;
+		}
+		if (bpf_probe_read_user(&iov, sizeof(iov), hdr.msg_iov) != 0) {
+			return 0;
+		}
+		addr = (u64)hdr.msg_name;
+		break;
+	case HOOK_RECVMMSG:
+		// recvmmsg returns the number of messages; the length of the first
+		// one is reported in its msg_len.
+		if (bpf_probe_read_user(&mmsg, sizeof(mmsg), (const void *)args.buf) != 0) {
+			return 0;
+		}
+		if (mmsg.msg_hdr.msg_iovlen == 0) {
+			return 0;
+		}
+		if (bpf_probe_read_user(&iov, sizeof(iov), mmsg.msg_hdr.msg_iov) != 0) {
+			return 0;
+		}
+		count = mmsg.msg_len;
+		addr = (u64)mmsg.msg_hdr.msg_name;
+		break;
+	default:
+		iov.iov_base = (void *)buf;
+		iov.iov_len = count;
+		break;
+	}
+
+	buf = (const char *)iov.iov_base;
+	if (count > iov.iov_len) {
+		count = iov.iov_len;
+	}
+	if (read_prefix(prefix, buf, count) != 0) {
 		return 0;
 	}
 
-	if (bpf_probe_read_user(prefix, sizeof(prefix), (const void *)args->buf) != 0) {
-		bpf_map_delete_elem(&pending_reads, &tid);
+	event_type = classify_dns(args.fd, prefix, count, addr);
+	if (event_type) {
+		protocol = PROTO_DNS;
+	} else {
+		event_type = classify(args.fd, prefix, count, 1, &protocol);
+	}
+	if (!event_type) {
 		return 0;
 	}
 
-	if (is_http_response(prefix)) {
-		emit_event((const char *)args->buf, (size_t)ret, args->fd, EVENT_RESPONSE);
+	return emit_event(buf, count, args.fd, addr, event_type, protocol, FLAG_INGRESS, args.hook);
+}
+
+SEC("tracepoint/syscalls/sys_enter_write")
+int trace_write_entry(struct trace_event_raw_sys_enter *ctx) {
+	count_probe(PROBE_WRITE_ENTRY);
+	return emit_egress((const char *)ctx->args[1], (size_t)ctx->args[2], (s32)ctx->args[0], 0, HOOK_WRITE);
+}
+
+SEC("tracepoint/syscalls/sys_enter_sendto")
+int trace_sendto_entry(struct trace_event_raw_sys_enter *ctx) {
+	count_probe(PROBE_SENDTO_ENTRY);
+	return emit_egress((const char *)ctx->args[1], (size_t)ctx->args[2], (s32)ctx->args[0], (u64)ctx->args[4], HOOK_SENDTO);
+}
+
+SEC("tracepoint/syscalls/sys_enter_writev")
+int trace_writev_entry(struct trace_event_raw_sys_enter *ctx) {
+	count_probe(PROBE_WRITEV_ENTRY);
+	return emit_iovecs((const struct iovec *)ctx->args[1], (size_t)ctx->args[2], (s32)ctx->args[0], 0, HOOK_WRITEV);
+}
+
+SEC("tracepoint/syscalls/sys_enter_sendmsg")
+int trace_sendmsg_entry(struct trace_event_raw_sys_enter *ctx) {
+	struct user_msghdr hdr;
+
+	count_probe(PROBE_SENDMSG_ENTRY);
+
+	if (bpf_probe_read_user(&hdr, sizeof(hdr), (const void *)ctx->args[1]) != 0) {
+		return 0;
 	}
+	return emit_iovecs(hdr.msg_iov, hdr.msg_iovlen, (s32)ctx->args[0], (u64)hdr.msg_name, HOOK_SENDMSG);
+}
 
-	bpf_map_delete_elem(&pending_reads, &tid);
+SEC("tracepoint/syscalls/sys_enter_sendmmsg")
+int trace_sendmmsg_entry(struct trace_event_raw_sys_enter *ctx) {
+	s32 fd = (s32)ctx->args[0];
+	const struct mmsghdr *vec = (const struct mmsghdr *)ctx->args[1];
+	unsigned int vlen = (unsigned int)ctx->args[2];
+	struct user_msghdr hdr;
+	struct iovec iov;
+
+	count_probe(PROBE_SENDMMSG_ENTRY);
+
+	// Resolvers send the A and AAAA queries in one call, so the first few
+	// messages are inspected rather than only the first.
+	for (unsigned int i = 0; i < MAX_MMSG; i++) {
+		if (i >= vlen) {
+			break;
+		}
+		if (bpf_probe_read_user(&hdr, sizeof(hdr), &vec[i].msg_hdr) != 0) {
+			break;
+		}
+		if (hdr.msg_iovlen == 0 || bpf_probe_read_user(&iov, sizeof(iov), hdr.msg_iov) != 0) {
+			continue;
+		}
+		emit_egress((const char *)iov.iov_base, iov.iov_len, fd, (u64)hdr.msg_name, HOOK_SENDMMSG);
+	}
 	return 0;
 }
 
+SEC("tracepoint/syscalls/sys_enter_read")
+int trace_read_entry(struct trace_event_raw_sys_enter *ctx) {
+	count_probe(PROBE_READ_ENTRY);
+	return track_read((s32)ctx->args[0], (u64)ctx->args[1], 0, HOOK_READ);
+}
+
+SEC("tracepoint/syscalls/sys_exit_read")
+int trace_read_exit(struct trace_event_raw_sys_exit *ctx) {
+	count_probe(PROBE_READ_EXIT);
+	return handle_read_exit(ctx->ret);
+}
+
 SEC("tracepoint/syscalls/sys_enter_recvfrom")
 int trace_recv_entry(struct trace_event_raw_sys_enter *ctx) {
-	u64 id = bpf_get_current_pid_tgid();
-	u32 tid = (u32)id;
-	struct read_args_t args = {};
-
-	args.fd = (s32)ctx->args[0];
-	args.buf = (u64)ctx->args[1];
-	bpf_map_update_elem(&pending_reads, &tid, &args, BPF_ANY);
-	return 0;
+	count_probe(PROBE_RECV_ENTRY);
+	return track_read((s32)ctx->args[0], (u64)ctx->args[1], (u64)ctx->args[4], HOOK_RECVFROM);
 }
 
 SEC("tracepoint/syscalls/sys_exit_recvfrom")
 int trace_recv_exit(struct trace_event_raw_sys_exit *ctx) {
-	u64 id = bpf_get_current_pid_tgid();
-	u32 tid = (u32)id;
-	struct read_args_t *args = bpf_map_lookup_elem(&pending_reads, &tid);
-	long ret = ctx->ret;
-	char prefix[8] = {};
+	count_probe(PROBE_RECV_EXIT);
+	return handle_read_exit(ctx->ret);
+}
+
+SEC("tracepoint/syscalls/sys_enter_readv")
+int trace_readv_entry(struct trace_event_raw_sys_enter *ctx) {
+	count_probe(PROBE_READV_ENTRY);
+	if ((size_t)ctx->args[2] == 0) {
+		return 0;
+	}
+	return track_read((s32)ctx->args[0], (u64)ctx->args[1], 0, HOOK_READV);
+}
+
+SEC("tracepoint/syscalls/sys_exit_readv")
+int trace_readv_exit(struct trace_event_raw_sys_exit *ctx) {
+	count_probe(PROBE_READV_EXIT);
+	return handle_read_exit(ctx->ret);
+}
+
+SEC("tracepoint/syscalls/sys_enter_recvmsg")
+int trace_recvmsg_entry(struct trace_event_raw_sys_enter *ctx) {
+	count_probe(PROBE_RECVMSG_ENTRY);
+	return track_read((s32)ctx->args[0], (u64)ctx->args[1], 0, HOOK_RECVMSG);
+}
+
+SEC("tracepoint/syscalls/sys_exit_recvmsg")
+int trace_recvmsg_exit(struct trace_event_raw_sys_exit *ctx) {
+	count_probe(PROBE_RECVMSG_EXIT);
+	return handle_read_exit(ctx->ret);
+}
+
+SEC("tracepoint/syscalls/sys_enter_recvmmsg")
+int trace_recvmmsg_entry(struct trace_event_raw_sys_enter *ctx) {
+	count_probe(PROBE_RECVMMSG_ENTRY);
+	if ((unsigned int)ctx->args[2] == 0) {
+		return 0;
+	}
+	return track_read((s32)ctx->args[0], (u64)ctx->args[1], 0, HOOK_RECVMMSG);
+}
+
+SEC("tracepoint/syscalls/sys_exit_recvmmsg")
+int trace_recvmmsg_exit(struct trace_event_raw_sys_exit *ctx) {
+	count_probe(PROBE_RECVMMSG_EXIT);
+	return handle_read_exit(ctx->ret);
+}
+
+SEC("tracepoint/syscalls/sys_enter_close")
+int trace_close_entry(struct trace_event_raw_sys_enter *ctx) {
+	count_probe(PROBE_CLOSE_ENTRY);
+	return emit_close((s32)ctx->args[0], HOOK_CLOSE);
+}
+
+// A socket shut down for writing only still receives the responses to what
+// it sent, so only shutdowns that stop reading end its requests.
+SEC("tracepoint/syscalls/sys_enter_shutdown")
+int trace_shutdown_entry(struct trace_event_raw_sys_enter *ctx) {
+	count_probe(PROBE_SHUTDOWN_ENTRY);
+	if ((int)ctx->args[1] == SHUT_WR) {
+		return 0;
+	}
+	return emit_close((s32)ctx->args[0], HOOK_SHUTDOWN);
+}
+
+static __always_inline int ssl_enter(u64 ssl, u64 buf, u64 out_len) {
+	u32 tid = (u32)bpf_get_current_pid_tgid();
+	struct ssl_args_t args = {};
+
+	args.ssl = ssl;
+	args.buf = buf;
+	args.out_len = out_len;
+	args.fd = -1;
+	bpf_map_update_elem(&ssl_calls, &tid, &args, BPF_ANY);
+	return 0;
+}
+
+static __always_inline int ssl_exit(long ret, int is_write) {
+	u32 tid = (u32)bpf_get_current_pid_tgid();
+	struct ssl_args_t *args = bpf_map_lookup_elem(&ssl_calls, &tid);
+	char prefix[PREFIX_LEN] = {};
+	size_t count = 0;
+	u8 event_type;
+	u8 protocol = 0;
+	u64 ssl;
+	u64 buf;
+	s32 fd;
 
 	if (!args) {
 		return 0;
 	}
 
-	if (ret <= 0) {
-		bpf_map_delete_elem(&pending_reads, &tid);
+	ssl = args->ssl;
+	buf = args->buf;
+	fd = args->fd;
+
+	// SSL_read_ex/SSL_write_ex return 1 on success and report the length
+	// through an out pointer; the classic variants return the length.
+	if (args->out_len) {
+		if (ret != 1 || bpf_probe_read_user(&count, sizeof(count), (const void *)args->out_len) != 0) {
+			bpf_map_delete_elem(&ssl_calls, &tid);
+			return 0;
+		}
+	} else if (ret > 0) {
+		count = (size_t)ret;
+	}
+	bpf_map_delete_elem(&ssl_calls, &tid);
+
+	// Reads served from the TLS record buffer never reach a syscall, so fall
+	// back to the fd last seen for this SSL object.
+	if (fd >= 0) {
+		bpf_map_update_elem(&ssl_fds, &ssl, &fd, BPF_ANY);
+	} else {
+		s32 *known = bpf_map_lookup_elem(&ssl_fds, &ssl);
+		if (known) {
+			fd = *known;
+		}
+	}
+
+	if (read_prefix(prefix, (const char *)buf, count) != 0) {
 		return 0;
 	}
 
-	if (ret < 4) {
-		bpf_map_delete_elem(&pending_reads, &tid);
+	event_type = classify(fd, prefix, count, !is_write, &protocol);
+	if (!event_type) {
 		return 0;
 	}
+	return emit_event((const char *)buf, count, fd, 0, event_type, protocol, is_write ? FLAG_TLS : FLAG_TLS | FLAG_INGRESS, HOOK_SSL);
+}
+
+SEC("uprobe/SSL_write")
+int trace_ssl_write_entry(struct pt_regs *ctx) {
+	count_probe(PROBE_SSL_WRITE_ENTRY);
+	return ssl_enter(C_PARAM1(ctx), C_PARAM2(ctx), 0);
+}
+
+SEC("uprobe/SSL_write_ex")
+int trace_ssl_write_ex_entry(struct pt_regs *ctx) {
+	count_probe(PROBE_SSL_WRITE_EX_ENTRY);
+	return ssl_enter(C_PARAM1(ctx), C_PARAM2(ctx), C_PARAM4(ctx));
+}
+
+SEC("uretprobe/SSL_write")
+int trace_ssl_write_exit(struct pt_regs *ctx) {
+	count_probe(PROBE_SSL_WRITE_EXIT);
+	return ssl_exit((int)C_RET(ctx), 1);
+}
+
+SEC("uprobe/SSL_read")
+int trace_ssl_read_entry(struct pt_regs *ctx) {
+	count_probe(PROBE_SSL_READ_ENTRY);
+	return ssl_enter(C_PARAM1(ctx), C_PARAM2(ctx), 0);
+}
 
-	if (bpf_probe_read_user(prefix, sizeof(prefix), (const void *)args->buf) != 0) {
-		bpf_map_delete_elem(&pending_reads, &tid);
+SEC("uprobe/SSL_read_ex")
+int trace_ssl_read_ex_entry(struct pt_regs *ctx) {
+	count_probe(PROBE_SSL_READ_EX_ENTRY);
+	return ssl_enter(C_PARAM1(ctx), C_PARAM2(ctx), C_PARAM4(ctx));
+}
+
+SEC("uretprobe/SSL_read")
+int trace_ssl_read_exit(struct pt_regs *ctx) {
+	count_probe(PROBE_SSL_READ_EXIT);
+	return ssl_exit((int)C_RET(ctx), 0);
+}
+
+static __always_inline s32 go_tls_conn_fd(u64 conn) {
+	u64 data = 0;
+	u64 netfd = 0;
+	s64 sysfd = -1;
+
+	if (bpf_probe_read_user(&data, sizeof(data), (const void *)(conn + GO_TLS_CONN_DATA_OFFSET)) != 0) {
+		return -1;
+	}
+	if (bpf_probe_read_user(&netfd, sizeof(netfd), (const void *)data) != 0) {
+		return -1;
+	}
+	if (bpf_probe_read_user(&sysfd, sizeof(sysfd), (const void *)(netfd + GO_NETFD_SYSFD_OFFSET)) != 0) {
+		return -1;
+	}
+	return (s32)sysfd;
+}
+
+static __always_inline void mark_go_tls_sock(s32 fd) {
+	struct conn_key_t key = {};
+	u8 one = 1;
+
+	if (fd < 0) {
+		return;
+	}
+	key.pid = bpf_get_current_pid_tgid() >> 32;
+	key.fd = fd;
+	if (!bpf_map_lookup_elem(&go_tls_socks, &key)) {
+		bpf_map_update_elem(&go_tls_socks, &key, &one, BPF_ANY);
+	}
+}
+
+SEC("uprobe/go_tls_write")
+int trace_go_tls_write_entry(struct pt_regs *ctx) {
+	u64 conn = GO_PARAM1(ctx);
+	const char *buf = (const char *)GO_PARAM2(ctx);
+	size_t count = (size_t)GO_PARAM3(ctx);
+	char prefix[PREFIX_LEN] = {};
+	u8 event_type;
+	u8 protocol = 0;
+	s32 fd;
+
+	count_probe(PROBE_GO_TLS_WRITE_ENTRY);
+
+	fd = go_tls_conn_fd(conn);
+	mark_go_tls_sock(fd);
+	if (read_prefix(prefix, buf, count) != 0) {
+		return 0;
+	}
+	event_type = classify(fd, prefix, count, 0, &protocol);
+	if (!event_type) {
+		return 0;
+	}
+
+	return emit_event(buf, count, fd, 0, event_type, protocol, FLAG_TLS, HOOK_GO_TLS);
+}
+
+// Go stacks move, so uretprobes are unsafe; the exit probe is attached to
+// every RET instruction of Read instead. Calls are keyed by goroutine since
+// a blocked Read may resume on a different thread.
+SEC("uprobe/go_tls_read")
+int trace_go_tls_read_entry(struct pt_regs *ctx) {
+	struct go_tls_key_t key = {};
+	struct go_tls_args_t args = {};
+
+	count_probe(PROBE_GO_TLS_READ_ENTRY);
+
+	key.pid = bpf_get_current_pid_tgid() >> 32;
+	key.goid = GO_G(ctx);
+	args.buf = GO_PARAM2(ctx);
+	args.fd = go_tls_conn_fd(GO_PARAM1(ctx));
+	mark_go_tls_sock(args.fd);
+	bpf_map_update_elem(&go_tls_reads, &key, &args, BPF_ANY);
+	return 0;
+}
+
+SEC("uprobe/go_tls_read_ret")
+int trace_go_tls_read_exit(struct pt_regs *ctx) {
+	struct go_tls_key_t key = {};
+	struct go_tls_args_t *args;
+	long ret = (long)GO_PARAM1(ctx);
+	char prefix[PREFIX_LEN] = {};
+	u8 event_type = 0;
+	u8 protocol = 0;
+
+	count_probe(PROBE_GO_TLS_READ_EXIT);
+
+	key.pid = bpf_get_current_pid_tgid() >> 32;
+	key.goid = GO_G(ctx);
+	args = bpf_map_lookup_elem(&go_tls_reads, &key);
+	if (!args) {
 		return 0;
 	}
 
-	if (is_http_response(prefix)) {
-		emit_event((const char *)args->buf, (size_t)ret, args->fd, EVENT_RESPONSE);
+	if (ret > 0 && read_prefix(prefix, (const char *)args->buf, (size_t)ret) == 0) {
+		event_type = classify(args->fd, prefix, (size_t)ret, 1, &protocol);
+	}
+	if (event_type) {
+		emit_event((const char *)args->buf, (size_t)ret, args->fd, 0, event_type, protocol, FLAG_TLS | FLAG_INGRESS, HOOK_GO_TLS);
 	}
 
-	bpf_map_delete_elem(&pending_reads, &tid);
+	bpf_map_delete_elem(&go_tls_reads, &key);
 	return 0;
 }
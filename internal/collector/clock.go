@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	clockCalibrateInterval = 10 * time.Second
+	// Offset changes larger than this between calibrations are treated as a
+	// wall-clock step (NTP jump, manual date change) rather than drift.
+	clockStepThreshold = 50 * time.Millisecond
+	clockSamples       = 5
+)
+
+// bootClock converts CLOCK_BOOTTIME nanoseconds, as stamped by
+// bpf_ktime_get_boot_ns, into wall-clock time. The offset between the two
+// clocks is recalibrated periodically so slewing and steps of the system clock
+// are picked up.
+type bootClock struct {
+	offset atomic.Int64
+	steps  atomic.Uint64
+}
+
+func newBootClock() *bootClock {
+	c := &bootClock{}
+	if offset, ok := measureBootOffset(); ok {
+		c.offset.Store(offset)
+	}
+	return c
+}
+
+func (c *bootClock) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if offset, ok := measureBootOffset(); ok {
+				c.update(offset)
+			}
+		}
+	}
+}
+
+// update stores a new offset and reports whether it was a step.
+func (c *bootClock) update(offset int64) bool {
+	previous := c.offset.Swap(offset)
+	delta := time.Duration(offset - previous)
+	if delta < 0 {
+		delta = -delta
+	}
+	if delta <= clockStepThreshold {
+		return false
+	}
+	c.steps.Add(1)
+	log.Printf("wall clock stepped by %v, boot time offset recalibrated", time.Duration(offset-previous))
+	return true
+}
+
+func (c *bootClock) Time(bootNs uint64) time.Time {
+	return time.Unix(0, int64(bootNs)+c.offset.Load()).UTC()
+}
+
+func (c *bootClock) Offset() time.Duration {
+	return time.Duration(c.offset.Load())
+}
+
+func (c *bootClock) Steps() uint64 {
+	return c.steps.Load()
+}
+
+// measureBootOffset returns realtime minus boottime in nanoseconds. The
+// boottime read is bracketed by two realtime reads and the tightest of a few
+// samples is used, which keeps scheduling noise out of the offset.
+func measureBootOffset() (int64, bool) {
+	var (
+		best    int64
+		bestGap int64 = -1
+	)
+	for range clockSamples {
+		var before, boot, after unix.Timespec
+		if unix.ClockGettime(unix.CLOCK_REALTIME, &before) != nil ||
+			unix.ClockGettime(unix.CLOCK_BOOTTIME, &boot) != nil ||
+			unix.ClockGettime(unix.CLOCK_REALTIME, &after) != nil {
+			log.Printf("read clocks for boot time offset failed")
+			return 0, false
+		}
+		gap := after.Nano() - before.Nano()
+		if bestGap < 0 || gap < bestGap {
+			bestGap = gap
+			best = before.Nano() + gap/2 - boot.Nano()
+		}
+	}
+	return best, true
+}
@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestBootClockTime(t *testing.T) {
+	clock := newBootClock()
+
+	var boot unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_BOOTTIME, &boot); err != nil {
+		t.Fatalf("clock_gettime: %v", err)
+	}
+	got := clock.Time(uint64(boot.Nano()))
+	if diff := time.Since(got); diff < -time.Second || diff > time.Second {
+		t.Fatalf("converted time %v is %v away from now", got, diff)
+	}
+}
+
+func TestBootClockUpdate(t *testing.T) {
+	clock := &bootClock{}
+	clock.offset.Store(int64(time.Hour))
+
+	if clock.update(int64(time.Hour + time.Millisecond)) {
+		t.Fatalf("1ms drift reported as step")
+	}
+	if !clock.update(int64(time.Hour + 2*time.Second)) {
+		t.Fatalf("2s jump not reported as step")
+	}
+	if clock.Steps() != 1 {
+		t.Fatalf("steps = %d, want 1", clock.Steps())
+	}
+	if clock.Offset() != time.Hour+2*time.Second {
+		t.Fatalf("offset = %v", clock.Offset())
+	}
+}
@@ -1,19 +1,34 @@
 package collector
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"fmt"
 	"log"
+	"net/netip"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/ringbuf"
 	"github.com/cilium/ebpf/rlimit"
 )
 
-const maxEventData = 128
+// maxEventData mirrors MAX_DATA in bpf/tracker.c, the largest payload a single
+// event can carry.
+const maxEventData = 4096
+
+const (
+	flagTLS     = 1
+	flagIngress = 2
+)
+
+const (
+	afInet  = 2
+	afInet6 = 10
+)
 
 type Direction uint8
 
@@ -21,130 +36,164 @@ const (
 	DirectionUnknown  Direction = 0
 	DirectionRequest  Direction = 1
 	DirectionResponse Direction = 2
+	// DirectionClose marks the end of a socket. Close events carry no data.
+	DirectionClose Direction = 3
+)
+
+type Role uint8
+
+const (
+	RoleUnknown Role = 0
+	RoleClient  Role = 1
+	RoleServer  Role = 2
 )
 
+func (r Role) String() string {
+	switch r {
+	case RoleClient:
+		return "client"
+	case RoleServer:
+		return "server"
+	default:
+		return ""
+	}
+}
+
 type Event struct {
 	Timestamp time.Time
 	Pid       uint32
 	Tid       uint32
 	Fd        int32
 	CgroupID  uint64
+	// Socket identifies the socket behind Fd, so a reused fd number can be
+	// told apart from the connection that had it before. It is 0 when
+	// unknown, as for packet captures.
+	Socket    uint64
 	Direction Direction
+	TLS       bool
+	Ingress   bool
+	Hook      Hook
+	Protocol  Protocol
+	Local     netip.AddrPort
+	Remote    netip.AddrPort
 	Data      []byte
+	// Length is the size of the read or write Data was captured from. It
+	// exceeds len(Data) when the capture was truncated and is 0 when unknown.
+	Length uint32
+}
+
+// Role reports which side of the connection the traced process is on: a
+// client sends requests and receives responses, a server the opposite.
+func (e Event) Role() Role {
+	switch e.Direction {
+	case DirectionRequest:
+		if e.Ingress {
+			return RoleServer
+		}
+		return RoleClient
+	case DirectionResponse:
+		if e.Ingress {
+			return RoleClient
+		}
+		return RoleServer
+	default:
+		return RoleUnknown
+	}
 }
 
 type Collector struct {
 	objs       TrackerObjects
-	tpWrite    link.Link
-	tpSend     link.Link
-	tpWritev   link.Link
-	tpReadEnt  link.Link
-	tpReadExit link.Link
-	tpRecvEnt  link.Link
-	tpRecvExit link.Link
+	links      []link.Link
 	reader     *ringbuf.Reader
+	tls        *tlsTracer
+	clock      *bootClock
+	hookEvents [numHooks]atomic.Uint64
+	filterMu   sync.Mutex
+}
+
+type tracepoint struct {
+	name string
+	prog *ebpf.Program
 }
 
-func New() (*Collector, error) {
+// New loads and attaches the BPF programs. captureBytes caps the payload
+// copied per event in the kernel; values outside 1..maxEventData are clamped.
+func New(captureBytes int) (*Collector, error) {
 	if err := rlimit.RemoveMemlock(); err != nil {
 		return nil, fmt.Errorf("locking err: %w", err)
 	}
 
-	var objs TrackerObjects
-	if err := LoadTrackerObjects(&objs, nil); err != nil {
-		return nil, fmt.Errorf("load objects: %w", err)
-	}
-
-	tpWrite, err := link.Tracepoint("syscalls", "sys_enter_write", objs.TraceWriteEntry, nil)
+	spec, err := LoadTracker()
 	if err != nil {
-		objs.Close()
-		return nil, fmt.Errorf("link sys_enter_write: %w", err)
+		return nil, fmt.Errorf("load spec: %w", err)
 	}
-
-	tpSend, err := link.Tracepoint("syscalls", "sys_enter_sendto", objs.TraceSendtoEntry, nil)
-	if err != nil {
-		tpWrite.Close()
-		objs.Close()
-		return nil, fmt.Errorf("link sys_enter_sendto: %w", err)
-	}
-
-	tpWritev, err := link.Tracepoint("syscalls", "sys_enter_writev", objs.TraceWritevEntry, nil)
-	if err != nil {
-		tpWrite.Close()
-		tpSend.Close()
-		objs.Close()
-		return nil, fmt.Errorf("link sys_enter_writev: %w", err)
+	captureVar, ok := spec.Variables["capture_bytes"]
+	if !ok {
+		return nil, fmt.Errorf("capture_bytes variable missing from BPF object")
 	}
-
-	tpReadEnt, err := link.Tracepoint("syscalls", "sys_enter_read", objs.TraceReadEntry, nil)
-	if err != nil {
-		tpWritev.Close()
-		tpSend.Close()
-		tpWrite.Close()
-		objs.Close()
-		return nil, fmt.Errorf("link sys_enter_read: %w", err)
+	if err := captureVar.Set(uint32(clampCaptureBytes(captureBytes))); err != nil {
+		return nil, fmt.Errorf("set capture_bytes: %w", err)
 	}
 
-	tpReadExit, err := link.Tracepoint("syscalls", "sys_exit_read", objs.TraceReadExit, nil)
-	if err != nil {
-		tpReadEnt.Close()
-		tpWritev.Close()
-		tpSend.Close()
-		tpWrite.Close()
-		objs.Close()
-		return nil, fmt.Errorf("link sys_exit_read: %w", err)
+	var objs TrackerObjects
+	if err := spec.LoadAndAssign(&objs, nil); err != nil {
+		return nil, fmt.Errorf("load objects: %w", err)
 	}
 
-	tpRecvEnt, err := link.Tracepoint("syscalls", "sys_enter_recvfrom", objs.TraceRecvEntry, nil)
-	if err != nil {
-		tpReadExit.Close()
-		tpReadEnt.Close()
-		tpWritev.Close()
-		tpSend.Close()
-		tpWrite.Close()
-		objs.Close()
-		return nil, fmt.Errorf("link sys_enter_recvfrom: %w", err)
+	tracepoints := []tracepoint{
+		{"sys_enter_write", objs.TraceWriteEntry},
+		{"sys_enter_sendto", objs.TraceSendtoEntry},
+		{"sys_enter_writev", objs.TraceWritevEntry},
+		{"sys_enter_sendmsg", objs.TraceSendmsgEntry},
+		{"sys_enter_sendmmsg", objs.TraceSendmmsgEntry},
+		{"sys_enter_read", objs.TraceReadEntry},
+		{"sys_exit_read", objs.TraceReadExit},
+		{"sys_enter_recvfrom", objs.TraceRecvEntry},
+		{"sys_exit_recvfrom", objs.TraceRecvExit},
+		{"sys_enter_readv", objs.TraceReadvEntry},
+		{"sys_exit_readv", objs.TraceReadvExit},
+		{"sys_enter_recvmsg", objs.TraceRecvmsgEntry},
+		{"sys_exit_recvmsg", objs.TraceRecvmsgExit},
+		{"sys_enter_recvmmsg", objs.TraceRecvmmsgEntry},
+		{"sys_exit_recvmmsg", objs.TraceRecvmmsgExit},
+		{"sys_enter_close", objs.TraceCloseEntry},
+		{"sys_enter_shutdown", objs.TraceShutdownEntry},
 	}
 
-	tpRecvExit, err := link.Tracepoint("syscalls", "sys_exit_recvfrom", objs.TraceRecvExit, nil)
-	if err != nil {
-		tpRecvEnt.Close()
-		tpReadExit.Close()
-		tpReadEnt.Close()
-		tpWritev.Close()
-		tpSend.Close()
-		tpWrite.Close()
-		objs.Close()
-		return nil, fmt.Errorf("link sys_exit_recvfrom: %w", err)
+	links := make([]link.Link, 0, len(tracepoints))
+	for _, tp := range tracepoints {
+		l, err := link.Tracepoint("syscalls", tp.name, tp.prog, nil)
+		if err != nil {
+			closeLinks(links)
+			objs.Close()
+			return nil, fmt.Errorf("link %s: %w", tp.name, err)
+		}
+		links = append(links, l)
 	}
 
 	reader, err := ringbuf.NewReader(objs.Events)
 	if err != nil {
-		tpRecvExit.Close()
-		tpRecvEnt.Close()
-		tpReadExit.Close()
-		tpReadEnt.Close()
-		tpWritev.Close()
-		tpSend.Close()
-		tpWrite.Close()
+		closeLinks(links)
 		objs.Close()
 		return nil, fmt.Errorf("open ringbuf reader: %w", err)
 	}
 
+	tls := newTLSTracer(&objs)
+	tls.Scan()
+
 	return &Collector{
-		objs:       objs,
-		tpWrite:    tpWrite,
-		tpSend:     tpSend,
-		tpWritev:   tpWritev,
-		tpReadEnt:  tpReadEnt,
-		tpReadExit: tpReadExit,
-		tpRecvEnt:  tpRecvEnt,
-		tpRecvExit: tpRecvExit,
-		reader:     reader,
+		objs:   objs,
+		links:  links,
+		reader: reader,
+		tls:    tls,
+		clock:  newBootClock(),
 	}, nil
 }
 
 func (c *Collector) Run(ctx context.Context, handler func(Event)) error {
+	go c.tls.Run(ctx, tlsScanInterval)
+	go c.clock.Run(ctx, clockCalibrateInterval)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -161,11 +210,14 @@ func (c *Collector) Run(ctx context.Context, handler func(Event)) error {
 			continue
 		}
 
-		event, err := parseEvent(record.RawSample)
+		event, err := parseEvent(record.RawSample, c.clock)
 		if err != nil {
 			log.Printf("parse event error: %v", err)
 			continue
 		}
+		if int(event.Hook) < numHooks {
+			c.hookEvents[event.Hook].Add(1)
+		}
 
 		handler(event)
 	}
@@ -175,62 +227,89 @@ func (c *Collector) Close() {
 	if c.reader != nil {
 		c.reader.Close()
 	}
-	if c.tpRecvExit != nil {
-		c.tpRecvExit.Close()
-	}
-	if c.tpRecvEnt != nil {
-		c.tpRecvEnt.Close()
-	}
-	if c.tpReadExit != nil {
-		c.tpReadExit.Close()
-	}
-	if c.tpReadEnt != nil {
-		c.tpReadEnt.Close()
-	}
-	if c.tpWritev != nil {
-		c.tpWritev.Close()
-	}
-	if c.tpSend != nil {
-		c.tpSend.Close()
-	}
-	if c.tpWrite != nil {
-		c.tpWrite.Close()
+	if c.tls != nil {
+		c.tls.Close()
 	}
+	closeLinks(c.links)
+	c.links = nil
 	c.objs.Close()
 }
 
-type bpfEvent struct {
+func clampCaptureBytes(n int) int {
+	if n <= 0 || n > maxEventData {
+		return maxEventData
+	}
+	return n
+}
+
+// bpfEventHeader mirrors struct event_t up to data. Records are variable
+// length: the header is followed by exactly data_len payload bytes.
+type bpfEventHeader struct {
 	TsNs      uint64
 	CgroupID  uint64
+	SockIno   uint64
 	Pid       uint32
 	Tid       uint32
 	Fd        int32
 	DataLen   uint32
+	BufLen    uint32
 	EventType uint8
-	_         [3]byte
-	Data      [maxEventData]byte
+	Flags     uint8
+	Hook      uint8
+	Protocol  uint8
+	Family    uint16
+	LPort     uint16
+	RPort     uint16
+	_         [2]byte
+	LAddr     [16]byte
+	RAddr     [16]byte
 }
 
-func parseEvent(raw []byte) (Event, error) {
-	var evt bpfEvent
-	if err := binary.Read(bytes.NewReader(raw), binary.LittleEndian, &evt); err != nil {
-		return Event{}, fmt.Errorf("binary read: %w", err)
+var bpfEventHeaderSize = binary.Size(bpfEventHeader{})
+
+func parseEvent(raw []byte, clock *bootClock) (Event, error) {
+	var evt bpfEventHeader
+	if len(raw) < bpfEventHeaderSize {
+		return Event{}, fmt.Errorf("short record: %d bytes", len(raw))
+	}
+	if _, err := binary.Decode(raw, binary.LittleEndian, &evt); err != nil {
+		return Event{}, fmt.Errorf("binary decode: %w", err)
 	}
 
+	payload := raw[bpfEventHeaderSize:]
 	dataLen := int(evt.DataLen)
-	if dataLen > maxEventData {
-		dataLen = maxEventData
+	if dataLen > len(payload) {
+		return Event{}, fmt.Errorf("record truncated: data_len %d, have %d bytes", dataLen, len(payload))
 	}
 	data := make([]byte, dataLen)
-	copy(data, evt.Data[:dataLen])
+	copy(data, payload[:dataLen])
 
 	return Event{
-		Timestamp: time.Unix(0, int64(evt.TsNs)),
+		Timestamp: clock.Time(evt.TsNs),
 		Pid:       evt.Pid,
 		Tid:       evt.Tid,
 		Fd:        evt.Fd,
 		CgroupID:  evt.CgroupID,
+		Socket:    evt.SockIno,
 		Direction: Direction(evt.EventType),
-		Data:      bytes.TrimRight(data, "\x00"),
+		TLS:       evt.Flags&flagTLS != 0,
+		Ingress:   evt.Flags&flagIngress != 0,
+		Hook:      Hook(evt.Hook),
+		Protocol:  Protocol(evt.Protocol),
+		Local:     socketAddr(evt.Family, evt.LAddr, evt.LPort),
+		Remote:    socketAddr(evt.Family, evt.RAddr, evt.RPort),
+		Data:      data,
+		Length:    evt.BufLen,
 	}, nil
 }
+
+func socketAddr(family uint16, addr [16]byte, port uint16) netip.AddrPort {
+	switch family {
+	case afInet:
+		return netip.AddrPortFrom(netip.AddrFrom4([4]byte(addr[:4])), port)
+	case afInet6:
+		return netip.AddrPortFrom(netip.AddrFrom16(addr), port)
+	default:
+		return netip.AddrPort{}
+	}
+}
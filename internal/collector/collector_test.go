@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"unsafe"
+)
+
+func TestEventRole(t *testing.T) {
+	cases := []struct {
+		event Event
+		want  Role
+	}{
+		{Event{Direction: DirectionRequest}, RoleClient},
+		{Event{Direction: DirectionResponse, Ingress: true}, RoleClient},
+		{Event{Direction: DirectionRequest, Ingress: true}, RoleServer},
+		{Event{Direction: DirectionResponse}, RoleServer},
+		{Event{Direction: DirectionUnknown}, RoleUnknown},
+	}
+	for _, tc := range cases {
+		if got := tc.event.Role(); got != tc.want {
+			t.Fatalf("role for %+v = %v, want %v", tc.event, got, tc.want)
+		}
+	}
+}
+
+func TestParseEventVariableLength(t *testing.T) {
+	payload := []byte("GET / HTTP/1.1\r\nHost: example\r\n\x00\x01")
+	header := bpfEventHeader{
+		Pid:       42,
+		Fd:        7,
+		DataLen:   uint32(len(payload)),
+		EventType: uint8(DirectionRequest),
+		Family:    afInet,
+		LPort:     8080,
+		LAddr:     [16]byte{127, 0, 0, 1},
+	}
+	raw, err := binary.Append(nil, binary.LittleEndian, header)
+	if err != nil {
+		t.Fatalf("encode header: %v", err)
+	}
+	raw = append(raw, payload...)
+
+	event, err := parseEvent(raw, &bootClock{})
+	if err != nil {
+		t.Fatalf("parseEvent: %v", err)
+	}
+	if !bytes.Equal(event.Data, payload) {
+		t.Fatalf("data = %q, want %q", event.Data, payload)
+	}
+	if event.Pid != 42 || event.Fd != 7 || event.Direction != DirectionRequest {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if got := event.Local.String(); got != "127.0.0.1:8080" {
+		t.Fatalf("local = %s", got)
+	}
+
+	if _, err := parseEvent(raw[:len(raw)-1], &bootClock{}); err == nil {
+		t.Fatalf("expected error for truncated record")
+	}
+}
+
+func TestTrackerSpec(t *testing.T) {
+	spec, err := LoadTracker()
+	if err != nil {
+		t.Fatalf("LoadTracker: %v", err)
+	}
+	if _, ok := spec.Variables["capture_bytes"]; !ok {
+		t.Fatalf("capture_bytes variable missing from BPF object")
+	}
+	var specs TrackerSpecs
+	if err := spec.Assign(&specs); err != nil {
+		t.Fatalf("BPF object does not match its bindings: %v", err)
+	}
+}
+
+func TestEventHeaderLayout(t *testing.T) {
+	var ev TrackerEventT
+	if got := int(unsafe.Offsetof(ev.Data)); got != bpfEventHeaderSize {
+		t.Fatalf("event_t data at offset %d, header is %d bytes", got, bpfEventHeaderSize)
+	}
+	var header bpfEventHeader
+	if unsafe.Offsetof(ev.SockIno) != unsafe.Offsetof(header.SockIno) || unsafe.Offsetof(ev.Laddr) != unsafe.Offsetof(header.LAddr) {
+		t.Fatalf("bpfEventHeader does not mirror event_t")
+	}
+}
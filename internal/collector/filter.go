@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/cilium/ebpf"
+)
+
+// FilterKind selects the capture filter map; values match the
+// FILTER_KIND_* constants in bpf/tracker.c.
+type FilterKind uint32
+
+const (
+	FilterCgroup FilterKind = 0
+	FilterPID    FilterKind = 1
+	FilterPort   FilterKind = 2
+)
+
+type FilterAction uint8
+
+const (
+	FilterAllow FilterAction = 1
+	FilterDeny  FilterAction = 2
+)
+
+// AddFilter installs or replaces a kernel-side capture filter. Deny entries
+// drop matching events before they reach the ring buffer. Once a kind has at
+// least one allow entry, only events matching an allow entry of that kind are
+// captured. Port filters match either the local or the remote port.
+func (c *Collector) AddFilter(kind FilterKind, value uint64, action FilterAction) error {
+	if action != FilterAllow && action != FilterDeny {
+		return fmt.Errorf("invalid filter action %d", action)
+	}
+
+	c.filterMu.Lock()
+	defer c.filterMu.Unlock()
+
+	m, key, err := c.filterEntry(kind, value)
+	if err != nil {
+		return err
+	}
+
+	prev, err := lookupFilter(m, key)
+	if err != nil {
+		return err
+	}
+	if err := m.Put(key, uint8(action)); err != nil {
+		return fmt.Errorf("put filter: %w", err)
+	}
+
+	switch {
+	case action == FilterAllow && prev != FilterAllow:
+		return c.adjustAllowCount(kind, 1)
+	case action != FilterAllow && prev == FilterAllow:
+		return c.adjustAllowCount(kind, -1)
+	}
+	return nil
+}
+
+func (c *Collector) RemoveFilter(kind FilterKind, value uint64) error {
+	c.filterMu.Lock()
+	defer c.filterMu.Unlock()
+
+	m, key, err := c.filterEntry(kind, value)
+	if err != nil {
+		return err
+	}
+
+	prev, err := lookupFilter(m, key)
+	if err != nil || prev == 0 {
+		return err
+	}
+	if err := m.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+		return fmt.Errorf("delete filter: %w", err)
+	}
+	if prev == FilterAllow {
+		return c.adjustAllowCount(kind, -1)
+	}
+	return nil
+}
+
+func (c *Collector) filterEntry(kind FilterKind, value uint64) (*ebpf.Map, any, error) {
+	switch kind {
+	case FilterCgroup:
+		return c.objs.CgroupFilters, value, nil
+	case FilterPID:
+		if value > math.MaxUint32 {
+			return nil, nil, fmt.Errorf("pid %d out of range", value)
+		}
+		return c.objs.PidFilters, uint32(value), nil
+	case FilterPort:
+		if value > math.MaxUint16 {
+			return nil, nil, fmt.Errorf("port %d out of range", value)
+		}
+		return c.objs.PortFilters, uint16(value), nil
+	default:
+		return nil, nil, fmt.Errorf("invalid filter kind %d", kind)
+	}
+}
+
+func (c *Collector) adjustAllowCount(kind FilterKind, delta int) error {
+	key := uint32(kind)
+	var count uint32
+	if err := c.objs.FilterAllowCounts.Lookup(key, &count); err != nil {
+		return fmt.Errorf("lookup allow count: %w", err)
+	}
+	if delta < 0 && count == 0 {
+		return nil
+	}
+	count = uint32(int(count) + delta)
+	if err := c.objs.FilterAllowCounts.Put(key, count); err != nil {
+		return fmt.Errorf("put allow count: %w", err)
+	}
+	return nil
+}
+
+func lookupFilter(m *ebpf.Map, key any) (FilterAction, error) {
+	var action uint8
+	if err := m.Lookup(key, &action); err != nil {
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("lookup filter: %w", err)
+	}
+	return FilterAction(action), nil
+}
@@ -2,4 +2,4 @@
 
 package collector
 
-//go:generate bpf2go -target bpf -type event_t Tracker bpf/tracker.c -- -I./bpf -O2 -g
+//go:generate bpf2go -target amd64,arm64 -type event_t Tracker bpf/tracker.c -- -I./bpf -O2 -g
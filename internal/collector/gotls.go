@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"debug/elf"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/link"
+	"golang.org/x/arch/x86/x86asm"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	goTLSWriteSymbol = "crypto/tls.(*Conn).Write"
+	goTLSReadSymbol  = "crypto/tls.(*Conn).Read"
+
+	// The register-based calling convention the probes rely on landed in
+	// go1.17. The offsets tracker.c follows from a crypto/tls.Conn to its
+	// socket fd are known to hold up to goTLSMaxMinor.
+	goRegisterABIMinor = 17
+	goTLSMaxMinor      = 27
+)
+
+var arm64Ret = []byte{0xc0, 0x03, 0x5f, 0xd6}
+
+func processExecutable(pid string) (fileID, string, error) {
+	path := filepath.Join("/proc", pid, "exe")
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return fileID{}, "", err
+	}
+	return fileID{dev: st.Dev, inode: st.Ino}, path, nil
+}
+
+func (t *tlsTracer) attachGoTLS(path string) ([]link.Link, error) {
+	returns, err := goTLSReadReturns(path)
+	if err != nil || returns == nil {
+		return nil, err
+	}
+
+	ex, err := link.OpenExecutable(path)
+	if err != nil {
+		return nil, fmt.Errorf("open executable: %w", err)
+	}
+
+	write, err := ex.Uprobe(goTLSWriteSymbol, t.objs.TraceGoTlsWriteEntry, nil)
+	if err != nil {
+		return nil, fmt.Errorf("uprobe %s: %w", goTLSWriteSymbol, err)
+	}
+	links := []link.Link{write}
+
+	read, err := ex.Uprobe(goTLSReadSymbol, t.objs.TraceGoTlsReadEntry, nil)
+	if err != nil {
+		closeLinks(links)
+		return nil, fmt.Errorf("uprobe %s: %w", goTLSReadSymbol, err)
+	}
+	links = append(links, read)
+
+	for _, offset := range returns {
+		ret, err := ex.Uprobe(
+			goTLSReadSymbol,
+			t.objs.TraceGoTlsReadExit,
+			&link.UprobeOptions{Offset: offset},
+		)
+		if err != nil {
+			closeLinks(links)
+			return nil, fmt.Errorf("uprobe %s+%#x: %w", goTLSReadSymbol, offset, err)
+		}
+		links = append(links, ret)
+	}
+
+	return links, nil
+}
+
+// goTLSReadReturns returns the offsets of the RET instructions in
+// crypto/tls.(*Conn).Read, or nil if the file is not a Go binary that links
+// crypto/tls.
+func goTLSReadReturns(path string) ([]uint64, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open elf: %w", err)
+	}
+	defer f.Close()
+	if f.Section(".go.buildinfo") == nil {
+		return nil, nil
+	}
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read build info: %w", err)
+	}
+
+	syms, err := f.Symbols()
+	if err != nil {
+		return nil, fmt.Errorf("read symbols: %w", err)
+	}
+
+	var read *elf.Symbol
+	hasWrite := false
+	for i := range syms {
+		switch syms[i].Name {
+		case goTLSReadSymbol:
+			read = &syms[i]
+		case goTLSWriteSymbol:
+			hasWrite = true
+		}
+	}
+	if read == nil || !hasWrite {
+		return nil, nil
+	}
+	minor, ok := goMinorVersion(info.GoVersion)
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("unknown Go version %q", info.GoVersion)
+	case minor < goRegisterABIMinor:
+		return nil, fmt.Errorf("%s predates the register ABI", info.GoVersion)
+	case minor > goTLSMaxMinor:
+		return nil, fmt.Errorf("%s is newer than the crypto/tls layout the probes know", info.GoVersion)
+	}
+
+	if int(read.Section) >= len(f.Sections) {
+		return nil, fmt.Errorf("symbol %s has no section", goTLSReadSymbol)
+	}
+	sec := f.Sections[read.Section]
+	code := make([]byte, read.Size)
+	if _, err := sec.ReadAt(code, int64(read.Value-sec.Addr)); err != nil {
+		return nil, fmt.Errorf("read %s: %w", goTLSReadSymbol, err)
+	}
+
+	offsets, err := returnOffsets(f.Machine, code)
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) == 0 {
+		return nil, fmt.Errorf("no return instructions in %s", goTLSReadSymbol)
+	}
+	return offsets, nil
+}
+
+func returnOffsets(machine elf.Machine, code []byte) ([]uint64, error) {
+	var offsets []uint64
+
+	switch machine {
+	case elf.EM_AARCH64:
+		for off := 0; off+len(arm64Ret) <= len(code); off += len(arm64Ret) {
+			if bytes.Equal(code[off:off+len(arm64Ret)], arm64Ret) {
+				offsets = append(offsets, uint64(off))
+			}
+		}
+	case elf.EM_X86_64:
+		for off := 0; off < len(code); {
+			inst, err := x86asm.Decode(code[off:], 64)
+			if err != nil {
+				off++
+				continue
+			}
+			if inst.Op == x86asm.RET {
+				offsets = append(offsets, uint64(off))
+			}
+			off += inst.Len
+		}
+	default:
+		return nil, fmt.Errorf("unsupported machine %s", machine)
+	}
+
+	return offsets, nil
+}
+
+// goMinorVersion returns the minor release of a go1.x version string.
+func goMinorVersion(version string) (int, bool) {
+	_, rest, ok := strings.Cut(version, "go1.")
+	if !ok {
+		return 0, false
+	}
+	end := 0
+	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+		end++
+	}
+	minor, err := strconv.Atoi(rest[:end])
+	if err != nil {
+		return 0, false
+	}
+	return minor, true
+}
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"debug/elf"
+	"testing"
+)
+
+func TestReturnOffsetsX86(t *testing.T) {
+	// mov %rax,%rbx (whose ModRM byte is 0xc3) followed by a real ret.
+	code := []byte{0x48, 0x89, 0xc3, 0xc3}
+	offsets, err := returnOffsets(elf.EM_X86_64, code)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(offsets) != 1 || offsets[0] != 3 {
+		t.Fatalf("unexpected offsets: %v", offsets)
+	}
+}
+
+func TestReturnOffsetsARM64(t *testing.T) {
+	code := []byte{
+		0x1f, 0x20, 0x03, 0xd5, // nop
+		0xc0, 0x03, 0x5f, 0xd6, // ret
+		0x1f, 0x20, 0x03, 0xd5, // nop
+		0xc0, 0x03, 0x5f, 0xd6, // ret
+	}
+	offsets, err := returnOffsets(elf.EM_AARCH64, code)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(offsets) != 2 || offsets[0] != 4 || offsets[1] != 12 {
+		t.Fatalf("unexpected offsets: %v", offsets)
+	}
+}
+
+func TestGoMinorVersion(t *testing.T) {
+	cases := map[string]int{
+		"go1.16.15":                 16,
+		"go1.17":                    17,
+		"go1.22rc1":                 22,
+		"devel go1.23-abcdef +0000": 23,
+		"not a version":             -1,
+	}
+	for version, want := range cases {
+		got, ok := goMinorVersion(version)
+		if !ok {
+			got = -1
+		}
+		if got != want {
+			t.Fatalf("goMinorVersion(%q) = %d, want %d", version, got, want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package collector
+
+// Hook identifies the syscall or library function an event was captured
+// from. Values match the HOOK_* constants in bpf/tracker.c.
+type Hook uint8
+
+const (
+	HookUnknown  Hook = 0
+	HookWrite    Hook = 1
+	HookSendto   Hook = 2
+	HookWritev   Hook = 3
+	HookSendmsg  Hook = 4
+	HookSendmmsg Hook = 5
+	HookRead     Hook = 6
+	HookRecvfrom Hook = 7
+	HookReadv    Hook = 8
+	HookRecvmsg  Hook = 9
+	HookRecvmmsg Hook = 10
+	HookSSL      Hook = 11
+	HookGoTLS    Hook = 12
+	// HookPcap marks events read from a packet capture rather than a probe.
+	HookPcap     Hook = 13
+	HookClose    Hook = 14
+	HookShutdown Hook = 15
+
+	numHooks = 16
+)
+
+var hookNames = [numHooks]string{
+	HookUnknown:  "unknown",
+	HookWrite:    "write",
+	HookSendto:   "sendto",
+	HookWritev:   "writev",
+	HookSendmsg:  "sendmsg",
+	HookSendmmsg: "sendmmsg",
+	HookRead:     "read",
+	HookRecvfrom: "recvfrom",
+	HookReadv:    "readv",
+	HookRecvmsg:  "recvmsg",
+	HookRecvmmsg: "recvmmsg",
+	HookSSL:      "ssl",
+	HookGoTLS:    "go_tls",
+	HookPcap:     "pcap",
+	HookClose:    "close",
+	HookShutdown: "shutdown",
+}
+
+func (h Hook) String() string {
+	if int(h) < len(hookNames) {
+		return hookNames[h]
+	}
+	return hookNames[HookUnknown]
+}
@@ -0,0 +1,310 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+const (
+	pcapMagicMicros = 0xa1b2c3d4
+	pcapMagicNanos  = 0xa1b23c4d
+
+	pcapngSectionHeader   = 0x0a0d0d0a
+	pcapngInterface       = 0x00000001
+	pcapngSimplePacket    = 0x00000003
+	pcapngEnhancedPacket  = 0x00000006
+	pcapngByteOrderMagic  = 0x1a2b3c4d
+	pcapngOptionEnd       = 0
+	pcapngOptionTSResol   = 9
+	pcapMaxBlockSize      = 64 << 20
+	pcapDefaultResolution = 6
+)
+
+// PcapReplay is a Source that reads a pcap or pcapng capture, reassembles its
+// TCP streams and emits the payloads as events. Captures carry no process
+// information, so events have pid 0 and one synthetic fd per TCP flow, and
+// are reported from the point of view of the side that opened the
+// connection.
+type PcapReplay struct {
+	file   *os.File
+	reader packetReader
+	speed  float64
+	idle   time.Duration
+}
+
+type packet struct {
+	ts       time.Time
+	linkType uint32
+	data     []byte
+}
+
+type packetReader interface {
+	next() (packet, error)
+}
+
+// NewPcapReplay opens a capture. Flows that see no packets for idle, in
+// capture time, are flushed and forgotten; zero keeps them until they close.
+func NewPcapReplay(path string, speed float64, idle time.Duration) (*PcapReplay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open capture: %w", err)
+	}
+	reader, err := newPacketReader(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &PcapReplay{file: file, reader: reader, speed: speed, idle: idle}, nil
+}
+
+func (p *PcapReplay) Run(ctx context.Context, handler func(Event)) error {
+	pace := pacer{speed: p.speed}
+	flows := newFlowTable(p.idle)
+
+	for {
+		pkt, err := p.reader.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			flows.flush(handler)
+			return err
+		}
+		seg, ok := decodeTCP(pkt.linkType, pkt.data)
+		if !ok {
+			continue
+		}
+		if !pace.wait(ctx, pkt.ts) {
+			return nil
+		}
+		flows.add(pkt.ts, seg, handler)
+	}
+	flows.flush(handler)
+	return nil
+}
+
+func (p *PcapReplay) Close() {
+	p.file.Close()
+}
+
+func newPacketReader(r *bufio.Reader) (packetReader, error) {
+	head, err := r.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("read capture header: %w", err)
+	}
+	if binary.LittleEndian.Uint32(head) == pcapngSectionHeader {
+		return &pcapngReader{r: r}, nil
+	}
+
+	var hdr [24]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("read capture header: %w", err)
+	}
+	var order binary.ByteOrder
+	var nanos bool
+	switch {
+	case binary.LittleEndian.Uint32(hdr[:]) == pcapMagicMicros:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr[:]) == pcapMagicMicros:
+		order = binary.BigEndian
+	case binary.LittleEndian.Uint32(hdr[:]) == pcapMagicNanos:
+		order, nanos = binary.LittleEndian, true
+	case binary.BigEndian.Uint32(hdr[:]) == pcapMagicNanos:
+		order, nanos = binary.BigEndian, true
+	default:
+		return nil, fmt.Errorf("not a pcap or pcapng file")
+	}
+	return &pcapReader{
+		r:        r,
+		order:    order,
+		nanos:    nanos,
+		linkType: order.Uint32(hdr[20:]) & 0x0fffffff,
+	}, nil
+}
+
+type pcapReader struct {
+	r        *bufio.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	linkType uint32
+}
+
+func (p *pcapReader) next() (packet, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(p.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return packet{}, fmt.Errorf("truncated pcap record header: %w", err)
+		}
+		return packet{}, err
+	}
+	sec := int64(p.order.Uint32(hdr[0:]))
+	frac := int64(p.order.Uint32(hdr[4:]))
+	capLen := p.order.Uint32(hdr[8:])
+	if capLen > pcapMaxBlockSize {
+		return packet{}, fmt.Errorf("pcap record of %d bytes", capLen)
+	}
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return packet{}, fmt.Errorf("truncated pcap record: %w", unexpectedEOF(err))
+	}
+	if !p.nanos {
+		frac *= int64(time.Microsecond)
+	}
+	return packet{ts: time.Unix(sec, frac).UTC(), linkType: p.linkType, data: data}, nil
+}
+
+type pcapngInterfaceInfo struct {
+	linkType uint32
+	// tsResol is the raw if_tsresol option: a negative power of ten, or of
+	// two when the high bit is set.
+	tsResol byte
+}
+
+type pcapngReader struct {
+	r          *bufio.Reader
+	order      binary.ByteOrder
+	interfaces []pcapngInterfaceInfo
+}
+
+func (p *pcapngReader) next() (packet, error) {
+	for {
+		blockType, body, err := p.block()
+		if err != nil {
+			return packet{}, err
+		}
+		switch blockType {
+		case pcapngSectionHeader:
+			p.interfaces = p.interfaces[:0]
+		case pcapngInterface:
+			if len(body) < 8 {
+				return packet{}, fmt.Errorf("short pcapng interface block")
+			}
+			p.interfaces = append(p.interfaces, pcapngInterfaceInfo{
+				linkType: uint32(p.order.Uint16(body)),
+				tsResol:  p.tsResolution(body[8:]),
+			})
+		case pcapngEnhancedPacket:
+			if len(body) < 20 {
+				return packet{}, fmt.Errorf("short pcapng packet block")
+			}
+			id := p.order.Uint32(body)
+			if int(id) >= len(p.interfaces) {
+				continue
+			}
+			iface := p.interfaces[id]
+			ticks := uint64(p.order.Uint32(body[4:]))<<32 | uint64(p.order.Uint32(body[8:]))
+			capLen := p.order.Uint32(body[12:])
+			if int(capLen) > len(body)-20 {
+				capLen = uint32(len(body) - 20)
+			}
+			return packet{ts: ticksToTime(ticks, iface.tsResol), linkType: iface.linkType, data: body[20 : 20+capLen]}, nil
+		case pcapngSimplePacket:
+			if len(body) < 4 || len(p.interfaces) == 0 {
+				continue
+			}
+			capLen := p.order.Uint32(body)
+			if int(capLen) > len(body)-4 {
+				capLen = uint32(len(body) - 4)
+			}
+			return packet{linkType: p.interfaces[0].linkType, data: body[4 : 4+capLen]}, nil
+		}
+	}
+}
+
+// block reads one pcapng block and returns its type and body, the bytes
+// between the length fields. Section headers also set the byte order.
+func (p *pcapngReader) block() (uint32, []byte, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(p.r, hdr[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, fmt.Errorf("truncated pcapng block header: %w", err)
+		}
+		return 0, nil, err
+	}
+	blockType := binary.LittleEndian.Uint32(hdr[:])
+	if blockType == pcapngSectionHeader {
+		magic, err := p.r.Peek(4)
+		if err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header: %w", unexpectedEOF(err))
+		}
+		switch {
+		case binary.LittleEndian.Uint32(magic) == pcapngByteOrderMagic:
+			p.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(magic) == pcapngByteOrderMagic:
+			p.order = binary.BigEndian
+		default:
+			return 0, nil, fmt.Errorf("bad pcapng byte order magic")
+		}
+	} else if p.order == nil {
+		return 0, nil, fmt.Errorf("pcapng block before section header")
+	} else {
+		blockType = p.order.Uint32(hdr[:])
+	}
+
+	total := p.order.Uint32(hdr[4:])
+	if total < 12 || total > pcapMaxBlockSize {
+		return 0, nil, fmt.Errorf("bad pcapng block length %d", total)
+	}
+	rest := make([]byte, total-8)
+	if _, err := io.ReadFull(p.r, rest); err != nil {
+		return 0, nil, fmt.Errorf("truncated pcapng block: %w", unexpectedEOF(err))
+	}
+	return blockType, rest[:len(rest)-4], nil
+}
+
+// unexpectedEOF reports running out of input partway through a record as
+// io.ErrUnexpectedEOF so it is not mistaken for the end of the capture.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// tsResolution returns the if_tsresol option of an interface block.
+func (p *pcapngReader) tsResolution(options []byte) byte {
+	resolution := byte(pcapDefaultResolution)
+	for len(options) >= 4 {
+		code := p.order.Uint16(options)
+		size := int(p.order.Uint16(options[2:]))
+		if code == pcapngOptionEnd || 4+size > len(options) {
+			break
+		}
+		if code == pcapngOptionTSResol && size >= 1 {
+			resolution = options[4]
+		}
+		step := 4 + (size+3)&^3
+		if step > len(options) {
+			break
+		}
+		options = options[step:]
+	}
+	return resolution
+}
+
+func ticksToTime(ticks uint64, resolution byte) time.Time {
+	if resolution&0x80 != 0 {
+		unit := math.Pow(2, -float64(resolution&0x7f))
+		return time.Unix(0, int64(float64(ticks)*unit*float64(time.Second))).UTC()
+	}
+	exp := int(resolution)
+	perSecond := uint64(math.Pow10(exp))
+	if exp > 19 || perSecond == 0 {
+		return time.Time{}
+	}
+	sec := ticks / perSecond
+	frac := ticks % perSecond
+	if exp <= 9 {
+		frac *= uint64(math.Pow10(9 - exp))
+	} else {
+		frac /= uint64(math.Pow10(exp - 9))
+	}
+	return time.Unix(int64(sec), int64(frac)).UTC()
+}
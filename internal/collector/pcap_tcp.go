@@ -0,0 +1,411 @@
+package collector
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+const (
+	linkTypeNull     = 0
+	linkTypeEthernet = 1
+	linkTypeRaw      = 101
+	linkTypeRawAlt   = 12
+	linkTypeIPv4     = 228
+	linkTypeIPv6     = 229
+	linkTypeLinuxSLL = 113
+	linkTypeSLL2     = 276
+
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86dd
+	etherTypeVLAN = 0x8100
+	etherTypeQinQ = 0x88a8
+
+	ipProtoTCP = 6
+
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpRST = 0x04
+	tcpACK = 0x10
+
+	// Out-of-order segments buffered per direction before the missing bytes
+	// are given up on and the stream skips ahead.
+	maxPendingSegments = 64
+)
+
+type tcpSegment struct {
+	src, dst netip.AddrPort
+	seq      uint32
+	flags    uint8
+	payload  []byte
+}
+
+// decodeTCP extracts the TCP segment from a captured frame. Fragmented IP
+// packets are skipped.
+func decodeTCP(linkType uint32, frame []byte) (tcpSegment, bool) {
+	var etherType uint16
+	switch linkType {
+	case linkTypeEthernet:
+		if len(frame) < 14 {
+			return tcpSegment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(frame[12:])
+		frame = frame[14:]
+		for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+			if len(frame) < 4 {
+				return tcpSegment{}, false
+			}
+			etherType = binary.BigEndian.Uint16(frame[2:])
+			frame = frame[4:]
+		}
+	case linkTypeLinuxSLL:
+		if len(frame) < 16 {
+			return tcpSegment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(frame[14:])
+		frame = frame[16:]
+	case linkTypeSLL2:
+		if len(frame) < 20 {
+			return tcpSegment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(frame)
+		frame = frame[20:]
+	case linkTypeNull:
+		if len(frame) < 4 {
+			return tcpSegment{}, false
+		}
+		// The family is in host byte order of the capturing machine; AF_INET
+		// is 2 everywhere, AF_INET6 varies between BSDs and Linux.
+		family := binary.LittleEndian.Uint32(frame)
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(frame)
+		}
+		frame = frame[4:]
+		if family == 2 {
+			etherType = etherTypeIPv4
+		} else {
+			etherType = etherTypeIPv6
+		}
+	case linkTypeRaw, linkTypeRawAlt, linkTypeIPv4, linkTypeIPv6:
+		if len(frame) == 0 {
+			return tcpSegment{}, false
+		}
+		if frame[0]>>4 == 6 {
+			etherType = etherTypeIPv6
+		} else {
+			etherType = etherTypeIPv4
+		}
+	default:
+		return tcpSegment{}, false
+	}
+
+	var src, dst netip.Addr
+	var transport []byte
+	switch etherType {
+	case etherTypeIPv4:
+		if len(frame) < 20 || frame[0]>>4 != 4 {
+			return tcpSegment{}, false
+		}
+		headerLen := int(frame[0]&0x0f) * 4
+		totalLen := int(binary.BigEndian.Uint16(frame[2:]))
+		fragment := binary.BigEndian.Uint16(frame[6:])
+		if frame[9] != ipProtoTCP || fragment&0x3fff != 0 || headerLen < 20 || totalLen < headerLen {
+			return tcpSegment{}, false
+		}
+		if totalLen > len(frame) {
+			totalLen = len(frame)
+		}
+		if headerLen > totalLen {
+			return tcpSegment{}, false
+		}
+		src = netip.AddrFrom4([4]byte(frame[12:16]))
+		dst = netip.AddrFrom4([4]byte(frame[16:20]))
+		transport = frame[headerLen:totalLen]
+	case etherTypeIPv6:
+		if len(frame) < 40 || frame[0]>>4 != 6 {
+			return tcpSegment{}, false
+		}
+		payloadLen := int(binary.BigEndian.Uint16(frame[4:]))
+		next := frame[6]
+		src = netip.AddrFrom16([16]byte(frame[8:24]))
+		dst = netip.AddrFrom16([16]byte(frame[24:40]))
+		transport = frame[40:]
+		if payloadLen < len(transport) {
+			transport = transport[:payloadLen]
+		}
+		// Hop-by-hop, routing and destination options headers.
+		for next == 0 || next == 43 || next == 60 {
+			if len(transport) < 8 {
+				return tcpSegment{}, false
+			}
+			size := (int(transport[1]) + 1) * 8
+			if size > len(transport) {
+				return tcpSegment{}, false
+			}
+			next = transport[0]
+			transport = transport[size:]
+		}
+		if next != ipProtoTCP {
+			return tcpSegment{}, false
+		}
+	default:
+		return tcpSegment{}, false
+	}
+
+	if len(transport) < 20 {
+		return tcpSegment{}, false
+	}
+	dataOffset := int(transport[12]>>4) * 4
+	if dataOffset < 20 || dataOffset > len(transport) {
+		return tcpSegment{}, false
+	}
+	return tcpSegment{
+		src:     netip.AddrPortFrom(src, binary.BigEndian.Uint16(transport[0:])),
+		dst:     netip.AddrPortFrom(dst, binary.BigEndian.Uint16(transport[2:])),
+		seq:     binary.BigEndian.Uint32(transport[4:]),
+		flags:   transport[13],
+		payload: transport[dataOffset:],
+	}, true
+}
+
+type flowKey struct {
+	a, b netip.AddrPort
+}
+
+// tcpStream reassembles one direction of a flow.
+type tcpStream struct {
+	started bool
+	next    uint32
+	pending map[uint32][]byte
+}
+
+type tcpFlow struct {
+	fd     int32
+	client netip.AddrPort
+	server netip.AddrPort
+	// Index 0 carries client to server bytes, index 1 the replies.
+	streams [2]tcpStream
+	fin     [2]bool
+	proto   connProtocol
+	last    time.Time
+}
+
+type flowTable struct {
+	flows  map[flowKey]*tcpFlow
+	nextFd int32
+	idle   time.Duration
+	swept  time.Time
+}
+
+func newFlowTable(idle time.Duration) *flowTable {
+	return &flowTable{flows: make(map[flowKey]*tcpFlow), idle: idle}
+}
+
+func makeFlowKey(x, y netip.AddrPort) flowKey {
+	if x.Addr().Less(y.Addr()) || (x.Addr() == y.Addr() && x.Port() < y.Port()) {
+		return flowKey{x, y}
+	}
+	return flowKey{y, x}
+}
+
+func (t *flowTable) add(ts time.Time, seg tcpSegment, handler func(Event)) {
+	t.expire(ts, handler)
+
+	key := makeFlowKey(seg.src, seg.dst)
+	flow, ok := t.flows[key]
+	if !ok {
+		if seg.flags&(tcpFIN|tcpRST) != 0 && len(seg.payload) == 0 {
+			return
+		}
+		t.nextFd++
+		flow = &tcpFlow{fd: t.nextFd, client: seg.src, server: seg.dst}
+		// A lone SYN-ACK means the handshake was seen from the server side.
+		if seg.flags&tcpSYN != 0 && seg.flags&tcpACK != 0 {
+			flow.client, flow.server = seg.dst, seg.src
+		}
+		t.flows[key] = flow
+	}
+	flow.last = ts
+
+	side := 0
+	if seg.src != flow.client {
+		side = 1
+	}
+	stream := &flow.streams[side]
+	if seg.flags&tcpSYN != 0 {
+		stream.started = true
+		stream.next = seg.seq + 1
+	}
+	if len(seg.payload) > 0 {
+		if !stream.started {
+			stream.started = true
+			stream.next = seg.seq
+		}
+		stream.push(seg.seq, seg.payload, func(data []byte) {
+			handler(flow.event(ts, side, data))
+		})
+	}
+
+	if seg.flags&tcpFIN != 0 {
+		flow.fin[side] = true
+	}
+	if seg.flags&tcpRST != 0 || (flow.fin[0] && flow.fin[1]) {
+		flow.close(ts, handler)
+		delete(t.flows, key)
+	}
+}
+
+// expire closes and forgets flows idle for longer than the table's timeout.
+// Captures rarely see every connection close, so without this the table
+// would grow for as long as the capture runs. Sweeps happen at most once per
+// timeout.
+func (t *flowTable) expire(now time.Time, handler func(Event)) {
+	if t.idle <= 0 || now.Sub(t.swept) < t.idle {
+		return
+	}
+	t.swept = now
+
+	var flows []*tcpFlow
+	for key, flow := range t.flows {
+		if now.Sub(flow.last) > t.idle {
+			flows = append(flows, flow)
+			delete(t.flows, key)
+		}
+	}
+	sortFlows(flows)
+	for _, flow := range flows {
+		flow.close(flow.last, handler)
+	}
+}
+
+// flush emits whatever is still buffered once the capture ends.
+func (t *flowTable) flush(handler func(Event)) {
+	flows := make([]*tcpFlow, 0, len(t.flows))
+	for _, flow := range t.flows {
+		flows = append(flows, flow)
+	}
+	sortFlows(flows)
+	for _, flow := range flows {
+		flow.drain(flow.last, handler)
+	}
+	t.flows = make(map[flowKey]*tcpFlow)
+}
+
+func sortFlows(flows []*tcpFlow) {
+	sort.Slice(flows, func(i, j int) bool { return flows[i].fd < flows[j].fd })
+}
+
+func (f *tcpFlow) drain(ts time.Time, handler func(Event)) {
+	for side := range f.streams {
+		f.streams[side].skipGaps(func(data []byte) {
+			handler(f.event(ts, side, data))
+		})
+	}
+}
+
+// close drains the flow and reports the end of its connection, so that the
+// requests still waiting on its fd are settled.
+func (f *tcpFlow) close(ts time.Time, handler func(Event)) {
+	f.drain(ts, handler)
+	handler(Event{
+		Timestamp: ts,
+		Fd:        f.fd,
+		Direction: DirectionClose,
+		Hook:      HookPcap,
+		Local:     f.client,
+		Remote:    f.server,
+	})
+}
+
+func (f *tcpFlow) event(ts time.Time, side int, data []byte) Event {
+	ev := Event{
+		Timestamp: ts,
+		Fd:        f.fd,
+		Ingress:   side == 1,
+		Hook:      HookPcap,
+		Local:     f.client,
+		Remote:    f.server,
+		Data:      data,
+		Length:    uint32(len(data)),
+	}
+	ev.Direction, ev.Protocol = f.proto.classify(data, side)
+	if ev.Direction == DirectionUnknown {
+		// Unrecognised payloads keep the opener-sends-requests guess.
+		if side == 0 {
+			ev.Direction = DirectionRequest
+		} else {
+			ev.Direction = DirectionResponse
+		}
+	}
+	return ev
+}
+
+// push delivers data that continues the stream, buffering segments that
+// arrive ahead of a gap and trimming retransmitted bytes.
+func (s *tcpStream) push(seq uint32, data []byte, emit func([]byte)) {
+	offset := int32(s.next - seq)
+	if offset > 0 {
+		if int(offset) >= len(data) {
+			return
+		}
+		data = data[offset:]
+		seq = s.next
+	}
+	if seq != s.next {
+		if s.pending == nil {
+			s.pending = make(map[uint32][]byte)
+		}
+		if _, ok := s.pending[seq]; !ok {
+			s.pending[seq] = append([]byte(nil), data...)
+		}
+		if len(s.pending) > maxPendingSegments {
+			s.skipGaps(emit)
+		}
+		return
+	}
+
+	emit(append([]byte(nil), data...))
+	s.next += uint32(len(data))
+	s.drainPending(emit)
+}
+
+func (s *tcpStream) drainPending(emit func([]byte)) {
+	for len(s.pending) > 0 {
+		progressed := false
+		for seq, data := range s.pending {
+			offset := int32(s.next - seq)
+			if offset < 0 {
+				continue
+			}
+			delete(s.pending, seq)
+			progressed = true
+			if int(offset) < len(data) {
+				emit(data[offset:])
+				s.next += uint32(len(data) - int(offset))
+			}
+		}
+		if !progressed {
+			return
+		}
+	}
+	s.pending = nil
+}
+
+// skipGaps gives up on missing bytes and emits the buffered segments in
+// sequence order.
+func (s *tcpStream) skipGaps(emit func([]byte)) {
+	for len(s.pending) > 0 {
+		first := true
+		var lowest uint32
+		for seq := range s.pending {
+			if first || int32(seq-lowest) < 0 {
+				lowest = seq
+				first = false
+			}
+		}
+		s.next = lowest
+		s.drainPending(emit)
+	}
+	s.pending = nil
+}
@@ -0,0 +1,261 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testFrame struct {
+	ts      time.Time
+	src     netip.AddrPort
+	dst     netip.AddrPort
+	seq     uint32
+	flags   uint8
+	payload string
+}
+
+func ethernetFrame(f testFrame) []byte {
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:], f.src.Port())
+	binary.BigEndian.PutUint16(tcp[2:], f.dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:], f.seq)
+	tcp[12] = 5 << 4
+	tcp[13] = f.flags
+	tcp = append(tcp, f.payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)))
+	ip[8] = 64
+	ip[9] = ipProtoTCP
+	src, dst := f.src.Addr().As4(), f.dst.Addr().As4()
+	copy(ip[12:], src[:])
+	copy(ip[16:], dst[:])
+
+	eth := make([]byte, 14)
+	binary.BigEndian.PutUint16(eth[12:], etherTypeIPv4)
+	return append(append(eth, ip...), tcp...)
+}
+
+func writePcap(t *testing.T, frames []testFrame) string {
+	t.Helper()
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], pcapMagicMicros)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], 65535)
+	binary.LittleEndian.PutUint32(hdr[20:], linkTypeEthernet)
+
+	out := hdr
+	for _, f := range frames {
+		frame := ethernetFrame(f)
+		rec := make([]byte, 16)
+		binary.LittleEndian.PutUint32(rec[0:], uint32(f.ts.Unix()))
+		binary.LittleEndian.PutUint32(rec[4:], uint32(f.ts.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(rec[8:], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(rec[12:], uint32(len(frame)))
+		out = append(append(out, rec...), frame...)
+	}
+
+	path := filepath.Join(t.TempDir(), "capture.pcap")
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		t.Fatalf("write pcap: %v", err)
+	}
+	return path
+}
+
+func TestPcapReplayReassembles(t *testing.T) {
+	client := netip.MustParseAddrPort("10.0.0.1:40000")
+	server := netip.MustParseAddrPort("10.0.0.2:80")
+	base := time.Unix(1700000000, 0).UTC()
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+
+	path := writePcap(t, []testFrame{
+		{ts: at(0), src: client, dst: server, seq: 99, flags: tcpSYN},
+		{ts: at(1), src: server, dst: client, seq: 499, flags: tcpSYN | tcpACK},
+		// The second half of the request arrives first and a retransmission
+		// of the first half follows; both must be folded into order.
+		{ts: at(2), src: client, dst: server, seq: 109, flags: tcpACK, payload: "TP/1.1\r\n\r\n"},
+		{ts: at(3), src: client, dst: server, seq: 100, flags: tcpACK, payload: "GET /a HT"},
+		{ts: at(4), src: client, dst: server, seq: 100, flags: tcpACK, payload: "GET /a HT"},
+		{ts: at(10), src: server, dst: client, seq: 500, flags: tcpACK, payload: "HTTP/1.1 200 OK\r\n\r\n"},
+		{ts: at(11), src: client, dst: server, seq: 119, flags: tcpFIN | tcpACK},
+		{ts: at(12), src: server, dst: client, seq: 519, flags: tcpFIN | tcpACK},
+	})
+
+	replay, err := NewPcapReplay(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewPcapReplay: %v", err)
+	}
+	defer replay.Close()
+
+	var events []Event
+	if err := replay.Run(context.Background(), func(ev Event) { events = append(events, ev) }); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := []struct {
+		data      string
+		direction Direction
+		ingress   bool
+	}{
+		{"GET /a HT", DirectionRequest, false},
+		{"TP/1.1\r\n\r\n", DirectionRequest, false},
+		{"HTTP/1.1 200 OK\r\n\r\n", DirectionResponse, true},
+		{"", DirectionClose, false},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		ev := events[i]
+		if string(ev.Data) != w.data || ev.Ingress != w.ingress {
+			t.Fatalf("event %d = %q ingress=%v, want %q ingress=%v", i, ev.Data, ev.Ingress, w.data, w.ingress)
+		}
+		if ev.Direction != w.direction {
+			t.Fatalf("event %d direction = %v, want %v", i, ev.Direction, w.direction)
+		}
+		if ev.Pid != 0 || ev.Fd != 1 || ev.Local != client || ev.Remote != server || ev.Hook != HookPcap {
+			t.Fatalf("event %d has unexpected identity %+v", i, ev)
+		}
+	}
+	if !events[2].Timestamp.Equal(at(10)) {
+		t.Fatalf("response timestamp = %v, want %v", events[2].Timestamp, at(10))
+	}
+	if events[2].Role() != RoleClient {
+		t.Fatalf("role = %v, want client", events[2].Role())
+	}
+}
+
+func TestPcapngReader(t *testing.T) {
+	client := netip.MustParseAddrPort("10.0.0.1:40000")
+	server := netip.MustParseAddrPort("10.0.0.2:80")
+	frame := ethernetFrame(testFrame{src: client, dst: server, seq: 1, flags: tcpACK, payload: "GET / HTTP/1.1\r\n\r\n"})
+
+	block := func(blockType uint32, body []byte) []byte {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		total := uint32(12 + len(body))
+		b := binary.LittleEndian.AppendUint32(nil, blockType)
+		b = binary.LittleEndian.AppendUint32(b, total)
+		b = append(b, body...)
+		return binary.LittleEndian.AppendUint32(b, total)
+	}
+
+	shb := binary.LittleEndian.AppendUint32(nil, pcapngByteOrderMagic)
+	shb = binary.LittleEndian.AppendUint16(shb, 1)
+	shb = binary.LittleEndian.AppendUint16(shb, 0)
+	shb = binary.LittleEndian.AppendUint64(shb, ^uint64(0))
+
+	idb := binary.LittleEndian.AppendUint16(nil, linkTypeEthernet)
+	idb = binary.LittleEndian.AppendUint16(idb, 0)
+	idb = binary.LittleEndian.AppendUint32(idb, 0)
+	// if_tsresol = 9 (nanoseconds), then opt_endofopt.
+	idb = append(idb, pcapngOptionTSResol, 0, 1, 0, 9, 0, 0, 0, 0, 0, 0, 0)
+
+	ts := uint64(1700000000123456789)
+	epb := binary.LittleEndian.AppendUint32(nil, 0)
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(frame)))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(frame)))
+	epb = append(epb, frame...)
+
+	var data []byte
+	data = append(data, block(pcapngSectionHeader, shb)...)
+	data = append(data, block(pcapngInterface, idb)...)
+	data = append(data, block(pcapngEnhancedPacket, epb)...)
+
+	path := filepath.Join(t.TempDir(), "capture.pcapng")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write pcapng: %v", err)
+	}
+
+	replay, err := NewPcapReplay(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewPcapReplay: %v", err)
+	}
+	defer replay.Close()
+
+	var events []Event
+	if err := replay.Run(context.Background(), func(ev Event) { events = append(events, ev) }); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(events) != 1 || events[0].Direction != DirectionRequest {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if got := events[0].Timestamp.UnixNano(); got != int64(ts) {
+		t.Fatalf("timestamp = %d, want %d", got, ts)
+	}
+}
+
+func TestPcapReplayTruncated(t *testing.T) {
+	client := netip.MustParseAddrPort("10.0.0.1:40000")
+	server := netip.MustParseAddrPort("10.0.0.2:80")
+	path := writePcap(t, []testFrame{
+		{src: client, dst: server, seq: 1, flags: tcpACK, payload: "GET / HTTP/1.1\r\n\r\n"},
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read pcap: %v", err)
+	}
+	if err := os.WriteFile(path, data[:len(data)-5], 0o644); err != nil {
+		t.Fatalf("write pcap: %v", err)
+	}
+
+	replay, err := NewPcapReplay(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewPcapReplay: %v", err)
+	}
+	defer replay.Close()
+
+	err = replay.Run(context.Background(), func(Event) {})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Run error = %v, want unexpected EOF", err)
+	}
+}
+
+func TestPcapReplayExpiresIdleFlows(t *testing.T) {
+	first := netip.MustParseAddrPort("10.0.0.1:40000")
+	second := netip.MustParseAddrPort("10.0.0.1:40001")
+	server := netip.MustParseAddrPort("10.0.0.2:80")
+	base := time.Unix(1700000000, 0).UTC()
+
+	path := writePcap(t, []testFrame{
+		{ts: base, src: first, dst: server, seq: 99, flags: tcpSYN},
+		// Bytes 100-108 never arrive, so this segment waits for the gap.
+		{ts: base, src: first, dst: server, seq: 109, flags: tcpACK, payload: "GET /a HTTP/1.1\r\n\r\n"},
+		{ts: base.Add(2 * time.Minute), src: second, dst: server, seq: 1, flags: tcpACK, payload: "GET /b HTTP/1.1\r\n\r\n"},
+	})
+
+	replay, err := NewPcapReplay(path, 0, time.Minute)
+	if err != nil {
+		t.Fatalf("NewPcapReplay: %v", err)
+	}
+	defer replay.Close()
+
+	var events []Event
+	if err := replay.Run(context.Background(), func(ev Event) { events = append(events, ev) }); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3: %+v", len(events), events)
+	}
+	if events[0].Fd != 1 || !events[0].Timestamp.Equal(base) {
+		t.Fatalf("idle flow was not drained first: %+v", events[0])
+	}
+	if events[1].Fd != 1 || events[1].Direction != DirectionClose {
+		t.Fatalf("idle flow was not closed: %+v", events[1])
+	}
+	if events[2].Fd != 2 {
+		t.Fatalf("third event fd = %d, want 2", events[2].Fd)
+	}
+}
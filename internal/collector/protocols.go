@@ -0,0 +1,226 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Protocol is the application protocol of an event. Values match the PROTO_*
+// constants in bpf/tracker.c.
+type Protocol uint8
+
+const (
+	ProtocolUnknown   Protocol = 0
+	ProtocolHTTP      Protocol = 1
+	ProtocolHTTP2     Protocol = 2
+	ProtocolRedis     Protocol = 3
+	ProtocolPostgres  Protocol = 4
+	ProtocolMySQL     Protocol = 5
+	ProtocolKafka     Protocol = 6
+	ProtocolDNS       Protocol = 7
+	ProtocolMongo     Protocol = 8
+	ProtocolMemcached Protocol = 9
+)
+
+var protocolNames = map[Protocol]string{
+	ProtocolHTTP:      "http",
+	ProtocolHTTP2:     "http2",
+	ProtocolRedis:     "redis",
+	ProtocolPostgres:  "postgres",
+	ProtocolMySQL:     "mysql",
+	ProtocolKafka:     "kafka",
+	ProtocolDNS:       "dns",
+	ProtocolMongo:     "mongodb",
+	ProtocolMemcached: "memcached",
+}
+
+func (p Protocol) String() string {
+	if name, ok := protocolNames[p]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+var http2Preface = []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("POST"), []byte("PUT "), []byte("DELE"),
+	[]byte("PATC"), []byte("HEAD"), []byte("OPTI"),
+}
+
+// connProtocol mirrors the per-connection classification done by classify in
+// bpf/tracker.c for sources that see raw streams, such as packet captures.
+type connProtocol struct {
+	protocol Protocol
+	// requestSide is the side of the connection that sent the first
+	// request; 0 is the connection opener.
+	requestSide int
+}
+
+func (c *connProtocol) classify(data []byte, side int) (Direction, Protocol) {
+	switch {
+	case hasHTTPMethod(data):
+		c.protocol = ProtocolUnknown
+		return DirectionRequest, ProtocolHTTP
+	case bytes.HasPrefix(data, []byte("HTTP/")):
+		return DirectionResponse, ProtocolHTTP
+	}
+
+	if c.protocol == ProtocolUnknown {
+		c.protocol = detectProtocol(data)
+		c.requestSide = side
+		if c.protocol == ProtocolUnknown {
+			return DirectionUnknown, ProtocolUnknown
+		}
+	}
+	if side == c.requestSide {
+		return DirectionRequest, c.protocol
+	}
+	return DirectionResponse, c.protocol
+}
+
+// detectProtocol mirrors detect_protocol in bpf/tracker.c.
+func detectProtocol(data []byte) Protocol {
+	if bytes.HasPrefix(data, http2Preface[:12]) && len(data) >= len(http2Preface) {
+		return ProtocolHTTP2
+	}
+	if isMongoRequest(data) {
+		return ProtocolMongo
+	}
+	if isRESPCommand(data) {
+		return ProtocolRedis
+	}
+	if isPostgresFrontend(data) {
+		return ProtocolPostgres
+	}
+	if isMySQLCommand(data) {
+		return ProtocolMySQL
+	}
+	if isMemcachedText(data) || isMemcachedBinary(data) {
+		return ProtocolMemcached
+	}
+	if isKafkaRequest(data) {
+		return ProtocolKafka
+	}
+	return ProtocolUnknown
+}
+
+func isMongoRequest(data []byte) bool {
+	if len(data) < 16 {
+		return false
+	}
+	length := binary.LittleEndian.Uint32(data)
+	if length < 21 || length > 48000000 || binary.LittleEndian.Uint32(data[8:]) != 0 {
+		return false
+	}
+	op := binary.LittleEndian.Uint32(data[12:])
+	return op == 2013 || op == 2004
+}
+
+func isRESPCommand(data []byte) bool {
+	if len(data) < 6 || data[0] != '*' || !isDigit(data[1]) {
+		return false
+	}
+	if data[2] == '\r' {
+		return data[3] == '\n' && data[4] == '$'
+	}
+	return isDigit(data[2]) && data[3] == '\r' && data[4] == '\n' && data[5] == '$'
+}
+
+func isPostgresFrontend(data []byte) bool {
+	if len(data) < 8 {
+		return false
+	}
+	if data[0] == 0 && data[1] == 0 && binary.BigEndian.Uint32(data[4:]) == 3<<16 {
+		length := binary.BigEndian.Uint32(data)
+		return length >= 8 && length <= 10000
+	}
+	if data[0] != 'Q' && data[0] != 'P' && data[0] != 'B' {
+		return false
+	}
+	length := binary.BigEndian.Uint32(data[1:])
+	if length < 8 || length > 1<<24 {
+		return false
+	}
+	if data[0] == 'Q' {
+		c := data[5] | 0x20
+		return (c >= 'a' && c <= 'z') || data[5] == '(' || data[5] == ' '
+	}
+	return true
+}
+
+func isMySQLCommand(data []byte) bool {
+	if len(data) < 6 {
+		return false
+	}
+	length := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+	if data[3] != 0 || length < 2 || length+4 > len(data) {
+		return false
+	}
+	switch data[4] {
+	case 0x03, 0x16:
+		c := data[5] | 0x20
+		return (c >= 'a' && c <= 'z') || data[5] == '(' || data[5] == '/' || data[5] == ' '
+	case 0x17:
+		return length >= 10 && binary.LittleEndian.Uint32(data[10:]) == 1
+	}
+	return false
+}
+
+var memcachedCommands = [][]byte{
+	[]byte("get "), []byte("gets "), []byte("gat "), []byte("gats "),
+	[]byte("set "), []byte("add "), []byte("cas "), []byte("delete "),
+	[]byte("incr "), []byte("decr "), []byte("touch "), []byte("replace "),
+	[]byte("append "), []byte("prepend "),
+}
+
+func isMemcachedText(data []byte) bool {
+	if len(data) < 6 {
+		return false
+	}
+	for _, cmd := range memcachedCommands {
+		if bytes.HasPrefix(data, cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMemcachedBinary(data []byte) bool {
+	if len(data) < 24 || data[0] != 0x80 || data[1] > 0x1e || data[5] != 0 {
+		return false
+	}
+	keyLen := uint32(binary.BigEndian.Uint16(data[2:]))
+	return binary.BigEndian.Uint32(data[8:]) >= keyLen+uint32(data[4])
+}
+
+func isKafkaRequest(data []byte) bool {
+	if len(data) < 14 {
+		return false
+	}
+	length := binary.BigEndian.Uint32(data)
+	if length < 10 || length >= 1<<24 {
+		return false
+	}
+	header := data[4:]
+	if header[0] != 0 || header[1] > 80 || header[2] != 0 || header[3] > 20 {
+		return false
+	}
+	if header[8] == 0xff {
+		return header[9] == 0xff
+	}
+	return header[8] == 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func hasHTTPMethod(data []byte) bool {
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(data, method) {
+			return true
+		}
+	}
+	return false
+}
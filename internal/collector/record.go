@@ -0,0 +1,303 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+)
+
+// Recordings start with recordMagic followed by one record per event:
+// a uvarint body length and the body as written by appendRecord. Fields added
+// after the first version follow the data, so older recordings parse with
+// those fields left zero.
+const recordMagic = "HMDLREC1"
+
+// recordMaxSize bounds a record body so a corrupt length cannot exhaust
+// memory.
+const recordMaxSize = 64 << 20
+
+const (
+	recordFlagTLS     = 1
+	recordFlagIngress = 2
+)
+
+// Recorder writes events to a file that Replay can read back.
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+	buf  []byte
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create recording: %w", err)
+	}
+	w := bufio.NewWriter(file)
+	if _, err := w.WriteString(recordMagic); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("write header: %w", err)
+	}
+	return &Recorder{file: file, w: w}, nil
+}
+
+func (r *Recorder) Record(ev Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf = appendRecord(r.buf[:0], ev)
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(r.buf)))
+	if _, err := r.w.Write(size[:n]); err != nil {
+		return err
+	}
+	_, err := r.w.Write(r.buf)
+	return err
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	flushErr := r.w.Flush()
+	closeErr := r.file.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// Replay is a Source that reads a recording. Events are delivered with their
+// original spacing divided by speed; a speed of 0 or less replays as fast as
+// possible. Timestamps are kept as recorded.
+type Replay struct {
+	file  *os.File
+	r     *bufio.Reader
+	speed float64
+}
+
+func NewReplay(path string, speed float64) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open recording: %w", err)
+	}
+	r := bufio.NewReader(file)
+	magic := make([]byte, len(recordMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != recordMagic {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a recording", path)
+	}
+	return &Replay{file: file, r: r, speed: speed}, nil
+}
+
+func (p *Replay) Run(ctx context.Context, handler func(Event)) error {
+	pace := pacer{speed: p.speed}
+
+	for {
+		ev, err := p.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if !pace.wait(ctx, ev.Timestamp) {
+			return nil
+		}
+		handler(ev)
+	}
+}
+
+func (p *Replay) Close() {
+	p.file.Close()
+}
+
+func (p *Replay) next() (Event, error) {
+	size, err := binary.ReadUvarint(p.r)
+	if err != nil {
+		return Event{}, err
+	}
+	if size > recordMaxSize {
+		return Event{}, fmt.Errorf("record of %d bytes", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		return Event{}, fmt.Errorf("read record: %w", err)
+	}
+	return parseRecord(body)
+}
+
+// pacer spaces out events read from a file according to their recorded
+// timestamps, divided by speed. A speed of 0 or less does not wait.
+type pacer struct {
+	speed float64
+	first time.Time
+	start time.Time
+}
+
+// wait blocks until the event stamped ts is due and reports false once ctx is
+// done.
+func (p *pacer) wait(ctx context.Context, ts time.Time) bool {
+	if p.speed > 0 {
+		if p.first.IsZero() {
+			p.first = ts
+			p.start = time.Now()
+		}
+		due := p.start.Add(time.Duration(float64(ts.Sub(p.first)) / p.speed))
+		if wait := time.Until(due); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			case <-timer.C:
+			}
+		}
+	}
+	return ctx.Err() == nil
+}
+
+func appendRecord(b []byte, ev Event) []byte {
+	var flags uint8
+	if ev.TLS {
+		flags |= recordFlagTLS
+	}
+	if ev.Ingress {
+		flags |= recordFlagIngress
+	}
+
+	b = binary.AppendVarint(b, ev.Timestamp.UnixNano())
+	b = binary.AppendUvarint(b, uint64(ev.Pid))
+	b = binary.AppendUvarint(b, uint64(ev.Tid))
+	b = binary.AppendVarint(b, int64(ev.Fd))
+	b = binary.AppendUvarint(b, ev.CgroupID)
+	b = append(b, byte(ev.Direction), flags, byte(ev.Hook))
+	b = appendAddrPort(b, ev.Local)
+	b = appendAddrPort(b, ev.Remote)
+	b = binary.AppendUvarint(b, uint64(len(ev.Data)))
+	b = append(b, ev.Data...)
+	b = append(b, byte(ev.Protocol))
+	b = binary.AppendUvarint(b, ev.Socket)
+	b = binary.AppendUvarint(b, uint64(ev.Length))
+	return b
+}
+
+func appendAddrPort(b []byte, ap netip.AddrPort) []byte {
+	if !ap.IsValid() {
+		return append(b, 0)
+	}
+	addr := ap.Addr().AsSlice()
+	b = append(b, byte(len(addr)))
+	b = append(b, addr...)
+	return binary.LittleEndian.AppendUint16(b, ap.Port())
+}
+
+var errShortRecord = errors.New("short record")
+
+type recordReader struct {
+	b   []byte
+	err error
+}
+
+func (r *recordReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = errShortRecord
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *recordReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = errShortRecord
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *recordReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b) {
+		r.err = errShortRecord
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *recordReader) addrPort() netip.AddrPort {
+	size := r.bytes(1)
+	if r.err != nil || size[0] == 0 {
+		return netip.AddrPort{}
+	}
+	addr, ok := netip.AddrFromSlice(r.bytes(int(size[0])))
+	port := r.bytes(2)
+	if r.err != nil {
+		return netip.AddrPort{}
+	}
+	if !ok {
+		r.err = fmt.Errorf("bad address length %d", size[0])
+		return netip.AddrPort{}
+	}
+	return netip.AddrPortFrom(addr, binary.LittleEndian.Uint16(port))
+}
+
+func parseRecord(body []byte) (Event, error) {
+	r := &recordReader{b: body}
+	ev := Event{
+		Timestamp: time.Unix(0, r.varint()).UTC(),
+		Pid:       uint32(r.uvarint()),
+		Tid:       uint32(r.uvarint()),
+		Fd:        int32(r.varint()),
+		CgroupID:  r.uvarint(),
+	}
+	if fields := r.bytes(3); r.err == nil {
+		ev.Direction = Direction(fields[0])
+		ev.TLS = fields[1]&recordFlagTLS != 0
+		ev.Ingress = fields[1]&recordFlagIngress != 0
+		ev.Hook = Hook(fields[2])
+	}
+	ev.Local = r.addrPort()
+	ev.Remote = r.addrPort()
+	dataLen := r.uvarint()
+	if r.err == nil && dataLen > uint64(len(r.b)) {
+		r.err = errShortRecord
+	}
+	if data := r.bytes(int(dataLen)); r.err == nil {
+		ev.Data = append([]byte(nil), data...)
+	}
+	if r.err == nil && len(r.b) > 0 {
+		ev.Protocol = Protocol(r.bytes(1)[0])
+	}
+	if r.err == nil && len(r.b) > 0 {
+		ev.Socket = r.uvarint()
+	}
+	if r.err == nil && len(r.b) > 0 {
+		ev.Length = uint32(r.uvarint())
+	}
+	if r.err != nil {
+		return Event{}, fmt.Errorf("parse record: %w", r.err)
+	}
+	return ev, nil
+}
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"context"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.rec")
+	events := []Event{
+		{
+			Timestamp: time.Unix(1700000000, 123).UTC(),
+			Pid:       10,
+			Tid:       11,
+			Fd:        5,
+			CgroupID:  99,
+			Socket:    123456,
+			Direction: DirectionRequest,
+			TLS:       true,
+			Hook:      HookSSL,
+			Protocol:  ProtocolHTTP,
+			Local:     netip.MustParseAddrPort("10.0.0.1:43210"),
+			Remote:    netip.MustParseAddrPort("[2001:db8::1]:443"),
+			Data:      []byte("GET / HTTP/1.1\r\n\r\n"),
+			Length:    4096,
+		},
+		{
+			Timestamp: time.Unix(1700000000, 5000123).UTC(),
+			Pid:       10,
+			Tid:       11,
+			Fd:        5,
+			Direction: DirectionResponse,
+			Ingress:   true,
+			Hook:      HookRead,
+			Protocol:  ProtocolHTTP2,
+			Data:      []byte("HTTP/1.1 200 OK\r\n\r\n"),
+		},
+		{
+			Timestamp: time.Unix(1700000000, 6000123).UTC(),
+			Pid:       10,
+			Tid:       11,
+			Fd:        5,
+			Socket:    123456,
+			Direction: DirectionClose,
+			Hook:      HookClose,
+		},
+	}
+
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	for _, ev := range events {
+		if err := recorder.Record(ev); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	replay, err := NewReplay(path, 0)
+	if err != nil {
+		t.Fatalf("NewReplay: %v", err)
+	}
+	defer replay.Close()
+
+	var got []Event
+	if err := replay.Run(context.Background(), func(ev Event) { got = append(got, ev) }); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Fatalf("replayed events differ:\n got %+v\nwant %+v", got, events)
+	}
+}
+
+func TestReplayRejectsOversizedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corrupt.rec")
+	data := binary.AppendUvarint([]byte(recordMagic), 1<<40)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	replay, err := NewReplay(path, 0)
+	if err != nil {
+		t.Fatalf("NewReplay: %v", err)
+	}
+	defer replay.Close()
+	if err := replay.Run(context.Background(), func(Event) {}); err == nil {
+		t.Fatalf("expected an oversized record to fail")
+	}
+}
@@ -0,0 +1,15 @@
+package collector
+
+import "context"
+
+// Source produces events for the pipeline. Collector is the live eBPF
+// source; Replay feeds back a recording made by Recorder.
+type Source interface {
+	Run(ctx context.Context, handler func(Event)) error
+	Close()
+}
+
+var (
+	_ Source = (*Collector)(nil)
+	_ Source = (*Replay)(nil)
+)
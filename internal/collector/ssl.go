@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"golang.org/x/sys/unix"
+)
+
+type sslProbe struct {
+	symbol   string
+	entry    *ebpf.Program
+	exit     *ebpf.Program
+	optional bool
+}
+
+func (t *tlsTracer) sslProbes() []sslProbe {
+	return []sslProbe{
+		{symbol: "SSL_write", entry: t.objs.TraceSslWriteEntry, exit: t.objs.TraceSslWriteExit},
+		{symbol: "SSL_read", entry: t.objs.TraceSslReadEntry, exit: t.objs.TraceSslReadExit},
+		{
+			symbol:   "SSL_write_ex",
+			entry:    t.objs.TraceSslWriteExEntry,
+			exit:     t.objs.TraceSslWriteExit,
+			optional: true,
+		},
+		{
+			symbol:   "SSL_read_ex",
+			entry:    t.objs.TraceSslReadExEntry,
+			exit:     t.objs.TraceSslReadExit,
+			optional: true,
+		},
+	}
+}
+
+func (t *tlsTracer) attachSSL(path string) ([]link.Link, error) {
+	ex, err := link.OpenExecutable(path)
+	if err != nil {
+		return nil, fmt.Errorf("open executable: %w", err)
+	}
+
+	var links []link.Link
+	for _, probe := range t.sslProbes() {
+		entry, err := ex.Uprobe(probe.symbol, probe.entry, nil)
+		if err != nil {
+			if probe.optional {
+				continue
+			}
+			closeLinks(links)
+			return nil, fmt.Errorf("uprobe %s: %w", probe.symbol, err)
+		}
+		links = append(links, entry)
+
+		exit, err := ex.Uretprobe(probe.symbol, probe.exit, nil)
+		if err != nil {
+			closeLinks(links)
+			return nil, fmt.Errorf("uretprobe %s: %w", probe.symbol, err)
+		}
+		links = append(links, exit)
+	}
+
+	return links, nil
+}
+
+// sslLibraries returns the libssl mappings of a process, with paths resolved
+// through /proc/<pid>/root so libraries inside containers are reachable.
+func sslLibraries(pid string) (map[fileID]string, error) {
+	f, err := os.Open(filepath.Join("/proc", pid, "maps"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	libs := make(map[fileID]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+		path := fields[5]
+		if !strings.Contains(filepath.Base(path), "libssl.so") {
+			continue
+		}
+		id, ok := parseMapsFileID(fields[3], fields[4])
+		if !ok {
+			continue
+		}
+		libs[id] = filepath.Join("/proc", pid, "root", path)
+	}
+
+	return libs, scanner.Err()
+}
+
+func parseMapsFileID(dev, inode string) (fileID, bool) {
+	major, minor, ok := strings.Cut(dev, ":")
+	if !ok {
+		return fileID{}, false
+	}
+	maj, err := strconv.ParseUint(major, 16, 32)
+	if err != nil {
+		return fileID{}, false
+	}
+	mnr, err := strconv.ParseUint(minor, 16, 32)
+	if err != nil {
+		return fileID{}, false
+	}
+	ino, err := strconv.ParseUint(inode, 10, 64)
+	if err != nil || ino == 0 {
+		return fileID{}, false
+	}
+	return fileID{dev: unix.Mkdev(uint32(maj), uint32(mnr)), inode: ino}, true
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cilium/ebpf"
+)
+
+// Slots of the kernel stats map, see STAT_* in bpf/tracker.c.
+const (
+	statReserveFailed = iota
+	statReadFailed
+	statTruncated
+	statFiltered
+	numStats
+)
+
+// probeNames mirrors enum probe_id in bpf/tracker.c.
+var probeNames = []string{
+	"write_entry",
+	"sendto_entry",
+	"writev_entry",
+	"sendmsg_entry",
+	"sendmmsg_entry",
+	"read_entry",
+	"read_exit",
+	"recv_entry",
+	"recv_exit",
+	"readv_entry",
+	"readv_exit",
+	"recvmsg_entry",
+	"recvmsg_exit",
+	"recvmmsg_entry",
+	"recvmmsg_exit",
+	"ssl_write_entry",
+	"ssl_write_ex_entry",
+	"ssl_write_exit",
+	"ssl_read_entry",
+	"ssl_read_ex_entry",
+	"ssl_read_exit",
+	"go_tls_write_entry",
+	"go_tls_read_entry",
+	"go_tls_read_exit",
+	"close_entry",
+	"shutdown_entry",
+}
+
+type Stats struct {
+	// HookEvents counts events read from the ring buffer per hook, keyed by
+	// hook name. Hooks that produced no events are omitted.
+	HookEvents map[string]uint64
+
+	// Kernel-side counters. ReserveFailures are events lost because the ring
+	// buffer was full; ReadFailures are events whose payload could not be
+	// copied from user memory. Truncated events were cut to the capture size
+	// and Filtered ones were dropped by the capture filters.
+	KernelReserveFailures uint64
+	KernelReadFailures    uint64
+	KernelTruncated       uint64
+	KernelFiltered        uint64
+
+	// ProbeHits counts how often each BPF program ran, keyed by probe name.
+	// Probes that never fired are omitted.
+	ProbeHits map[string]uint64
+
+	// ClockOffset is the wall-clock time of boot used to convert kernel
+	// timestamps, and ClockSteps counts wall-clock jumps seen while
+	// recalibrating it.
+	ClockOffset time.Duration
+	ClockSteps  uint64
+}
+
+func (c *Collector) Stats() Stats {
+	stats := Stats{
+		HookEvents:  make(map[string]uint64),
+		ProbeHits:   make(map[string]uint64),
+		ClockOffset: c.clock.Offset(),
+		ClockSteps:  c.clock.Steps(),
+	}
+	for i := range c.hookEvents {
+		if n := c.hookEvents[i].Load(); n > 0 {
+			stats.HookEvents[Hook(i).String()] = n
+		}
+	}
+
+	kernel, err := sumPerCPU(c.objs.Stats, numStats)
+	if err != nil {
+		log.Printf("read kernel stats: %v", err)
+	} else {
+		stats.KernelReserveFailures = kernel[statReserveFailed]
+		stats.KernelReadFailures = kernel[statReadFailed]
+		stats.KernelTruncated = kernel[statTruncated]
+		stats.KernelFiltered = kernel[statFiltered]
+	}
+
+	hits, err := sumPerCPU(c.objs.ProbeHits, len(probeNames))
+	if err != nil {
+		log.Printf("read probe hits: %v", err)
+	} else {
+		for i, n := range hits {
+			if n > 0 {
+				stats.ProbeHits[probeNames[i]] = n
+			}
+		}
+	}
+	return stats
+}
+
+// sumPerCPU returns the first n slots of a per-CPU array map, each summed
+// across CPUs.
+func sumPerCPU(m *ebpf.Map, n int) ([]uint64, error) {
+	totals := make([]uint64, n)
+	if m == nil {
+		return totals, nil
+	}
+	var values []uint64
+	for i := range totals {
+		if err := m.Lookup(uint32(i), &values); err != nil {
+			return nil, fmt.Errorf("lookup %d: %w", i, err)
+		}
+		for _, v := range values {
+			totals[i] += v
+		}
+	}
+	return totals, nil
+}
@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/link"
+)
+
+const tlsScanInterval = 30 * time.Second
+
+type fileID struct {
+	dev   uint64
+	inode uint64
+}
+
+type attachFunc func(path string) ([]link.Link, error)
+
+// tlsTracer attaches the TLS library uprobes to every running process. Files
+// are keyed by device and inode so a library or binary shared by many
+// processes or containers is only inspected and attached once.
+type tlsTracer struct {
+	objs     *TrackerObjects
+	mu       sync.Mutex
+	attached map[fileID][]link.Link
+}
+
+func newTLSTracer(objs *TrackerObjects) *tlsTracer {
+	return &tlsTracer{
+		objs:     objs,
+		attached: make(map[fileID][]link.Link),
+	}
+}
+
+func (t *tlsTracer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.Scan()
+		}
+	}
+}
+
+func (t *tlsTracer) Scan() {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		log.Printf("tls scan: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if _, err := strconv.ParseUint(entry.Name(), 10, 32); err != nil {
+			continue
+		}
+		if libs, err := sslLibraries(entry.Name()); err == nil {
+			for id, path := range libs {
+				t.attach(id, path, t.attachSSL)
+			}
+		}
+		if id, path, err := processExecutable(entry.Name()); err == nil {
+			t.attach(id, path, t.attachGoTLS)
+		}
+	}
+}
+
+func (t *tlsTracer) attach(id fileID, path string, fn attachFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.attached[id]; ok {
+		return
+	}
+
+	links, err := fn(path)
+	if err != nil {
+		log.Printf("tls attach %s: %v", path, err)
+	} else if len(links) > 0 {
+		log.Printf("tls uprobes attached to %s", path)
+	}
+	// Failed and uninteresting files are remembered too so they are not
+	// inspected again on every scan.
+	t.attached[id] = links
+}
+
+func (t *tlsTracer) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, links := range t.attached {
+		closeLinks(links)
+		delete(t.attached, id)
+	}
+}
+
+func closeLinks(links []link.Link) {
+	for _, l := range links {
+		l.Close()
+	}
+}
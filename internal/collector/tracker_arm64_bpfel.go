@@ -0,0 +1,323 @@
+// Code generated by bpf2go; DO NOT EDIT.
+//go:build arm64
+
+package collector
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"io"
+	"structs"
+
+	"github.com/cilium/ebpf"
+)
+
+type TrackerConnInfoT struct {
+	_              structs.HostLayout
+	Protocol       uint8
+	RequestIngress uint8
+}
+
+type TrackerConnKeyT struct {
+	_   structs.HostLayout
+	Pid uint32
+	Fd  int32
+}
+
+type TrackerEventT struct {
+	_         structs.HostLayout
+	TsNs      uint64
+	CgroupId  uint64
+	SockIno   uint64
+	Pid       uint32
+	Tid       uint32
+	Fd        int32
+	DataLen   uint32
+	BufLen    uint32
+	EventType uint8
+	Flags     uint8
+	Hook      uint8
+	Protocol  uint8
+	Family    uint16
+	Lport     uint16
+	Rport     uint16
+	Pad2      [2]uint8
+	Laddr     [16]uint8
+	Raddr     [16]uint8
+	Data      [4096]int8
+}
+
+type TrackerGoTlsArgsT struct {
+	_   structs.HostLayout
+	Buf uint64
+	Fd  int32
+	_   [4]byte
+}
+
+type TrackerGoTlsKeyT struct {
+	_    structs.HostLayout
+	Pid  uint32
+	Pad  uint32
+	Goid uint64
+}
+
+type TrackerReadArgsT struct {
+	_    structs.HostLayout
+	Buf  uint64
+	Addr uint64
+	Fd   int32
+	Hook uint8
+	_    [3]byte
+}
+
+type TrackerSslArgsT struct {
+	_      structs.HostLayout
+	Ssl    uint64
+	Buf    uint64
+	OutLen uint64
+	Fd     int32
+	_      [4]byte
+}
+
+// LoadTracker returns the embedded CollectionSpec for Tracker.
+func LoadTracker() (*ebpf.CollectionSpec, error) {
+	reader := bytes.NewReader(_TrackerBytes)
+	spec, err := ebpf.LoadCollectionSpecFromReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("can't load Tracker: %w", err)
+	}
+
+	return spec, err
+}
+
+// LoadTrackerObjects loads Tracker and converts it into a struct.
+//
+// The following types are suitable as obj argument:
+//
+//	*TrackerObjects
+//	*TrackerPrograms
+//	*TrackerMaps
+//
+// See ebpf.CollectionSpec.LoadAndAssign documentation for details.
+func LoadTrackerObjects(obj interface{}, opts *ebpf.CollectionOptions) error {
+	spec, err := LoadTracker()
+	if err != nil {
+		return err
+	}
+
+	return spec.LoadAndAssign(obj, opts)
+}
+
+// TrackerSpecs contains maps and programs before they are loaded into the kernel.
+//
+// It can be passed ebpf.CollectionSpec.Assign.
+type TrackerSpecs struct {
+	TrackerProgramSpecs
+	TrackerMapSpecs
+	TrackerVariableSpecs
+}
+
+// TrackerProgramSpecs contains programs before they are loaded into the kernel.
+//
+// It can be passed ebpf.CollectionSpec.Assign.
+type TrackerProgramSpecs struct {
+	TraceCloseEntry      *ebpf.ProgramSpec `ebpf:"trace_close_entry"`
+	TraceGoTlsReadEntry  *ebpf.ProgramSpec `ebpf:"trace_go_tls_read_entry"`
+	TraceGoTlsReadExit   *ebpf.ProgramSpec `ebpf:"trace_go_tls_read_exit"`
+	TraceGoTlsWriteEntry *ebpf.ProgramSpec `ebpf:"trace_go_tls_write_entry"`
+	TraceReadEntry       *ebpf.ProgramSpec `ebpf:"trace_read_entry"`
+	TraceReadExit        *ebpf.ProgramSpec `ebpf:"trace_read_exit"`
+	TraceReadvEntry      *ebpf.ProgramSpec `ebpf:"trace_readv_entry"`
+	TraceReadvExit       *ebpf.ProgramSpec `ebpf:"trace_readv_exit"`
+	TraceRecvEntry       *ebpf.ProgramSpec `ebpf:"trace_recv_entry"`
+	TraceRecvExit        *ebpf.ProgramSpec `ebpf:"trace_recv_exit"`
+	TraceRecvmmsgEntry   *ebpf.ProgramSpec `ebpf:"trace_recvmmsg_entry"`
+	TraceRecvmmsgExit    *ebpf.ProgramSpec `ebpf:"trace_recvmmsg_exit"`
+	TraceRecvmsgEntry    *ebpf.ProgramSpec `ebpf:"trace_recvmsg_entry"`
+	TraceRecvmsgExit     *ebpf.ProgramSpec `ebpf:"trace_recvmsg_exit"`
+	TraceSendmmsgEntry   *ebpf.ProgramSpec `ebpf:"trace_sendmmsg_entry"`
+	TraceSendmsgEntry    *ebpf.ProgramSpec `ebpf:"trace_sendmsg_entry"`
+	TraceSendtoEntry     *ebpf.ProgramSpec `ebpf:"trace_sendto_entry"`
+	TraceShutdownEntry   *ebpf.ProgramSpec `ebpf:"trace_shutdown_entry"`
+	TraceSslReadEntry    *ebpf.ProgramSpec `ebpf:"trace_ssl_read_entry"`
+	TraceSslReadExEntry  *ebpf.ProgramSpec `ebpf:"trace_ssl_read_ex_entry"`
+	TraceSslReadExit     *ebpf.ProgramSpec `ebpf:"trace_ssl_read_exit"`
+	TraceSslWriteEntry   *ebpf.ProgramSpec `ebpf:"trace_ssl_write_entry"`
+	TraceSslWriteExEntry *ebpf.ProgramSpec `ebpf:"trace_ssl_write_ex_entry"`
+	TraceSslWriteExit    *ebpf.ProgramSpec `ebpf:"trace_ssl_write_exit"`
+	TraceWriteEntry      *ebpf.ProgramSpec `ebpf:"trace_write_entry"`
+	TraceWritevEntry     *ebpf.ProgramSpec `ebpf:"trace_writev_entry"`
+}
+
+// TrackerMapSpecs contains maps before they are loaded into the kernel.
+//
+// It can be passed ebpf.CollectionSpec.Assign.
+type TrackerMapSpecs struct {
+	CgroupFilters     *ebpf.MapSpec `ebpf:"cgroup_filters"`
+	ConnProtos        *ebpf.MapSpec `ebpf:"conn_protos"`
+	EventScratch      *ebpf.MapSpec `ebpf:"event_scratch"`
+	Events            *ebpf.MapSpec `ebpf:"events"`
+	FilterAllowCounts *ebpf.MapSpec `ebpf:"filter_allow_counts"`
+	GoTlsReads        *ebpf.MapSpec `ebpf:"go_tls_reads"`
+	GoTlsSocks        *ebpf.MapSpec `ebpf:"go_tls_socks"`
+	LengthPrefixes    *ebpf.MapSpec `ebpf:"length_prefixes"`
+	PendingReads      *ebpf.MapSpec `ebpf:"pending_reads"`
+	PidFilters        *ebpf.MapSpec `ebpf:"pid_filters"`
+	PortFilters       *ebpf.MapSpec `ebpf:"port_filters"`
+	ProbeHits         *ebpf.MapSpec `ebpf:"probe_hits"`
+	SslCalls          *ebpf.MapSpec `ebpf:"ssl_calls"`
+	SslFds            *ebpf.MapSpec `ebpf:"ssl_fds"`
+	Stats             *ebpf.MapSpec `ebpf:"stats"`
+	TracedSocks       *ebpf.MapSpec `ebpf:"traced_socks"`
+}
+
+// TrackerVariableSpecs contains global variables before they are loaded into the kernel.
+//
+// It can be passed ebpf.CollectionSpec.Assign.
+type TrackerVariableSpecs struct {
+	CaptureBytes *ebpf.VariableSpec `ebpf:"capture_bytes"`
+}
+
+// TrackerObjects contains all objects after they have been loaded into the kernel.
+//
+// It can be passed to LoadTrackerObjects or ebpf.CollectionSpec.LoadAndAssign.
+type TrackerObjects struct {
+	TrackerPrograms
+	TrackerMaps
+	TrackerVariables
+}
+
+func (o *TrackerObjects) Close() error {
+	return _TrackerClose(
+		&o.TrackerPrograms,
+		&o.TrackerMaps,
+	)
+}
+
+// TrackerMaps contains all maps after they have been loaded into the kernel.
+//
+// It can be passed to LoadTrackerObjects or ebpf.CollectionSpec.LoadAndAssign.
+type TrackerMaps struct {
+	CgroupFilters     *ebpf.Map `ebpf:"cgroup_filters"`
+	ConnProtos        *ebpf.Map `ebpf:"conn_protos"`
+	EventScratch      *ebpf.Map `ebpf:"event_scratch"`
+	Events            *ebpf.Map `ebpf:"events"`
+	FilterAllowCounts *ebpf.Map `ebpf:"filter_allow_counts"`
+	GoTlsReads        *ebpf.Map `ebpf:"go_tls_reads"`
+	GoTlsSocks        *ebpf.Map `ebpf:"go_tls_socks"`
+	LengthPrefixes    *ebpf.Map `ebpf:"length_prefixes"`
+	PendingReads      *ebpf.Map `ebpf:"pending_reads"`
+	PidFilters        *ebpf.Map `ebpf:"pid_filters"`
+	PortFilters       *ebpf.Map `ebpf:"port_filters"`
+	ProbeHits         *ebpf.Map `ebpf:"probe_hits"`
+	SslCalls          *ebpf.Map `ebpf:"ssl_calls"`
+	SslFds            *ebpf.Map `ebpf:"ssl_fds"`
+	Stats             *ebpf.Map `ebpf:"stats"`
+	TracedSocks       *ebpf.Map `ebpf:"traced_socks"`
+}
+
+func (m *TrackerMaps) Close() error {
+	return _TrackerClose(
+		m.CgroupFilters,
+		m.ConnProtos,
+		m.EventScratch,
+		m.Events,
+		m.FilterAllowCounts,
+		m.GoTlsReads,
+		m.GoTlsSocks,
+		m.LengthPrefixes,
+		m.PendingReads,
+		m.PidFilters,
+		m.PortFilters,
+		m.ProbeHits,
+		m.SslCalls,
+		m.SslFds,
+		m.Stats,
+		m.TracedSocks,
+	)
+}
+
+// TrackerVariables contains all global variables after they have been loaded into the kernel.
+//
+// It can be passed to LoadTrackerObjects or ebpf.CollectionSpec.LoadAndAssign.
+type TrackerVariables struct {
+	CaptureBytes *ebpf.Variable `ebpf:"capture_bytes"`
+}
+
+// TrackerPrograms contains all programs after they have been loaded into the kernel.
+//
+// It can be passed to LoadTrackerObjects or ebpf.CollectionSpec.LoadAndAssign.
+type TrackerPrograms struct {
+	TraceCloseEntry      *ebpf.Program `ebpf:"trace_close_entry"`
+	TraceGoTlsReadEntry  *ebpf.Program `ebpf:"trace_go_tls_read_entry"`
+	TraceGoTlsReadExit   *ebpf.Program `ebpf:"trace_go_tls_read_exit"`
+	TraceGoTlsWriteEntry *ebpf.Program `ebpf:"trace_go_tls_write_entry"`
+	TraceReadEntry       *ebpf.Program `ebpf:"trace_read_entry"`
+	TraceReadExit        *ebpf.Program `ebpf:"trace_read_exit"`
+	TraceReadvEntry      *ebpf.Program `ebpf:"trace_readv_entry"`
+	TraceReadvExit       *ebpf.Program `ebpf:"trace_readv_exit"`
+	TraceRecvEntry       *ebpf.Program `ebpf:"trace_recv_entry"`
+	TraceRecvExit        *ebpf.Program `ebpf:"trace_recv_exit"`
+	TraceRecvmmsgEntry   *ebpf.Program `ebpf:"trace_recvmmsg_entry"`
+	TraceRecvmmsgExit    *ebpf.Program `ebpf:"trace_recvmmsg_exit"`
+	TraceRecvmsgEntry    *ebpf.Program `ebpf:"trace_recvmsg_entry"`
+	TraceRecvmsgExit     *ebpf.Program `ebpf:"trace_recvmsg_exit"`
+	TraceSendmmsgEntry   *ebpf.Program `ebpf:"trace_sendmmsg_entry"`
+	TraceSendmsgEntry    *ebpf.Program `ebpf:"trace_sendmsg_entry"`
+	TraceSendtoEntry     *ebpf.Program `ebpf:"trace_sendto_entry"`
+	TraceShutdownEntry   *ebpf.Program `ebpf:"trace_shutdown_entry"`
+	TraceSslReadEntry    *ebpf.Program `ebpf:"trace_ssl_read_entry"`
+	TraceSslReadExEntry  *ebpf.Program `ebpf:"trace_ssl_read_ex_entry"`
+	TraceSslReadExit     *ebpf.Program `ebpf:"trace_ssl_read_exit"`
+	TraceSslWriteEntry   *ebpf.Program `ebpf:"trace_ssl_write_entry"`
+	TraceSslWriteExEntry *ebpf.Program `ebpf:"trace_ssl_write_ex_entry"`
+	TraceSslWriteExit    *ebpf.Program `ebpf:"trace_ssl_write_exit"`
+	TraceWriteEntry      *ebpf.Program `ebpf:"trace_write_entry"`
+	TraceWritevEntry     *ebpf.Program `ebpf:"trace_writev_entry"`
+}
+
+func (p *TrackerPrograms) Close() error {
+	return _TrackerClose(
+		p.TraceCloseEntry,
+		p.TraceGoTlsReadEntry,
+		p.TraceGoTlsReadExit,
+		p.TraceGoTlsWriteEntry,
+		p.TraceReadEntry,
+		p.TraceReadExit,
+		p.TraceReadvEntry,
+		p.TraceReadvExit,
+		p.TraceRecvEntry,
+		p.TraceRecvExit,
+		p.TraceRecvmmsgEntry,
+		p.TraceRecvmmsgExit,
+		p.TraceRecvmsgEntry,
+		p.TraceRecvmsgExit,
+		p.TraceSendmmsgEntry,
+		p.TraceSendmsgEntry,
+		p.TraceSendtoEntry,
+		p.TraceShutdownEntry,
+		p.TraceSslReadEntry,
+		p.TraceSslReadExEntry,
+		p.TraceSslReadExit,
+		p.TraceSslWriteEntry,
+		p.TraceSslWriteExEntry,
+		p.TraceSslWriteExit,
+		p.TraceWriteEntry,
+		p.TraceWritevEntry,
+	)
+}
+
+func _TrackerClose(closers ...io.Closer) error {
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Do not access this directly.
+//
+//go:embed tracker_arm64_bpfel.o
+var _TrackerBytes []byte
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -31,8 +32,34 @@ type AgentConfig struct {
 	CorrelatorTTL       time.Duration
 	DiagnosticsInterval time.Duration
 	NodeName            string
+	ExcludeSelf         bool
+	ExcludeNamespaces   []string
+	IncludePorts        []uint16
+	ExcludePorts        []uint16
+	Mode                string
+	RecordFile          string
+	PcapFile            string
+	ReplaySpeed         float64
+	RedisKeys           string
+	MemcachedKeys       string
+	RouteTemplates      []string
+	HTTPHeaders         []string
+	PayloadNamespaces   []string
+	PayloadRoutes       []string
+	PayloadMaxBytes     int
+	PayloadContentTypes []string
+	PayloadRedactKeys   []string
+	EmitAborted         bool
 }
 
+var (
+	defaultPayloadContentTypes = []string{"application/json", "application/*+json", "text/*"}
+	defaultPayloadRedactKeys   = []string{
+		"password", "passwd", "secret", "token", "access_token", "refresh_token",
+		"api_key", "apikey", "authorization",
+	}
+)
+
 func Load() Config {
 	nodeName := getEnv("NODE_NAME", "")
 	if nodeName == "" {
@@ -57,10 +84,28 @@ func Load() Config {
 			FlushInterval:       getEnvDuration("AGENT_FLUSH_INTERVAL", 2*time.Second),
 			MaxQueue:            getEnvInt("AGENT_MAX_QUEUE", 5000),
 			K8sEnrich:           getEnvBool("AGENT_K8S_ENRICH", false),
-			HTTPSampleBytes:     getEnvInt("AGENT_HTTP_SAMPLE_BYTES", 128),
+			HTTPSampleBytes:     getEnvInt("AGENT_HTTP_SAMPLE_BYTES", 1024),
 			CorrelatorTTL:       getEnvDuration("AGENT_CORRELATOR_TTL", 30*time.Second),
 			DiagnosticsInterval: getEnvDuration("AGENT_DIAGNOSTICS_INTERVAL", 15*time.Second),
 			NodeName:            nodeName,
+			ExcludeSelf:         getEnvBool("AGENT_EXCLUDE_SELF", true),
+			ExcludeNamespaces:   getEnvList("AGENT_EXCLUDE_NAMESPACES"),
+			IncludePorts:        getEnvPorts("AGENT_INCLUDE_PORTS"),
+			ExcludePorts:        getEnvPorts("AGENT_EXCLUDE_PORTS"),
+			Mode:                strings.ToLower(getEnv("AGENT_MODE", "live")),
+			RecordFile:          getEnv("AGENT_RECORD_FILE", "heimdall.rec"),
+			PcapFile:            getEnv("AGENT_PCAP_FILE", ""),
+			ReplaySpeed:         getEnvFloat("AGENT_REPLAY_SPEED", 1),
+			RedisKeys:           strings.ToLower(getEnv("AGENT_REDIS_KEYS", "hash")),
+			MemcachedKeys:       strings.ToLower(getEnv("AGENT_MEMCACHED_KEYS", "hash")),
+			RouteTemplates:      getEnvList("AGENT_ROUTE_TEMPLATES"),
+			HTTPHeaders:         getEnvList("AGENT_HTTP_HEADERS"),
+			PayloadNamespaces:   getEnvList("AGENT_PAYLOAD_NAMESPACES"),
+			PayloadRoutes:       getEnvList("AGENT_PAYLOAD_ROUTES"),
+			PayloadMaxBytes:     getEnvInt("AGENT_PAYLOAD_MAX_BYTES", 256),
+			PayloadContentTypes: getEnvListOr("AGENT_PAYLOAD_CONTENT_TYPES", defaultPayloadContentTypes),
+			PayloadRedactKeys:   getEnvListOr("AGENT_PAYLOAD_REDACT_KEYS", defaultPayloadRedactKeys),
+			EmitAborted:         getEnvBool("AGENT_EMIT_ABORTED", false),
 		},
 	}
 }
@@ -84,6 +129,18 @@ func getEnvInt(key string, fallback int) int {
 	return value
 }
 
+func getEnvFloat(key string, fallback float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
 func getEnvBool(key string, fallback bool) bool {
 	raw := os.Getenv(key)
 	if raw == "" {
@@ -96,6 +153,35 @@ func getEnvBool(key string, fallback bool) bool {
 	return value
 }
 
+func getEnvList(key string) []string {
+	var values []string
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+func getEnvListOr(key string, fallback []string) []string {
+	if values := getEnvList(key); len(values) > 0 {
+		return values
+	}
+	return fallback
+}
+
+func getEnvPorts(key string) []uint16 {
+	var ports []uint16
+	for _, raw := range getEnvList(key) {
+		port, err := strconv.ParseUint(raw, 10, 16)
+		if err != nil {
+			continue
+		}
+		ports = append(ports, uint16(port))
+	}
+	return ports
+}
+
 func getEnvDuration(key string, fallback time.Duration) time.Duration {
 	raw := os.Getenv(key)
 	if raw == "" {
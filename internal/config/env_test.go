@@ -21,6 +21,24 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.Agent.DiagnosticsInterval != 15*time.Second {
 		t.Fatalf("expected default diagnostics interval")
 	}
+	if cfg.Agent.Mode != "live" || cfg.Agent.ReplaySpeed != 1 {
+		t.Fatalf("expected live mode at replay speed 1, got %q %v", cfg.Agent.Mode, cfg.Agent.ReplaySpeed)
+	}
+	if cfg.Agent.RedisKeys != "hash" {
+		t.Fatalf("expected hashed redis keys by default, got %q", cfg.Agent.RedisKeys)
+	}
+	if cfg.Agent.MemcachedKeys != "hash" {
+		t.Fatalf("expected hashed memcached keys by default, got %q", cfg.Agent.MemcachedKeys)
+	}
+	if len(cfg.Agent.RouteTemplates) != 0 {
+		t.Fatalf("expected no route templates by default, got %v", cfg.Agent.RouteTemplates)
+	}
+	if len(cfg.Agent.PayloadNamespaces) != 0 || len(cfg.Agent.PayloadContentTypes) != 3 || cfg.Agent.PayloadRedactKeys[0] != "password" {
+		t.Fatalf("unexpected payload defaults: %+v", cfg.Agent)
+	}
+	if cfg.Agent.EmitAborted {
+		t.Fatalf("expected aborted requests to be dropped by default")
+	}
 }
 
 func TestLoadOverrides(t *testing.T) {
@@ -28,6 +46,7 @@ func TestLoadOverrides(t *testing.T) {
 	t.Setenv("HTTP_PORT", "9000")
 	t.Setenv("AGENT_BATCH_SIZE", "10")
 	t.Setenv("AGENT_DIAGNOSTICS_INTERVAL", "5s")
+	t.Setenv("AGENT_EMIT_ABORTED", "true")
 
 	cfg := Load()
 	if cfg.Port != "6000" {
@@ -42,6 +61,37 @@ func TestLoadOverrides(t *testing.T) {
 	if cfg.Agent.DiagnosticsInterval != 5*time.Second {
 		t.Fatalf("expected AGENT_DIAGNOSTICS_INTERVAL override")
 	}
+	if !cfg.Agent.EmitAborted {
+		t.Fatalf("expected AGENT_EMIT_ABORTED override")
+	}
+}
+
+func TestLoadFilterLists(t *testing.T) {
+	t.Setenv("AGENT_EXCLUDE_NAMESPACES", "kube-system, monitoring,,")
+	t.Setenv("AGENT_EXCLUDE_PORTS", "22,bad,99999,9000")
+	t.Setenv("AGENT_INCLUDE_PORTS", "")
+	t.Setenv("AGENT_ROUTE_TEMPLATES", "checkout=/carts/{cart}, /static/{file}")
+	t.Setenv("AGENT_HTTP_HEADERS", "X-Tenant-ID, x-request-id")
+
+	cfg := Load()
+	if len(cfg.Agent.ExcludeNamespaces) != 2 || cfg.Agent.ExcludeNamespaces[1] != "monitoring" {
+		t.Fatalf("unexpected namespaces: %v", cfg.Agent.ExcludeNamespaces)
+	}
+	if len(cfg.Agent.ExcludePorts) != 2 || cfg.Agent.ExcludePorts[1] != 9000 {
+		t.Fatalf("unexpected ports: %v", cfg.Agent.ExcludePorts)
+	}
+	if len(cfg.Agent.RouteTemplates) != 2 || cfg.Agent.RouteTemplates[1] != "/static/{file}" {
+		t.Fatalf("unexpected route templates: %v", cfg.Agent.RouteTemplates)
+	}
+	if len(cfg.Agent.HTTPHeaders) != 2 || cfg.Agent.HTTPHeaders[0] != "X-Tenant-ID" {
+		t.Fatalf("unexpected HTTP headers: %v", cfg.Agent.HTTPHeaders)
+	}
+	if cfg.Agent.IncludePorts != nil {
+		t.Fatalf("expected no include ports")
+	}
+	if !cfg.Agent.ExcludeSelf {
+		t.Fatalf("expected agent traffic excluded by default")
+	}
 }
 
 func TestNodeNameFallback(t *testing.T) {
@@ -1,62 +1,187 @@
 package correlation
 
 import (
+	"net/netip"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
 
+// RequestKey identifies an in-flight request. Socket tells connections that
+// reused an fd number apart and is 0 when unknown. Stream is zero for
+// protocols with one outstanding request per connection and carries the
+// stream or correlation ID for multiplexed ones.
 type RequestKey struct {
-	Pid uint32
-	Fd  int32
+	Pid    uint32
+	Fd     int32
+	Socket uint64
+	Stream uint64
+}
+
+// ConnKey identifies the connection a request was sent on.
+type ConnKey struct {
+	Pid    uint32
+	Fd     int32
+	Socket uint64
+}
+
+func (k RequestKey) Conn() ConnKey {
+	return ConnKey{Pid: k.Pid, Fd: k.Fd, Socket: k.Socket}
 }
 
 type Request struct {
 	Key      RequestKey
 	Tid      uint32
 	CgroupID uint64
+	Role     string
+	Type     string
 	Method   string
 	Path     string
+	Local    netip.AddrPort
+	Remote   netip.AddrPort
 	Started  time.Time
+	// Trace context propagated in the request headers, if any.
+	TraceID      string
+	SpanID       string
+	TraceSampled bool
+	// Selected HTTP request headers; Attributes holds the allowlisted ones
+	// by lowercased name.
+	Host       string
+	UserAgent  string
+	Attributes map[string]string
+	// Start of the request body and its Content-Type, kept only while
+	// payload sampling is on.
+	Body     []byte
+	BodyType string
 }
 
+// maxQueued bounds the requests waiting on one key, so a connection whose
+// responses are never seen cannot grow without limit before the TTL.
+const maxQueued = 64
+
+// Correlator pairs responses with requests. Requests sharing a key, such as
+// pipelined HTTP/1 requests on one connection, wait in a FIFO queue and are
+// matched in the order they were sent. Queues are grouped by connection so
+// that all of them can be dropped when the connection closes.
 type Correlator struct {
 	mu       sync.Mutex
 	ttl      time.Duration
-	requests map[RequestKey]Request
+	requests map[ConnKey]map[uint64][]Request
 }
 
 func NewCorrelator(ttl time.Duration) *Correlator {
 	return &Correlator{
 		ttl:      ttl,
-		requests: make(map[RequestKey]Request),
+		requests: make(map[ConnKey]map[uint64][]Request),
 	}
 }
 
-func (c *Correlator) Add(req Request) {
+// Add queues req and reports whether an earlier request had to be dropped
+// for it: the oldest one when the queue is full, or the previous request
+// on a stream, since stream and correlation IDs are not reused while a
+// request is outstanding.
+func (c *Correlator) Add(req Request) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.requests[req.Key] = req
+	conn := c.requests[req.Key.Conn()]
+	if conn == nil {
+		conn = make(map[uint64][]Request)
+		c.requests[req.Key.Conn()] = conn
+	}
+	queue := conn[req.Key.Stream]
+	dropped := false
+	if req.Key.Stream != 0 && len(queue) > 0 {
+		queue = queue[:0]
+		dropped = true
+	} else if len(queue) >= maxQueued {
+		queue = queue[1:]
+		dropped = true
+	}
+	conn[req.Key.Stream] = append(queue, req)
+	return dropped
 }
 
 func (c *Correlator) Match(pid uint32, fd int32) (Request, bool) {
+	return c.MatchKey(RequestKey{Pid: pid, Fd: fd})
+}
+
+// MatchKey removes and returns the oldest request waiting on key.
+func (c *Correlator) MatchKey(key RequestKey) (Request, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	key := RequestKey{Pid: pid, Fd: fd}
-	req, ok := c.requests[key]
-	if ok {
-		delete(c.requests, key)
+	conn := c.requests[key.Conn()]
+	queue := conn[key.Stream]
+	if len(queue) == 0 {
+		return Request{}, false
 	}
-	return req, ok
+	req := queue[0]
+	if len(queue) == 1 {
+		delete(conn, key.Stream)
+		if len(conn) == 0 {
+			delete(c.requests, key.Conn())
+		}
+	} else {
+		queue[0] = Request{}
+		conn[key.Stream] = queue[1:]
+	}
+	return req, true
 }
 
+// Pending returns the requests waiting on key, oldest first, leaving them
+// queued.
+func (c *Correlator) Pending(key RequestKey) []Request {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.requests[key.Conn()][key.Stream])
+}
+
+// Close removes every request still waiting on conn, which can no longer
+// be answered, and returns them in the order they were started.
+func (c *Correlator) Close(conn ConnKey) []Request {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	streams, ok := c.requests[conn]
+	if !ok {
+		return nil
+	}
+	delete(c.requests, conn)
+
+	var pending []Request
+	for _, queue := range streams {
+		pending = append(pending, queue...)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Started.Before(pending[j].Started)
+	})
+	return pending
+}
+
+// Expire drops the requests older than the TTL, which never got a response,
+// and returns how many there were.
 func (c *Correlator) Expire(now time.Time) int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	removed := 0
-	for key, req := range c.requests {
-		if now.Sub(req.Started) > c.ttl {
-			delete(c.requests, key)
-			removed++
+	for connKey, conn := range c.requests {
+		for stream, queue := range conn {
+			kept := queue[:0]
+			for _, req := range queue {
+				if now.Sub(req.Started) > c.ttl {
+					removed++
+				} else {
+					kept = append(kept, req)
+				}
+			}
+			if len(kept) == 0 {
+				delete(conn, stream)
+			} else {
+				clear(queue[len(kept):])
+				conn[stream] = kept
+			}
+		}
+		if len(conn) == 0 {
+			delete(c.requests, connKey)
 		}
 	}
 	return removed
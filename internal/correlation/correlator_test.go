@@ -37,3 +37,111 @@ func TestCorrelatorExpire(t *testing.T) {
 		t.Fatalf("expected 1 removed, got %d", removed)
 	}
 }
+
+func TestCorrelatorMatchStreams(t *testing.T) {
+	corr := NewCorrelator(5 * time.Second)
+	corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3, Stream: 1}, Path: "/a", Started: time.Now()})
+	corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3, Stream: 3}, Path: "/b", Started: time.Now()})
+
+	got, ok := corr.MatchKey(RequestKey{Pid: 1, Fd: 3, Stream: 3})
+	if !ok || got.Path != "/b" {
+		t.Fatalf("expected stream 3 to match /b, got %+v %v", got, ok)
+	}
+	got, ok = corr.MatchKey(RequestKey{Pid: 1, Fd: 3, Stream: 1})
+	if !ok || got.Path != "/a" {
+		t.Fatalf("expected stream 1 to match /a, got %+v %v", got, ok)
+	}
+	if _, ok := corr.Match(1, 3); ok {
+		t.Fatalf("unexpected match without stream")
+	}
+}
+
+func TestCorrelatorPipelining(t *testing.T) {
+	corr := NewCorrelator(5 * time.Second)
+	now := time.Now()
+	if corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3}, Path: "/first", Started: now}) {
+		t.Fatalf("unexpected drop")
+	}
+	if corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3}, Path: "/second", Started: now}) {
+		t.Fatalf("expected the second request to queue behind the first")
+	}
+
+	for _, want := range []string{"/first", "/second"} {
+		got, ok := corr.Match(1, 3)
+		if !ok || got.Path != want {
+			t.Fatalf("expected %s, got %+v %v", want, got, ok)
+		}
+	}
+	if _, ok := corr.Match(1, 3); ok {
+		t.Fatalf("expected the queue to be empty")
+	}
+}
+
+func TestCorrelatorQueueLimits(t *testing.T) {
+	corr := NewCorrelator(5 * time.Second)
+	now := time.Now()
+	for i := 0; i < maxQueued; i++ {
+		corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3}, Started: now.Add(time.Duration(i))})
+	}
+	if !corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3}, Started: now.Add(time.Hour)}) {
+		t.Fatalf("expected a full queue to drop its oldest request")
+	}
+	if got, _ := corr.Match(1, 3); !got.Started.Equal(now.Add(1)) {
+		t.Fatalf("expected the oldest request to be gone, got %v", got.Started)
+	}
+
+	key := RequestKey{Pid: 1, Fd: 4, Stream: 7}
+	corr.Add(Request{Key: key, Path: "/old", Started: now})
+	if !corr.Add(Request{Key: key, Path: "/new", Started: now}) {
+		t.Fatalf("expected a reused stream to replace its request")
+	}
+	if got, _ := corr.MatchKey(key); got.Path != "/new" {
+		t.Fatalf("unexpected stream request %+v", got)
+	}
+}
+
+func TestCorrelatorExpireKeepsRecent(t *testing.T) {
+	corr := NewCorrelator(time.Second)
+	now := time.Now()
+	corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3}, Path: "/old", Started: now.Add(-2 * time.Second)})
+	corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3}, Path: "/new", Started: now})
+
+	if removed := corr.Expire(now); removed != 1 {
+		t.Fatalf("expected 1 removed, got %d", removed)
+	}
+	if got, ok := corr.Match(1, 3); !ok || got.Path != "/new" {
+		t.Fatalf("expected the recent request to remain, got %+v %v", got, ok)
+	}
+}
+
+func TestCorrelatorSocketIdentity(t *testing.T) {
+	corr := NewCorrelator(5 * time.Second)
+	now := time.Now()
+	corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3, Socket: 100}, Path: "/old", Started: now})
+
+	if _, ok := corr.MatchKey(RequestKey{Pid: 1, Fd: 3, Socket: 200}); ok {
+		t.Fatalf("expected a reused fd on another socket not to match")
+	}
+	if got, ok := corr.MatchKey(RequestKey{Pid: 1, Fd: 3, Socket: 100}); !ok || got.Path != "/old" {
+		t.Fatalf("expected the original socket to match, got %+v %v", got, ok)
+	}
+}
+
+func TestCorrelatorClose(t *testing.T) {
+	corr := NewCorrelator(5 * time.Second)
+	now := time.Now()
+	corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3, Socket: 100, Stream: 3}, Path: "/b", Started: now.Add(time.Millisecond)})
+	corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3, Socket: 100, Stream: 1}, Path: "/a", Started: now})
+	corr.Add(Request{Key: RequestKey{Pid: 1, Fd: 3, Socket: 200}, Path: "/other", Started: now})
+
+	pending := corr.Close(ConnKey{Pid: 1, Fd: 3, Socket: 100})
+	if len(pending) != 2 || pending[0].Path != "/a" || pending[1].Path != "/b" {
+		t.Fatalf("unexpected pending requests %+v", pending)
+	}
+	if _, ok := corr.MatchKey(RequestKey{Pid: 1, Fd: 3, Socket: 100, Stream: 1}); ok {
+		t.Fatalf("expected closed requests to be gone")
+	}
+	if _, ok := corr.MatchKey(RequestKey{Pid: 1, Fd: 3, Socket: 200}); !ok {
+		t.Fatalf("expected other sockets to be kept")
+	}
+}
@@ -0,0 +1,134 @@
+package dnsparse
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerLen = 12
+
+	flagResponse = 0x8000
+
+	// Labels followed per name, which also bounds compression pointer loops.
+	maxLabels = 128
+)
+
+// Message is the header and first question of a DNS message.
+type Message struct {
+	ID       uint16
+	Response bool
+	RCode    uint8
+	Name     string
+	Type     uint16
+}
+
+var typeNames = map[uint16]string{
+	1:   "A",
+	2:   "NS",
+	5:   "CNAME",
+	6:   "SOA",
+	12:  "PTR",
+	15:  "MX",
+	16:  "TXT",
+	28:  "AAAA",
+	33:  "SRV",
+	35:  "NAPTR",
+	41:  "OPT",
+	43:  "DS",
+	46:  "RRSIG",
+	48:  "DNSKEY",
+	64:  "SVCB",
+	65:  "HTTPS",
+	255: "ANY",
+	257: "CAA",
+}
+
+var rcodeNames = []string{
+	"NOERROR", "FORMERR", "SERVFAIL", "NXDOMAIN", "NOTIMP", "REFUSED",
+	"YXDOMAIN", "YXRRSET", "NXRRSET", "NOTAUTH", "NOTZONE",
+}
+
+// TypeName returns the mnemonic of a query type, or the generic TYPEn form.
+func TypeName(t uint16) string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// RCodeName returns the mnemonic of a response code.
+func RCodeName(rcode uint8) string {
+	if int(rcode) < len(rcodeNames) {
+		return rcodeNames[rcode]
+	}
+	return "RCODE" + strconv.Itoa(int(rcode))
+}
+
+// Parse reads the header and the first question of msg. Names are lowercased
+// since resolvers may randomise their case.
+func Parse(msg []byte) (Message, bool) {
+	if len(msg) < headerLen {
+		return Message{}, false
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if binary.BigEndian.Uint16(msg[4:]) == 0 {
+		return Message{}, false
+	}
+
+	name, end, ok := readName(msg, headerLen)
+	if !ok || end+4 > len(msg) {
+		return Message{}, false
+	}
+	return Message{
+		ID:       binary.BigEndian.Uint16(msg),
+		Response: flags&flagResponse != 0,
+		RCode:    uint8(flags & 0x0f),
+		Name:     name,
+		Type:     binary.BigEndian.Uint16(msg[end:]),
+	}, true
+}
+
+// readName decodes the name at offset and returns the offset after it.
+func readName(msg []byte, offset int) (string, int, bool) {
+	var b strings.Builder
+	end := -1
+	for i := 0; i < maxLabels; i++ {
+		if offset >= len(msg) {
+			return "", 0, false
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			if b.Len() == 0 {
+				return ".", end, true
+			}
+			return b.String(), end, true
+		case length&0xc0 == 0xc0:
+			if offset+1 >= len(msg) {
+				return "", 0, false
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3fff)
+		case length&0xc0 != 0:
+			return "", 0, false
+		default:
+			offset++
+			if offset+length > len(msg) {
+				return "", 0, false
+			}
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(strings.ToLower(string(msg[offset : offset+length])))
+			offset += length
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,41 @@
+package dnsparse
+
+import "testing"
+
+func query(id uint16, flags uint16, name []byte, qtype uint16) []byte {
+	msg := []byte{byte(id >> 8), byte(id), byte(flags >> 8), byte(flags), 0, 1, 0, 0, 0, 0, 0, 0}
+	msg = append(msg, name...)
+	return append(msg, byte(qtype>>8), byte(qtype), 0, 1)
+}
+
+var exampleName = []byte("\x03API\x07Example\x03com\x00")
+
+func TestParseQuery(t *testing.T) {
+	msg, ok := Parse(query(0xbeef, 0x0100, exampleName, 28))
+	if !ok || msg.ID != 0xbeef || msg.Response || msg.Name != "api.example.com" || TypeName(msg.Type) != "AAAA" {
+		t.Fatalf("unexpected query %+v %v", msg, ok)
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	msg, ok := Parse(query(7, 0x8183, exampleName, 1))
+	if !ok || !msg.Response || RCodeName(msg.RCode) != "NXDOMAIN" {
+		t.Fatalf("unexpected response %+v %v", msg, ok)
+	}
+}
+
+func TestParseCompressedName(t *testing.T) {
+	// The question name points back into itself; parsing must stop.
+	if _, ok := Parse(query(1, 0, []byte{0xc0, 12}, 1)); ok {
+		t.Fatalf("expected a pointer loop to be rejected")
+	}
+	if _, ok := Parse(query(1, 0, exampleName[:8], 1)[:20]); ok {
+		t.Fatalf("expected a truncated name to be rejected")
+	}
+}
+
+func TestNames(t *testing.T) {
+	if TypeName(99) != "TYPE99" || RCodeName(2) != "SERVFAIL" || RCodeName(16) != "RCODE16" {
+		t.Fatalf("unexpected names")
+	}
+}
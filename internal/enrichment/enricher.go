@@ -2,12 +2,14 @@ package enrichment
 
 import (
 	"context"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/emresahna/heimdall/internal/telemetry"
@@ -24,6 +26,10 @@ type Enricher interface {
 	Enrich(ctx context.Context, pid uint32, cgroupID uint64, entry *telemetry.LogEntry)
 }
 
+// PodListener is notified with the containers of a pod whenever the pod is
+// added, updated or deleted on this node.
+type PodListener func(metas []PodMeta, deleted bool)
+
 type NoopEnricher struct {
 	node string
 }
@@ -44,7 +50,12 @@ type K8sEnricher struct {
 	pidCache *pidCache
 }
 
-func NewEnricher(ctx context.Context, enabled bool, nodeName string) (Enricher, error) {
+func NewEnricher(
+	ctx context.Context,
+	enabled bool,
+	nodeName string,
+	listeners ...PodListener,
+) (Enricher, error) {
 	if !enabled {
 		return NoopEnricher{node: nodeName}, nil
 	}
@@ -78,6 +89,16 @@ func NewEnricher(ctx context.Context, enabled bool, nodeName string) (Enricher,
 		}),
 	)
 
+	notify := func(pod *v1.Pod, deleted bool) {
+		if len(listeners) == 0 {
+			return
+		}
+		metas := extractPodMeta(pod)
+		for _, listener := range listeners {
+			listener(metas, deleted)
+		}
+	}
+
 	informer := factory.Core().V1().Pods().Informer()
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj any) {
@@ -86,6 +107,7 @@ func NewEnricher(ctx context.Context, enabled bool, nodeName string) (Enricher,
 				return
 			}
 			index.UpsertPod(pod)
+			notify(pod, false)
 		},
 		UpdateFunc: func(_, newObj any) {
 			pod, ok := newObj.(*v1.Pod)
@@ -93,6 +115,7 @@ func NewEnricher(ctx context.Context, enabled bool, nodeName string) (Enricher,
 				return
 			}
 			index.UpsertPod(pod)
+			notify(pod, false)
 		},
 		DeleteFunc: func(obj any) {
 			pod, ok := obj.(*v1.Pod)
@@ -107,6 +130,7 @@ func NewEnricher(ctx context.Context, enabled bool, nodeName string) (Enricher,
 				}
 			}
 			index.DeletePod(pod)
+			notify(pod, true)
 		},
 	})
 
@@ -290,6 +314,64 @@ func containerIDFromPID(pid uint32) string {
 	return string(match)
 }
 
+// hostCgroupRoot is the host's cgroup v2 hierarchy as seen from an agent
+// running with hostPID, independent of the agent's own cgroup namespace.
+const hostCgroupRoot = "/proc/1/root/sys/fs/cgroup"
+
+// kubepodsRoots are the parents of every pod cgroup under the systemd and
+// cgroupfs drivers respectively.
+var kubepodsRoots = []string{"kubepods.slice", "kubepods"}
+
+var cgroupIndex = struct {
+	sync.Mutex
+	ids map[string]uint64
+}{ids: make(map[string]uint64)}
+
+// CgroupIDForContainer finds the cgroup v2 directory of a container and
+// returns its inode number, which is the ID bpf_get_current_cgroup_id reports.
+// A miss re-indexes the kubepods subtree once, so each new pod costs a single
+// walk rather than one per container lookup.
+func CgroupIDForContainer(containerID string) (uint64, bool) {
+	if containerID == "" {
+		return 0, false
+	}
+
+	cgroupIndex.Lock()
+	defer cgroupIndex.Unlock()
+
+	if id, ok := cgroupIndex.ids[containerID]; ok {
+		return id, true
+	}
+	cgroupIndex.ids = indexContainerCgroups(hostCgroupRoot)
+	id, ok := cgroupIndex.ids[containerID]
+	return id, ok
+}
+
+func indexContainerCgroups(root string) map[string]uint64 {
+	ids := make(map[string]uint64)
+	for _, dir := range kubepodsRoots {
+		_ = filepath.WalkDir(filepath.Join(root, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			match := containerIDRegex.FindString(d.Name())
+			if match == "" {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if st, ok := info.Sys().(*syscall.Stat_t); ok {
+				ids[match] = st.Ino
+			}
+			// Container cgroups are leaves of the pod cgroup.
+			return fs.SkipDir
+		})
+	}
+	return ids
+}
+
 func strconvPID(pid uint32) string {
 	return strconv.FormatUint(uint64(pid), 10)
 }
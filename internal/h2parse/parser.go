@@ -0,0 +1,332 @@
+package h2parse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+const (
+	frameHeaderLen = 9
+
+	frameData         = 0x0
+	frameHeaders      = 0x1
+	framePriority     = 0x2
+	frameRstStream    = 0x3
+	frameSettings     = 0x4
+	framePushPromise  = 0x5
+	framePing         = 0x6
+	frameGoAway       = 0x7
+	frameWindowUpdate = 0x8
+	frameContinuation = 0x9
+
+	flagEndStream  = 0x1
+	flagAck        = 0x1
+	flagEndHeaders = 0x4
+	flagPadded     = 0x8
+	flagPriority   = 0x20
+
+	headerTableSize = 4096
+)
+
+var Preface = []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+var (
+	errNotFrame = errors.New("data does not start with an HTTP/2 frame")
+	errDesynced = errors.New("header compression state lost")
+)
+
+// Frame is a header block or the end of a stream seen on one direction of a
+// connection. Headers is nil for frames that only end or reset the stream.
+type Frame struct {
+	StreamID  uint32
+	Headers   []hpack.HeaderField
+	EndStream bool
+	Reset     bool
+}
+
+// Decoder parses the frames sent in one direction of a connection. HPACK
+// state is shared by all streams of that direction, so every captured chunk
+// must be passed in order. Once a header block cannot be decoded, the
+// dynamic table no longer matches the peer's and every later block of the
+// connection would decode wrongly, so the decoder gives up for good.
+type Decoder struct {
+	hpack    *hpack.Decoder
+	fields   []hpack.HeaderField
+	block    uint32
+	blockEnd bool
+	failed   bool
+	// skip is what remains of a frame that ran past the end of the last
+	// read or write.
+	skip int
+}
+
+func NewDecoder() *Decoder {
+	d := &Decoder{}
+	d.hpack = hpack.NewDecoder(headerTableSize, d.emit)
+	return d
+}
+
+func (d *Decoder) emit(f hpack.HeaderField) {
+	d.fields = append(d.fields, f)
+}
+
+// Parse returns the frames of interest in data, the captured start of a read
+// or write of size bytes; a smaller size means data is all of it. A frame
+// that runs past the end of the write continues in the next one, which is
+// skipped up to where the frame ends. A frame cut off by the capture instead
+// hides the frames after it, and one of those may have changed the HPACK
+// table, so that fails the decoder, as does any block it cannot decode.
+func (d *Decoder) Parse(data []byte, size int) ([]Frame, error) {
+	if d.failed {
+		return nil, errDesynced
+	}
+	size = max(size, len(data))
+	if d.skip > 0 {
+		skip := min(d.skip, size)
+		d.skip -= skip
+		if skip >= len(data) {
+			if skip < size {
+				d.fail()
+				return nil, errDesynced
+			}
+			return nil, nil
+		}
+		data, size = data[skip:], size-skip
+	}
+	if bytes.HasPrefix(data, Preface) {
+		data, size = data[len(Preface):], size-len(Preface)
+	}
+	if !looksLikeFrame(data) {
+		return nil, errNotFrame
+	}
+
+	var frames []Frame
+	for len(data) > 0 {
+		if len(data) < frameHeaderLen {
+			if len(data) < size {
+				d.fail()
+				return frames, errDesynced
+			}
+			return frames, nil
+		}
+		length := int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+		typ := data[3]
+		flags := data[4]
+		streamID := binary.BigEndian.Uint32(data[5:]) & 0x7fffffff
+		end := frameHeaderLen + length
+		size -= end
+		if len(data) < end {
+			if typ == frameHeaders || typ == frameContinuation || size > 0 {
+				d.fail()
+				return frames, errDesynced
+			}
+			d.skip = -size
+			end = len(data)
+		}
+		payload := data[frameHeaderLen:end]
+		data = data[end:]
+
+		switch typ {
+		case frameData:
+			if flags&flagEndStream != 0 && streamID != 0 {
+				frames = append(frames, Frame{StreamID: streamID, EndStream: true})
+			}
+		case frameRstStream:
+			frames = append(frames, Frame{StreamID: streamID, EndStream: true, Reset: true})
+		case frameHeaders:
+			fragment, ok := headerFragment(payload, flags)
+			if !ok {
+				d.fail()
+				return frames, errors.New("malformed HEADERS frame")
+			}
+			d.block = streamID
+			d.blockEnd = flags&flagEndStream != 0
+			d.fields = nil
+			frame, done, err := d.write(fragment, flags&flagEndHeaders != 0)
+			if err != nil {
+				return frames, err
+			}
+			if done {
+				frames = append(frames, frame)
+			}
+		case frameContinuation:
+			if streamID != d.block {
+				continue
+			}
+			frame, done, err := d.write(payload, flags&flagEndHeaders != 0)
+			if err != nil {
+				return frames, err
+			}
+			if done {
+				frames = append(frames, frame)
+			}
+		}
+	}
+	if size > 0 {
+		d.fail()
+		return frames, errDesynced
+	}
+	return frames, nil
+}
+
+func (d *Decoder) write(fragment []byte, end bool) (Frame, bool, error) {
+	if _, err := d.hpack.Write(fragment); err != nil {
+		d.fail()
+		return Frame{}, false, err
+	}
+	if !end {
+		return Frame{}, false, nil
+	}
+	if err := d.hpack.Close(); err != nil {
+		d.fail()
+		return Frame{}, false, err
+	}
+	frame := Frame{StreamID: d.block, Headers: d.fields, EndStream: d.blockEnd}
+	d.fields = nil
+	d.block = 0
+	return frame, true, nil
+}
+
+// Failed reports whether the decoder gave up on the connection.
+func (d *Decoder) Failed() bool {
+	return d.failed
+}
+
+func (d *Decoder) fail() {
+	d.failed = true
+	d.fields = nil
+	d.block = 0
+}
+
+func headerFragment(payload []byte, flags uint8) ([]byte, bool) {
+	pad := 0
+	if flags&flagPadded != 0 {
+		if len(payload) < 1 {
+			return nil, false
+		}
+		pad = int(payload[0])
+		payload = payload[1:]
+	}
+	if flags&flagPriority != 0 {
+		if len(payload) < 5 {
+			return nil, false
+		}
+		payload = payload[5:]
+	}
+	if pad > len(payload) {
+		return nil, false
+	}
+	return payload[:len(payload)-pad], true
+}
+
+// looksLikeFrame rejects chunks that start in the middle of a frame, which
+// happens when a large frame spans several writes. Every frame header in
+// data must be valid for its type, which payload bytes rarely are.
+func looksLikeFrame(data []byte) bool {
+	if len(data) < frameHeaderLen {
+		return false
+	}
+	for len(data) >= frameHeaderLen {
+		length := int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+		if !validFrameHeader(length, data[3], data[4], binary.BigEndian.Uint32(data[5:])) {
+			return false
+		}
+		if len(data) < frameHeaderLen+length {
+			break
+		}
+		data = data[frameHeaderLen+length:]
+	}
+	return true
+}
+
+// validFrameHeader checks the stream, flags and length rules of RFC 9113
+// section 6 for a frame header.
+func validFrameHeader(length int, typ, flags uint8, stream uint32) bool {
+	if stream&0x80000000 != 0 {
+		return false
+	}
+	switch typ {
+	case frameData:
+		return stream != 0 && flags&^(flagEndStream|flagPadded) == 0
+	case frameHeaders:
+		return stream != 0 && flags&^(flagEndStream|flagEndHeaders|flagPadded|flagPriority) == 0
+	case framePriority:
+		return stream != 0 && flags == 0 && length == 5
+	case frameRstStream:
+		return stream != 0 && flags == 0 && length == 4
+	case frameSettings:
+		if flags == flagAck {
+			return stream == 0 && length == 0
+		}
+		return stream == 0 && flags == 0 && length%6 == 0
+	case framePushPromise:
+		return stream != 0 && flags&^(flagEndHeaders|flagPadded) == 0 && length >= 4
+	case framePing:
+		return stream == 0 && flags&^flagAck == 0 && length == 8
+	case frameGoAway:
+		return stream == 0 && flags == 0 && length >= 8
+	case frameWindowUpdate:
+		return flags == 0 && length == 4
+	case frameContinuation:
+		return stream != 0 && flags&^flagEndHeaders == 0
+	}
+	return false
+}
+
+func Header(fields []hpack.HeaderField, name string) string {
+	for _, f := range fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+func Status(fields []hpack.HeaderField) (uint32, bool) {
+	raw := Header(fields, ":status")
+	if raw == "" {
+		return 0, false
+	}
+	status, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(status), true
+}
+
+// GRPCStatus returns the grpc-status of a response or trailer block.
+func GRPCStatus(fields []hpack.HeaderField) (uint32, bool) {
+	raw := Header(fields, "grpc-status")
+	if raw == "" {
+		return 0, false
+	}
+	status, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(status), true
+}
+
+var grpcStatusNames = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// GRPCStatusName returns the name of a gRPC status code.
+func GRPCStatusName(status uint32) string {
+	if int(status) < len(grpcStatusNames) {
+		return grpcStatusNames[status]
+	}
+	return "CODE" + strconv.FormatUint(uint64(status), 10)
+}
+
+func IsGRPC(fields []hpack.HeaderField) bool {
+	return strings.HasPrefix(Header(fields, "content-type"), "application/grpc")
+}
@@ -0,0 +1,177 @@
+package h2parse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+func frame(typ, flags uint8, stream uint32, payload []byte) []byte {
+	b := []byte{byte(len(payload) >> 16), byte(len(payload) >> 8), byte(len(payload)), typ, flags}
+	b = binary.BigEndian.AppendUint32(b, stream)
+	return append(b, payload...)
+}
+
+func encode(enc *hpack.Encoder, buf *bytes.Buffer, fields ...string) []byte {
+	buf.Reset()
+	for i := 0; i < len(fields); i += 2 {
+		enc.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]})
+	}
+	return append([]byte(nil), buf.Bytes()...)
+}
+
+func TestParseRequestHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+	dec := NewDecoder()
+
+	first := encode(enc, &buf, ":method", "POST", ":path", "/orders.Orders/Get", "content-type", "application/grpc")
+	data := append([]byte(nil), Preface...)
+	data = append(data, frame(0x4, 0, 0, nil)...)
+	data = append(data, frame(frameHeaders, flagEndHeaders, 1, first)...)
+	data = append(data, frame(frameData, flagEndStream, 1, []byte{0, 0, 0, 0, 0})...)
+
+	frames, err := dec.Parse(data, 0)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	if Header(frames[0].Headers, ":path") != "/orders.Orders/Get" || !IsGRPC(frames[0].Headers) {
+		t.Fatalf("unexpected headers %v", frames[0].Headers)
+	}
+	if !frames[1].EndStream || frames[1].Headers != nil {
+		t.Fatalf("expected END_STREAM data frame, got %+v", frames[1])
+	}
+
+	// The second request reuses dynamic table entries from the first, split
+	// over HEADERS and CONTINUATION.
+	second := encode(enc, &buf, ":method", "POST", ":path", "/orders.Orders/Get", "content-type", "application/grpc")
+	data = frame(frameHeaders, flagEndStream, 3, second[:1])
+	data = append(data, frame(frameContinuation, flagEndHeaders, 3, second[1:])...)
+	frames, err = dec.Parse(data, 0)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(frames) != 1 || frames[0].StreamID != 3 || !frames[0].EndStream {
+		t.Fatalf("unexpected frames %+v", frames)
+	}
+	if Header(frames[0].Headers, ":method") != "POST" {
+		t.Fatalf("dynamic table lookup failed: %v", frames[0].Headers)
+	}
+}
+
+func TestParseTrailers(t *testing.T) {
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+	dec := NewDecoder()
+
+	data := frame(frameHeaders, flagEndHeaders, 1, encode(enc, &buf, ":status", "200", "content-type", "application/grpc"))
+	data = append(data, frame(frameHeaders, flagEndHeaders|flagEndStream, 1, encode(enc, &buf, "grpc-status", "5"))...)
+	frames, err := dec.Parse(data, 0)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	if status, ok := Status(frames[0].Headers); !ok || status != 200 {
+		t.Fatalf("status = %d %v", status, ok)
+	}
+	if status, ok := GRPCStatus(frames[1].Headers); !ok || status != 5 || !frames[1].EndStream {
+		t.Fatalf("grpc-status = %d %v", status, ok)
+	}
+	if GRPCStatusName(5) != "NOT_FOUND" || GRPCStatusName(99) != "CODE99" {
+		t.Fatalf("unexpected status names %s %s", GRPCStatusName(5), GRPCStatusName(99))
+	}
+}
+
+func TestParseRejectsMidFrame(t *testing.T) {
+	if _, err := NewDecoder().Parse([]byte("continuation of a large DATA frame"), 0); err == nil {
+		t.Fatalf("expected error for data that is not a frame")
+	}
+	// A DATA payload whose first bytes read like a HEADERS frame on stream 0.
+	if _, err := NewDecoder().Parse([]byte("\x00\x00\x05\x01\x04\x00\x00\x00\x00hello"), 0); err == nil {
+		t.Fatalf("expected error for a frame header that is invalid for its type")
+	}
+	data := frame(frameData, 0, 1, []byte("ab"))
+	data = append(data, "\x00\x00\x04\x03\x00\x00\x00"...)
+	if _, err := NewDecoder().Parse(data, 0); err != nil {
+		t.Fatalf("expected a valid chunk ending in a partial header, got %v", err)
+	}
+	data = append(frame(frameData, 0, 1, []byte("ab")), frame(framePing, 0, 1, make([]byte, 8))...)
+	if _, err := NewDecoder().Parse(data, 0); err == nil {
+		t.Fatalf("expected error for an invalid frame after a valid one")
+	}
+}
+
+func TestParseTruncatedHeaderBlock(t *testing.T) {
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+	dec := NewDecoder()
+
+	block := encode(enc, &buf, ":method", "GET", ":path", "/a", "x-custom", "value")
+	data := frame(frameHeaders, flagEndHeaders|flagEndStream, 1, block)
+	if _, err := dec.Parse(data[:len(data)-3], 0); err == nil {
+		t.Fatalf("expected a cut-off header block to fail")
+	}
+
+	data = frame(frameHeaders, flagEndHeaders|flagEndStream, 3, encode(enc, &buf, ":method", "GET", ":path", "/b"))
+	if frames, err := dec.Parse(data, 0); err == nil || len(frames) != 0 {
+		t.Fatalf("expected the decoder to stop after losing its table, got %+v %v", frames, err)
+	}
+}
+
+func TestParseFrameAcrossWrites(t *testing.T) {
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+	dec := NewDecoder()
+
+	body := bytes.Repeat([]byte("x"), 100)
+	first := frame(frameHeaders, flagEndHeaders, 1, encode(enc, &buf, ":method", "POST", ":path", "/a"))
+	first = append(first, frame(frameData, flagEndStream, 1, body)...)
+	second := frame(frameHeaders, flagEndHeaders|flagEndStream, 3, encode(enc, &buf, ":method", "GET", ":path", "/b"))
+
+	// The DATA frame is split over two writes; the second one starts with
+	// the last 40 bytes of its payload.
+	frames, err := dec.Parse(first[:len(first)-40], len(first)-40)
+	if err != nil || len(frames) != 2 || !frames[1].EndStream {
+		t.Fatalf("first write: %+v %v", frames, err)
+	}
+	frames, err = dec.Parse(append(first[len(first)-40:], second...), 40+len(second))
+	if err != nil || len(frames) != 1 || Header(frames[0].Headers, ":path") != "/b" {
+		t.Fatalf("second write: %+v %v", frames, err)
+	}
+}
+
+func TestParseCapturedPrefix(t *testing.T) {
+	var buf bytes.Buffer
+	enc := hpack.NewEncoder(&buf)
+
+	body := bytes.Repeat([]byte("x"), 100)
+	data := frame(frameHeaders, flagEndHeaders, 1, encode(enc, &buf, ":status", "200"))
+	data = append(data, frame(frameData, flagEndStream, 1, body)...)
+
+	// A capture that stops inside a DATA frame ending the write loses
+	// nothing that matters.
+	dec := NewDecoder()
+	frames, err := dec.Parse(data[:len(data)-50], len(data))
+	if err != nil || len(frames) != 2 || dec.Failed() {
+		t.Fatalf("cut-off final frame: %+v %v", frames, err)
+	}
+
+	// Anything written after the cut-off frame may have been a header block.
+	dec = NewDecoder()
+	trailers := frame(frameHeaders, flagEndHeaders|flagEndStream, 1, encode(enc, &buf, "grpc-status", "0"))
+	if _, err := dec.Parse(data[:len(data)-50], len(data)+len(trailers)); err == nil || !dec.Failed() {
+		t.Fatalf("expected frames lost after a cut-off frame to fail the decoder, got %v", err)
+	}
+
+	dec = NewDecoder()
+	if _, err := dec.Parse(data, len(data)+len(trailers)); err == nil || !dec.Failed() {
+		t.Fatalf("expected frames lost after a complete frame to fail the decoder, got %v", err)
+	}
+}
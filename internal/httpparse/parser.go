@@ -32,6 +32,268 @@ func ParseRequestLine(data []byte) (string, string, bool) {
 	return method, path, true
 }
 
+// Header holds headers by lowercased name. Repeated headers are joined
+// with ", ".
+type Header map[string]string
+
+func (h Header) Get(name string) string {
+	return h[strings.ToLower(name)]
+}
+
+// ContentLength returns the Content-Length header, or 0 when it is missing
+// or not a number.
+func (h Header) ContentLength() uint64 {
+	n, err := strconv.ParseUint(h.Get("content-length"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Raw holds the captured bytes of the message, from its start line to the
+// end of its body or of the capture.
+type Request struct {
+	Method string
+	Path   string
+	Header Header
+	Raw    []byte
+}
+
+type Response struct {
+	Status uint32
+	Header Header
+	Raw    []byte
+}
+
+// ParseRequest reads the request line and the headers that follow it.
+func ParseRequest(data []byte) (Request, bool) {
+	method, path, ok := ParseRequestLine(data)
+	if !ok {
+		return Request{}, false
+	}
+	return Request{Method: method, Path: path, Header: parseHeaders(data)}, true
+}
+
+// ParseResponse reads the status line and the headers that follow it.
+func ParseResponse(data []byte) (Response, bool) {
+	status, ok := ParseResponseLine(data)
+	if !ok {
+		return Response{}, false
+	}
+	return Response{Status: status, Header: parseHeaders(data)}, true
+}
+
+// ParseRequests reads the requests a client pipelined into data. Scanning
+// stops after a message whose end cannot be found in data.
+func ParseRequests(data []byte) []Request {
+	var requests []Request
+	for {
+		data = skipBlankLines(data)
+		req, ok := ParseRequest(data)
+		if !ok {
+			return requests
+		}
+		n, complete := messageLength(data, req.Header, 0, "")
+		req.Raw = data[:n]
+		requests = append(requests, req)
+		if !complete {
+			return requests
+		}
+		data = data[n:]
+	}
+}
+
+// ParseResponses reads the responses a server sent back to back in data.
+// methods holds the methods of the requests they answer, in order, since
+// the responses to HEAD requests have no body whatever their headers say.
+// Scanning stops after a message whose end cannot be found in data, which
+// includes a body delimited by the connection closing.
+func ParseResponses(data []byte, methods []string) []Response {
+	var responses []Response
+	for {
+		data = skipBlankLines(data)
+		resp, ok := ParseResponse(data)
+		if !ok {
+			return responses
+		}
+		method := ""
+		if resp.Status >= 200 || resp.Status == 101 {
+			if len(methods) > 0 {
+				method = methods[0]
+				methods = methods[1:]
+			}
+		}
+		n, complete := messageLength(data, resp.Header, resp.Status, method)
+		resp.Raw = data[:n]
+		responses = append(responses, resp)
+		if !complete {
+			return responses
+		}
+		data = data[n:]
+	}
+}
+
+// messageLength returns the length of the message at the start of data and
+// whether all of it was captured. status is 0 for requests, which have no
+// body unless they declare one. method is the method of the request a
+// response answers, if known.
+func messageLength(data []byte, header Header, status uint32, method string) (int, bool) {
+	end := headerEnd(data)
+	if end < 0 {
+		return len(data), false
+	}
+	body := data[end:]
+	var n int
+	var ok bool
+	switch {
+	case status >= 100 && status < 200, status == 204, status == 304:
+		n, ok = 0, true
+	case status != 0 && strings.EqualFold(method, "HEAD"):
+		n, ok = 0, true
+	case strings.Contains(strings.ToLower(header.Get("transfer-encoding")), "chunked"):
+		n, ok = chunkedLength(body)
+	case header.Get("content-length") != "":
+		length, err := strconv.ParseUint(header.Get("content-length"), 10, 31)
+		if err != nil {
+			return len(data), false
+		}
+		n, ok = int(length), true
+	case status == 0:
+		n, ok = 0, true
+	default:
+		return len(data), false
+	}
+	if !ok || n > len(body) {
+		return len(data), false
+	}
+	return end + n, true
+}
+
+// headerEnd returns the offset just past the blank line that ends the
+// header block, or -1 when it was not captured.
+func headerEnd(data []byte) int {
+	pos := 0
+	for {
+		idx := bytes.IndexByte(data[pos:], '\n')
+		if idx < 0 {
+			return -1
+		}
+		line := bytes.TrimRight(data[pos:pos+idx], "\r")
+		pos += idx + 1
+		if len(line) == 0 {
+			return pos
+		}
+	}
+}
+
+// chunkedLength returns the length of a chunked body, trailers included.
+func chunkedLength(body []byte) (int, bool) {
+	pos := 0
+	for {
+		idx := bytes.IndexByte(body[pos:], '\n')
+		if idx < 0 {
+			return 0, false
+		}
+		line := bytes.TrimSpace(body[pos : pos+idx])
+		pos += idx + 1
+		if ext := bytes.IndexByte(line, ';'); ext >= 0 {
+			line = bytes.TrimSpace(line[:ext])
+		}
+		size, err := strconv.ParseUint(string(line), 16, 31)
+		if err != nil {
+			return 0, false
+		}
+		if size == 0 {
+			break
+		}
+		pos += int(size)
+		if pos > len(body) {
+			return 0, false
+		}
+		switch {
+		case bytes.HasPrefix(body[pos:], []byte("\r\n")):
+			pos += 2
+		case bytes.HasPrefix(body[pos:], []byte("\n")):
+			pos++
+		default:
+			return 0, false
+		}
+	}
+	for {
+		idx := bytes.IndexByte(body[pos:], '\n')
+		if idx < 0 {
+			return 0, false
+		}
+		line := bytes.TrimRight(body[pos:pos+idx], "\r")
+		pos += idx + 1
+		if len(line) == 0 {
+			return pos, true
+		}
+	}
+}
+
+func skipBlankLines(data []byte) []byte {
+	for len(data) > 0 && (data[0] == '\r' || data[0] == '\n') {
+		data = data[1:]
+	}
+	return data
+}
+
+// parseHeaders reads the header lines after the start line of data. Lines
+// starting with a space or tab continue the previous header (obsolete line
+// folding). Only complete lines are read, so a capture cut off inside the
+// headers still yields the ones before the cut; a header whose folded
+// continuation is cut off is dropped rather than stored half.
+func parseHeaders(data []byte) Header {
+	header := Header{}
+	idx := bytes.IndexByte(data, '\n')
+	if idx < 0 {
+		return header
+	}
+	rest := data[idx+1:]
+
+	var name, value string
+	flush := func() {
+		if name == "" {
+			return
+		}
+		if prev, ok := header[name]; ok {
+			value = prev + ", " + value
+		}
+		header[name] = value
+		name = ""
+	}
+	for {
+		end := bytes.IndexByte(rest, '\n')
+		if end < 0 {
+			if len(rest) == 0 || (rest[0] != ' ' && rest[0] != '\t') {
+				flush()
+			}
+			break
+		}
+		line := bytes.TrimRight(rest[:end], "\r")
+		rest = rest[end+1:]
+		if len(line) == 0 {
+			flush()
+			break
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if name != "" {
+				value = strings.TrimSpace(value + " " + string(bytes.TrimSpace(line)))
+			}
+			continue
+		}
+		flush()
+		key, val, found := bytes.Cut(line, []byte(":"))
+		if !found {
+			continue
+		}
+		name = strings.ToLower(string(bytes.TrimSpace(key)))
+		value = string(bytes.TrimSpace(val))
+	}
+	return header
+}
+
 func ParseResponseLine(data []byte) (uint32, bool) {
 	line := firstLine(data)
 	fields := bytes.Fields(line)
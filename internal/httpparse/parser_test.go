@@ -30,3 +30,88 @@ func TestParseInvalid(t *testing.T) {
 		t.Fatalf("expected response parse to fail")
 	}
 }
+
+func TestParseRequestHeaders(t *testing.T) {
+	req, ok := ParseRequest([]byte("GET / HTTP/1.1\r\nHost: example\r\nAccept: a\r\naccept: b\r\nX-Cut: par"))
+	if !ok || req.Header.Get("Host") != "example" || req.Header.Get("accept") != "a, b" {
+		t.Fatalf("unexpected headers %+v", req.Header)
+	}
+	if _, ok := req.Header["x-cut"]; ok {
+		t.Fatalf("expected the cut-off header line to be skipped")
+	}
+}
+
+func TestParseFoldedHeaders(t *testing.T) {
+	req, _ := ParseRequest([]byte("POST / HTTP/1.1\r\nX-Long: one\r\n  two\r\nContent-Length: 12\r\n\r\nbody"))
+	if req.Header.Get("x-long") != "one two" || req.Header.ContentLength() != 12 {
+		t.Fatalf("unexpected headers %+v", req.Header)
+	}
+
+	req, _ = ParseRequest([]byte("GET / HTTP/1.1\r\nHost: a\r\nX-Cut: one\r\n tw"))
+	if _, ok := req.Header["x-cut"]; ok || req.Header.Get("host") != "a" {
+		t.Fatalf("expected a header with a cut-off continuation to be dropped, got %+v", req.Header)
+	}
+}
+
+func TestParseResponseHeaders(t *testing.T) {
+	resp, ok := ParseResponse([]byte("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: x\r\n\r\n{}"))
+	if !ok || resp.Status != 200 || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response %+v %v", resp, ok)
+	}
+	if resp.Header.ContentLength() != 0 {
+		t.Fatalf("expected an invalid Content-Length to read as 0")
+	}
+}
+
+func TestParsePipelinedRequests(t *testing.T) {
+	data := []byte("POST /a HTTP/1.1\r\nContent-Length: 2\r\n\r\n{}" +
+		"GET /b HTTP/1.1\r\nHost: x\r\n\r\n" +
+		"PUT /c HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nab\r\n0\r\n\r\n" +
+		"GET /d HTTP/1.1\r\nHo")
+	reqs := ParseRequests(data)
+	if len(reqs) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(reqs))
+	}
+	for i, path := range []string{"/a", "/b", "/c", "/d"} {
+		if reqs[i].Path != path {
+			t.Fatalf("request %d: expected %s, got %s", i, path, reqs[i].Path)
+		}
+	}
+	if string(reqs[0].Raw) != "POST /a HTTP/1.1\r\nContent-Length: 2\r\n\r\n{}" {
+		t.Fatalf("unexpected first message %q", reqs[0].Raw)
+	}
+
+	reqs = ParseRequests([]byte("POST /a HTTP/1.1\r\nContent-Length: 10\r\n\r\n{}GET /b HTTP/1.1\r\n\r\n"))
+	if len(reqs) != 1 {
+		t.Fatalf("expected a cut-off body to end the scan, got %d requests", len(reqs))
+	}
+}
+
+func TestParsePipelinedResponses(t *testing.T) {
+	data := []byte("HTTP/1.1 100 Continue\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok" +
+		"HTTP/1.1 204 No Content\r\n\r\n" +
+		"HTTP/1.0 500 Internal Server Error\r\n\r\nclosed")
+	resps := ParseResponses(data, nil)
+	if len(resps) != 4 {
+		t.Fatalf("expected 4 responses, got %d", len(resps))
+	}
+	for i, status := range []uint32{100, 200, 204, 500} {
+		if resps[i].Status != status {
+			t.Fatalf("response %d: expected %d, got %d", i, status, resps[i].Status)
+		}
+	}
+}
+
+func TestParseHeadResponses(t *testing.T) {
+	data := []byte("HTTP/1.1 100 Continue\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nContent-Length: 512\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+	resps := ParseResponses(data, []string{"HEAD", "GET"})
+	if len(resps) != 3 {
+		t.Fatalf("expected 3 responses, got %d", len(resps))
+	}
+	if string(resps[1].Raw) != "HTTP/1.1 200 OK\r\nContent-Length: 512\r\n\r\n" {
+		t.Fatalf("expected the HEAD response to end after its headers, got %q", resps[1].Raw)
+	}
+}
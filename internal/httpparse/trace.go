@@ -0,0 +1,109 @@
+package httpparse
+
+import "strings"
+
+// TraceContext is the trace a request belongs to, taken from W3C
+// traceparent or B3 headers. TraceID is 32 lowercase hex digits; 64-bit B3
+// trace IDs are left-padded with zeros.
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// ParseTraceContext reads the trace context through get, which looks up a
+// header by lowercase name. traceparent takes precedence over B3 single and
+// multi-header propagation.
+func ParseTraceContext(get func(name string) string) (TraceContext, bool) {
+	if tc, ok := parseTraceparent(get("traceparent")); ok {
+		return tc, true
+	}
+	if tc, ok := parseB3Single(get("b3")); ok {
+		return tc, true
+	}
+	return parseB3Multi(get)
+}
+
+// parseTraceparent reads "version-traceid-parentid-flags".
+func parseTraceparent(value string) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return TraceContext{}, false
+	}
+	if parts[0] == "00" && len(parts) != 4 {
+		return TraceContext{}, false
+	}
+	traceID, ok := hexID(parts[1], 32)
+	if !ok {
+		return TraceContext{}, false
+	}
+	spanID, ok := hexID(parts[2], 16)
+	if !ok || !isHex(parts[3]) {
+		return TraceContext{}, false
+	}
+	flags := strings.ToLower(parts[3])
+	sampled := strings.IndexByte("13579bdf", flags[1]) >= 0
+	return TraceContext{TraceID: traceID, SpanID: spanID, Sampled: sampled}, true
+}
+
+// parseB3Single reads "traceid-spanid[-sampled[-parentspanid]]". A lone
+// sampling decision carries no IDs and is ignored.
+func parseB3Single(value string) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 2 {
+		return TraceContext{}, false
+	}
+	tc, ok := b3IDs(parts[0], parts[1])
+	if !ok {
+		return TraceContext{}, false
+	}
+	if len(parts) > 2 {
+		tc.Sampled = parts[2] == "1" || parts[2] == "d"
+	}
+	return tc, true
+}
+
+func parseB3Multi(get func(name string) string) (TraceContext, bool) {
+	tc, ok := b3IDs(get("x-b3-traceid"), get("x-b3-spanid"))
+	if !ok {
+		return TraceContext{}, false
+	}
+	sampled := strings.TrimSpace(get("x-b3-sampled"))
+	tc.Sampled = sampled == "1" || strings.EqualFold(sampled, "true") || strings.TrimSpace(get("x-b3-flags")) == "1"
+	return tc, true
+}
+
+func b3IDs(trace, span string) (TraceContext, bool) {
+	trace = strings.TrimSpace(trace)
+	if len(trace) == 16 {
+		trace = strings.Repeat("0", 16) + trace
+	}
+	traceID, ok := hexID(trace, 32)
+	if !ok {
+		return TraceContext{}, false
+	}
+	spanID, ok := hexID(strings.TrimSpace(span), 16)
+	if !ok {
+		return TraceContext{}, false
+	}
+	return TraceContext{TraceID: traceID, SpanID: spanID}, true
+}
+
+// hexID lowercases an ID of the given length, rejecting the all-zero ID
+// that both formats reserve as invalid.
+func hexID(value string, length int) (string, bool) {
+	if len(value) != length || !isHex(value) || strings.Trim(value, "0") == "" {
+		return "", false
+	}
+	return strings.ToLower(value), true
+}
+
+func isHex(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package httpparse
+
+import "testing"
+
+func headers(pairs ...string) func(string) string {
+	h := Header{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		h[pairs[i]] = pairs[i+1]
+	}
+	return h.Get
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tc, ok := ParseTraceContext(headers("traceparent", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"))
+	if !ok || tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.SpanID != "00f067aa0ba902b7" || !tc.Sampled {
+		t.Fatalf("unexpected trace context %+v %v", tc, ok)
+	}
+
+	if _, ok := ParseTraceContext(headers("traceparent", "00-00000000000000000000000000000000-00f067aa0ba902b7-01")); ok {
+		t.Fatalf("expected an all-zero trace ID to be rejected")
+	}
+}
+
+func TestParseB3(t *testing.T) {
+	tc, ok := ParseTraceContext(headers("x-b3-traceid", "a3ce929d0e0e4736", "x-b3-spanid", "00f067aa0ba902b7", "x-b3-sampled", "1"))
+	if !ok || tc.TraceID != "0000000000000000a3ce929d0e0e4736" || !tc.Sampled {
+		t.Fatalf("unexpected multi-header context %+v %v", tc, ok)
+	}
+
+	tc, ok = ParseTraceContext(headers("b3", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-0-05e3ac9a4f6e3b90"))
+	if !ok || tc.SpanID != "e457b5a2e4d86bd1" || tc.Sampled {
+		t.Fatalf("unexpected single-header context %+v %v", tc, ok)
+	}
+
+	if _, ok := ParseTraceContext(headers("b3", "0")); ok {
+		t.Fatalf("expected a bare sampling decision to carry no context")
+	}
+}
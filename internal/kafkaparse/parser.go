@@ -0,0 +1,345 @@
+package kafkaparse
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+const (
+	APIProduce = 0
+	APIFetch   = 1
+
+	// First versions of Produce and Fetch with compact types and tags.
+	produceFlexible = 9
+	fetchFlexible   = 12
+
+	maxMessageLen = 1 << 30
+)
+
+var apiNames = map[int16]string{
+	0:  "Produce",
+	1:  "Fetch",
+	2:  "ListOffsets",
+	3:  "Metadata",
+	8:  "OffsetCommit",
+	9:  "OffsetFetch",
+	10: "FindCoordinator",
+	11: "JoinGroup",
+	12: "Heartbeat",
+	13: "LeaveGroup",
+	14: "SyncGroup",
+	15: "DescribeGroups",
+	16: "ListGroups",
+	17: "SaslHandshake",
+	18: "ApiVersions",
+	19: "CreateTopics",
+	20: "DeleteTopics",
+	21: "DeleteRecords",
+	22: "InitProducerId",
+	23: "OffsetForLeaderEpoch",
+	24: "AddPartitionsToTxn",
+	25: "AddOffsetsToTxn",
+	26: "EndTxn",
+	28: "TxnOffsetCommit",
+	32: "DescribeConfigs",
+	33: "AlterConfigs",
+	36: "SaslAuthenticate",
+	37: "CreatePartitions",
+	42: "DeleteGroups",
+	47: "OffsetDelete",
+	60: "DescribeCluster",
+	61: "DescribeProducers",
+	68: "ConsumerGroupHeartbeat",
+}
+
+var errorNames = map[int16]string{
+	-1:  "UNKNOWN_SERVER_ERROR",
+	1:   "OFFSET_OUT_OF_RANGE",
+	2:   "CORRUPT_MESSAGE",
+	3:   "UNKNOWN_TOPIC_OR_PARTITION",
+	5:   "LEADER_NOT_AVAILABLE",
+	6:   "NOT_LEADER_OR_FOLLOWER",
+	7:   "REQUEST_TIMED_OUT",
+	10:  "MESSAGE_TOO_LARGE",
+	14:  "COORDINATOR_LOAD_IN_PROGRESS",
+	15:  "COORDINATOR_NOT_AVAILABLE",
+	16:  "NOT_COORDINATOR",
+	19:  "NOT_ENOUGH_REPLICAS",
+	20:  "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	25:  "UNKNOWN_MEMBER_ID",
+	27:  "REBALANCE_IN_PROGRESS",
+	29:  "TOPIC_AUTHORIZATION_FAILED",
+	30:  "GROUP_AUTHORIZATION_FAILED",
+	31:  "CLUSTER_AUTHORIZATION_FAILED",
+	35:  "UNSUPPORTED_VERSION",
+	36:  "TOPIC_ALREADY_EXISTS",
+	45:  "OUT_OF_ORDER_SEQUENCE_NUMBER",
+	46:  "DUPLICATE_SEQUENCE_NUMBER",
+	47:  "INVALID_PRODUCER_EPOCH",
+	58:  "SASL_AUTHENTICATION_FAILED",
+	74:  "FENCED_LEADER_EPOCH",
+	75:  "UNKNOWN_LEADER_EPOCH",
+	100: "UNKNOWN_TOPIC_ID",
+}
+
+func APIName(key int16) string {
+	if name, ok := apiNames[key]; ok {
+		return name
+	}
+	return "ApiKey(" + strconv.Itoa(int(key)) + ")"
+}
+
+func ErrorName(code int16) string {
+	if name, ok := errorNames[code]; ok {
+		return name
+	}
+	return strconv.Itoa(int(code))
+}
+
+// Request is a request header and, for Produce and Fetch, the topics it
+// touches; v13 and later name topics by ID only. Produce with acks=0 is
+// never answered.
+type Request struct {
+	APIKey        int16
+	APIVersion    int16
+	CorrelationID int32
+	ClientID      string
+	Topics        []string
+	NoResponse    bool
+}
+
+type Response struct {
+	CorrelationID int32
+	ErrorCode     int16
+}
+
+// Framer splits one direction of a connection into messages. Java clients
+// and brokers read and write the 4-byte size separately from the message,
+// so a chunk holding only a size means the next chunk has none.
+type Framer struct {
+	sized bool
+}
+
+func (f *Framer) Messages(data []byte) [][]byte {
+	if len(data) == 4 {
+		f.sized = true
+		return nil
+	}
+	if f.sized {
+		f.sized = false
+		return [][]byte{data}
+	}
+
+	var messages [][]byte
+	for len(data) > 4 {
+		size := int(binary.BigEndian.Uint32(data))
+		if size <= 0 || size > maxMessageLen {
+			break
+		}
+		data = data[4:]
+		if size >= len(data) {
+			return append(messages, data)
+		}
+		messages = append(messages, data[:size])
+		data = data[size:]
+	}
+	if len(data) == 4 {
+		f.sized = true
+	}
+	return messages
+}
+
+func ParseRequest(msg []byte) (Request, bool) {
+	r := &reader{b: msg}
+	req := Request{
+		APIKey:        r.int16(),
+		APIVersion:    r.int16(),
+		CorrelationID: r.int32(),
+	}
+	req.ClientID = r.string(false)
+	if r.failed || req.APIKey < 0 || req.APIVersion < 0 {
+		return Request{}, false
+	}
+
+	switch req.APIKey {
+	case APIProduce:
+		parseProduceRequest(r, &req)
+	case APIFetch:
+		parseFetchRequest(r, &req)
+	}
+	return req, true
+}
+
+func parseProduceRequest(r *reader, req *Request) {
+	flexible := req.APIVersion >= produceFlexible
+	r.tags(flexible)
+	if req.APIVersion >= 3 {
+		r.string(flexible)
+	}
+	acks := r.int16()
+	req.NoResponse = !r.failed && acks == 0
+	r.int32()
+
+	topics := r.array(flexible)
+	for i := 0; i < topics && !r.failed; i++ {
+		if req.APIVersion >= 13 {
+			addTopic(req, r.uuid(), r.failed)
+		} else {
+			addTopic(req, r.string(flexible), r.failed)
+		}
+		partitions := r.array(flexible)
+		for j := 0; j < partitions && !r.failed; j++ {
+			r.int32()
+			r.bytes(flexible)
+			r.tags(flexible)
+		}
+		r.tags(flexible)
+	}
+}
+
+func parseFetchRequest(r *reader, req *Request) {
+	version := req.APIVersion
+	flexible := version >= fetchFlexible
+	r.tags(flexible)
+	if version <= 14 {
+		r.int32()
+	}
+	r.take(8)
+	if version >= 3 {
+		r.int32()
+	}
+	if version >= 4 {
+		r.int8()
+	}
+	if version >= 7 {
+		r.take(8)
+	}
+
+	partitionLen := 16
+	if version >= 9 {
+		partitionLen += 4
+	}
+	if version >= 12 {
+		partitionLen += 4
+	}
+	if version >= 5 {
+		partitionLen += 8
+	}
+
+	topics := r.array(flexible)
+	for i := 0; i < topics && !r.failed; i++ {
+		if version >= 13 {
+			addTopic(req, r.uuid(), r.failed)
+		} else {
+			addTopic(req, r.string(flexible), r.failed)
+		}
+		partitions := r.array(flexible)
+		for j := 0; j < partitions && !r.failed; j++ {
+			r.take(partitionLen)
+			r.tags(flexible)
+		}
+		r.tags(flexible)
+	}
+}
+
+func addTopic(req *Request, topic string, failed bool) {
+	if failed || topic == "" {
+		return
+	}
+	for _, known := range req.Topics {
+		if known == topic {
+			return
+		}
+	}
+	req.Topics = append(req.Topics, topic)
+}
+
+func CorrelationID(msg []byte) (int32, bool) {
+	if len(msg) < 4 {
+		return 0, false
+	}
+	return int32(binary.BigEndian.Uint32(msg)), true
+}
+
+func ParseResponse(msg []byte, apiKey, apiVersion int16) Response {
+	r := &reader{b: msg}
+	resp := Response{CorrelationID: r.int32()}
+	switch apiKey {
+	case APIProduce:
+		resp.ErrorCode = produceError(r, apiVersion)
+	case APIFetch:
+		resp.ErrorCode = fetchError(r, apiVersion)
+	}
+	return resp
+}
+
+func produceError(r *reader, version int16) int16 {
+	flexible := version >= produceFlexible
+	r.tags(flexible)
+	topics := r.array(flexible)
+	for i := 0; i < topics && !r.failed; i++ {
+		if version >= 13 {
+			r.uuid()
+		} else {
+			r.string(flexible)
+		}
+		partitions := r.array(flexible)
+		for j := 0; j < partitions && !r.failed; j++ {
+			r.int32()
+			if code := r.int16(); code != 0 && !r.failed {
+				return code
+			}
+			r.take(8)
+			if version >= 2 {
+				r.take(8)
+			}
+			if version >= 5 {
+				r.take(8)
+			}
+			if version >= 8 {
+				errors := r.array(flexible)
+				for k := 0; k < errors && !r.failed; k++ {
+					r.int32()
+					r.string(flexible)
+					r.tags(flexible)
+				}
+				r.string(flexible)
+			}
+			r.tags(flexible)
+		}
+		r.tags(flexible)
+	}
+	return 0
+}
+
+// fetchError checks the top-level error and the first partition; the
+// records that follow are too large to be captured whole.
+func fetchError(r *reader, version int16) int16 {
+	flexible := version >= fetchFlexible
+	r.tags(flexible)
+	if version >= 1 {
+		r.int32()
+	}
+	if version >= 7 {
+		if code := r.int16(); code != 0 && !r.failed {
+			return code
+		}
+		r.int32()
+	}
+	if r.array(flexible) == 0 {
+		return 0
+	}
+	if version >= 13 {
+		r.uuid()
+	} else {
+		r.string(flexible)
+	}
+	if r.array(flexible) == 0 {
+		return 0
+	}
+	r.int32()
+	if code := r.int16(); !r.failed {
+		return code
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package kafkaparse
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func sized(msg []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(msg))), msg...)
+}
+
+func str(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func compactStr(b []byte, s string) []byte {
+	b = append(b, byte(len(s)+1))
+	return append(b, s...)
+}
+
+func header(apiKey, version int16, id int32, client string) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(apiKey))
+	b = binary.BigEndian.AppendUint16(b, uint16(version))
+	b = binary.BigEndian.AppendUint32(b, uint32(id))
+	return str(b, client)
+}
+
+func produceV7(acks int16) []byte {
+	b := header(APIProduce, 7, 42, "producer-1")
+	b = binary.BigEndian.AppendUint16(b, 0xffff)
+	b = binary.BigEndian.AppendUint16(b, uint16(acks))
+	b = binary.BigEndian.AppendUint32(b, 30000)
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = str(b, "orders")
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = binary.BigEndian.AppendUint32(b, 3)
+	return append(b, 'a', 'b', 'c')
+}
+
+func TestParseProduceRequest(t *testing.T) {
+	req, ok := ParseRequest(produceV7(1))
+	if !ok || req.APIKey != APIProduce || req.CorrelationID != 42 || req.ClientID != "producer-1" {
+		t.Fatalf("unexpected request %+v %v", req, ok)
+	}
+	if len(req.Topics) != 1 || req.Topics[0] != "orders" || req.NoResponse {
+		t.Fatalf("unexpected topics %+v", req)
+	}
+
+	req, _ = ParseRequest(produceV7(0))
+	if !req.NoResponse {
+		t.Fatalf("expected acks=0 produce to expect no response")
+	}
+}
+
+func TestParseFlexibleFetchRequest(t *testing.T) {
+	b := header(APIFetch, 12, 7, "consumer")
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint32(b, 0xffffffff)
+	b = append(b, make([]byte, 8+4+1+8)...)
+	b = append(b, 3)
+	for _, topic := range []string{"events", "audit"} {
+		b = compactStr(b, topic)
+		b = append(b, 2)
+		b = append(b, make([]byte, 32)...)
+		b = append(b, 0, 0)
+	}
+
+	req, ok := ParseRequest(b)
+	if !ok || req.APIVersion != 12 || len(req.Topics) != 2 || req.Topics[1] != "audit" {
+		t.Fatalf("unexpected request %+v %v", req, ok)
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	b := binary.BigEndian.AppendUint32(nil, 42)
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = str(b, "orders")
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = binary.BigEndian.AppendUint16(b, 3)
+
+	resp := ParseResponse(b, APIProduce, 7)
+	if resp.CorrelationID != 42 || ErrorName(resp.ErrorCode) != "UNKNOWN_TOPIC_OR_PARTITION" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	fetch := binary.BigEndian.AppendUint32(nil, 7)
+	fetch = append(fetch, 0)
+	fetch = binary.BigEndian.AppendUint32(fetch, 0)
+	fetch = binary.BigEndian.AppendUint16(fetch, 0)
+	fetch = binary.BigEndian.AppendUint32(fetch, 0)
+	fetch = append(fetch, 2)
+	fetch = compactStr(fetch, "events")
+	fetch = append(fetch, 2)
+	fetch = binary.BigEndian.AppendUint32(fetch, 0)
+	fetch = binary.BigEndian.AppendUint16(fetch, 1)
+	if resp := ParseResponse(fetch, APIFetch, 12); resp.ErrorCode != 1 {
+		t.Fatalf("unexpected fetch response %+v", resp)
+	}
+}
+
+func TestFramerSplitsSizes(t *testing.T) {
+	var f Framer
+	msgs := f.Messages(append(sized([]byte{1, 2, 3}), sized([]byte{4, 5})...))
+	if len(msgs) != 2 || len(msgs[1]) != 2 {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+
+	if msgs := f.Messages(binary.BigEndian.AppendUint32(nil, 5)); len(msgs) != 0 {
+		t.Fatalf("expected a size-only chunk to hold no message")
+	}
+	msgs = f.Messages([]byte{0, 0, 0, 9, 1})
+	if len(msgs) != 1 || len(msgs[0]) != 5 {
+		t.Fatalf("expected the chunk after a size to be one message, got %v", msgs)
+	}
+}
+
+func TestNames(t *testing.T) {
+	if APIName(3) != "Metadata" || APIName(999) != "ApiKey(999)" {
+		t.Fatalf("unexpected API names")
+	}
+	if ErrorName(12345) != "12345" {
+		t.Fatalf("unexpected error name")
+	}
+}
@@ -0,0 +1,122 @@
+package kafkaparse
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// reader decodes the primitive types of the Kafka protocol. Reads past the
+// end of the buffer set failed and return zero values, so a message cut off
+// by the capture limit is parsed as far as it goes.
+type reader struct {
+	b      []byte
+	failed bool
+}
+
+func (r *reader) take(n int) []byte {
+	if r.failed || n < 0 || n > len(r.b) {
+		r.failed = true
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) int8() int8 {
+	b := r.take(1)
+	if b == nil {
+		return 0
+	}
+	return int8(b[0])
+}
+
+func (r *reader) int16() int16 {
+	b := r.take(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+func (r *reader) int32() int32 {
+	b := r.take(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (r *reader) uvarint() uint64 {
+	if r.failed {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.failed = true
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *reader) string(compact bool) string {
+	var n int
+	if compact {
+		n = int(r.uvarint()) - 1
+	} else {
+		n = int(r.int16())
+	}
+	if n <= 0 {
+		return ""
+	}
+	return string(r.take(n))
+}
+
+func (r *reader) bytes(compact bool) {
+	var n int
+	if compact {
+		n = int(r.uvarint()) - 1
+	} else {
+		n = int(r.int32())
+	}
+	if n > 0 {
+		r.take(n)
+	}
+}
+
+// array caps lengths by the bytes left, since every element takes at least
+// one.
+func (r *reader) array(compact bool) int {
+	var n int
+	if compact {
+		n = int(r.uvarint()) - 1
+	} else {
+		n = int(r.int32())
+	}
+	if n < 0 {
+		return 0
+	}
+	return min(n, len(r.b))
+}
+
+func (r *reader) uuid() string {
+	b := r.take(16)
+	if b == nil {
+		return ""
+	}
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// tags skips the tagged fields that end structures in flexible versions.
+func (r *reader) tags(flexible bool) {
+	if !flexible {
+		return
+	}
+	count := r.uvarint()
+	for i := uint64(0); i < count && !r.failed; i++ {
+		r.uvarint()
+		r.take(int(r.uvarint()))
+	}
+}
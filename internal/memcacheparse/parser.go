@@ -0,0 +1,385 @@
+package memcacheparse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+)
+
+const (
+	magicRequest  = 0x80
+	magicResponse = 0x81
+
+	binaryHeaderLen = 24
+
+	maxKeyLen  = 250
+	maxLineLen = 1024
+)
+
+// Command is one request. Quiet binary gets sent ahead of a Noop or a plain
+// get are folded into it, so Keys counts every key the batch asked for.
+type Command struct {
+	Name      string
+	Key       string
+	Keys      int
+	Retrieval bool
+}
+
+// Response is one answer. Binary status codes are named with the text
+// protocol's words.
+type Response struct {
+	Values int
+	Status string
+	Error  bool
+	// Quiet marks the hit of a quiet binary get; it belongs to the batch
+	// answered by the next response that is not quiet.
+	Quiet bool
+}
+
+type opcode struct {
+	name      string
+	retrieval bool
+	// quiet opcodes are only answered on a hit (gets) or on failure.
+	quiet bool
+}
+
+var opcodes = map[byte]opcode{
+	0x00: {name: "get", retrieval: true},
+	0x01: {name: "set"},
+	0x02: {name: "add"},
+	0x03: {name: "replace"},
+	0x04: {name: "delete"},
+	0x05: {name: "incr"},
+	0x06: {name: "decr"},
+	0x07: {name: "quit"},
+	0x08: {name: "flush_all"},
+	0x09: {name: "get", retrieval: true, quiet: true},
+	0x0a: {name: "noop"},
+	0x0b: {name: "version"},
+	0x0c: {name: "get", retrieval: true},
+	0x0d: {name: "get", retrieval: true, quiet: true},
+	0x0e: {name: "append"},
+	0x0f: {name: "prepend"},
+	0x10: {name: "stats"},
+	0x11: {name: "set", quiet: true},
+	0x12: {name: "add", quiet: true},
+	0x13: {name: "replace", quiet: true},
+	0x14: {name: "delete", quiet: true},
+	0x15: {name: "incr", quiet: true},
+	0x16: {name: "decr", quiet: true},
+	0x17: {name: "quit", quiet: true},
+	0x18: {name: "flush_all", quiet: true},
+	0x19: {name: "append", quiet: true},
+	0x1a: {name: "prepend", quiet: true},
+	0x1c: {name: "touch"},
+	0x1d: {name: "gat", retrieval: true},
+	0x1e: {name: "gat", retrieval: true, quiet: true},
+}
+
+var binaryStatus = map[uint16]string{
+	0x01: "NOT_FOUND",
+	0x02: "EXISTS",
+	0x03: "TOO_LARGE",
+	0x04: "INVALID_ARGUMENTS",
+	0x05: "NOT_STORED",
+	0x06: "NON_NUMERIC",
+	0x20: "AUTH_ERROR",
+	0x81: "UNKNOWN_COMMAND",
+	0x82: "OUT_OF_MEMORY",
+}
+
+// Text commands and the position of their first key.
+var textCommands = map[string]struct {
+	retrieval bool
+	storage   bool
+	firstKey  int
+}{
+	"get":     {retrieval: true, firstKey: 1},
+	"gets":    {retrieval: true, firstKey: 1},
+	"gat":     {retrieval: true, firstKey: 2},
+	"gats":    {retrieval: true, firstKey: 2},
+	"set":     {storage: true, firstKey: 1},
+	"add":     {storage: true, firstKey: 1},
+	"replace": {storage: true, firstKey: 1},
+	"append":  {storage: true, firstKey: 1},
+	"prepend": {storage: true, firstKey: 1},
+	"cas":     {storage: true, firstKey: 1},
+	"delete":  {firstKey: 1},
+	"incr":    {firstKey: 1},
+	"decr":    {firstKey: 1},
+	"touch":   {firstKey: 1},
+}
+
+// RequestParser reads the commands a client sends. It remembers quiet
+// binary gets until the command that ends their batch.
+type RequestParser struct {
+	quietKeys int
+	quietKey  string
+}
+
+// Parse leaves out commands sent with noreply and quiet binary writes, which
+// expect no response.
+func (p *RequestParser) Parse(data []byte) []Command {
+	if len(data) > 0 && data[0] == magicRequest {
+		return p.parseBinary(data)
+	}
+	return parseText(data)
+}
+
+func parseText(data []byte) []Command {
+	var commands []Command
+	for len(data) > 0 {
+		end := bytes.Index(data, []byte("\r\n"))
+		line := data
+		if end >= 0 {
+			line = data[:end]
+		}
+		fields := bytes.Fields(line)
+		if len(fields) == 0 {
+			break
+		}
+		name := string(fields[0])
+		spec, ok := textCommands[name]
+		if !ok || len(fields) <= spec.firstKey {
+			break
+		}
+		keys := fields[spec.firstKey:]
+		if !spec.retrieval {
+			keys = keys[:1]
+		}
+		if !bytes.Equal(fields[len(fields)-1], []byte("noreply")) {
+			commands = append(commands, Command{
+				Name:      name,
+				Key:       string(keys[0]),
+				Keys:      len(keys),
+				Retrieval: spec.retrieval,
+			})
+		}
+		if end < 0 {
+			break
+		}
+		data = data[end+2:]
+
+		if spec.storage {
+			// set <key> <flags> <exptime> <bytes>; cas adds a unique.
+			if len(fields) < 5 {
+				break
+			}
+			size, err := strconv.Atoi(string(fields[4]))
+			if err != nil || size < 0 || size+2 > len(data) {
+				break
+			}
+			data = data[size+2:]
+		}
+	}
+	return commands
+}
+
+func (p *RequestParser) parseBinary(data []byte) []Command {
+	var commands []Command
+	for len(data) >= binaryHeaderLen && data[0] == magicRequest {
+		op, known := opcodes[data[1]]
+		keyLen := int(binary.BigEndian.Uint16(data[2:]))
+		extrasLen := int(data[4])
+		bodyLen := int(binary.BigEndian.Uint32(data[8:]))
+		if !known || keyLen+extrasLen > bodyLen {
+			break
+		}
+		var key string
+		if start := binaryHeaderLen + extrasLen; keyLen > 0 && start+keyLen <= len(data) && keyLen <= maxKeyLen {
+			key = string(data[start : start+keyLen])
+		}
+
+		switch {
+		case op.quiet && op.retrieval:
+			if p.quietKeys == 0 {
+				p.quietKey = key
+			}
+			p.quietKeys++
+		case op.quiet:
+		case p.quietKeys > 0 && (op.retrieval || op.name == "noop"):
+			cmd := Command{Name: "get", Key: p.quietKey, Keys: p.quietKeys, Retrieval: true}
+			if op.retrieval {
+				cmd.Name = op.name
+				cmd.Keys++
+			}
+			commands = append(commands, cmd)
+			p.quietKeys, p.quietKey = 0, ""
+		default:
+			cmd := Command{Name: op.name, Key: key, Retrieval: op.retrieval}
+			if keyLen > 0 {
+				cmd.Keys = 1
+			}
+			commands = append(commands, cmd)
+		}
+
+		if binaryHeaderLen+bodyLen >= len(data) {
+			break
+		}
+		data = data[binaryHeaderLen+bodyLen:]
+	}
+	return commands
+}
+
+// ResponseParser keeps its place in values and binary bodies that span
+// several reads.
+type ResponseParser struct {
+	inGet bool
+	skip  int
+	head  []byte
+}
+
+// Parse returns the responses that start in data, the captured start of a
+// read of size bytes. A retrieval response cut off at the end of data is
+// returned with the values seen so far. ok is false when the stream cannot
+// be followed; the parser then starts over with the next read.
+func (p *ResponseParser) Parse(data []byte, size int) (responses []Response, ok bool) {
+	unseen := size - len(data)
+	skip := min(p.skip, len(data))
+	p.skip -= skip
+	data = data[skip:]
+	if len(p.head) > 0 {
+		data = append(p.head, data...)
+		p.head = nil
+	}
+
+	if len(data) > 0 && data[0] == magicResponse {
+		p.inGet = false
+		responses, ok = p.parseBinary(data)
+	} else {
+		responses, ok = p.parseText(data)
+	}
+	if ok && unseen > 0 {
+		if len(p.head) > 0 || p.skip < unseen {
+			ok = false
+		} else {
+			p.skip -= unseen
+		}
+	}
+	if !ok {
+		p.inGet, p.skip, p.head = false, 0, nil
+	}
+	return responses, ok
+}
+
+func (p *ResponseParser) parseText(data []byte) ([]Response, bool) {
+	var responses []Response
+	var current *Response
+	for len(data) > 0 {
+		end := bytes.Index(data, []byte("\r\n"))
+		if end < 0 {
+			if len(data) > maxLineLen {
+				return responses, false
+			}
+			p.head = append([]byte(nil), data...)
+			break
+		}
+		line := data[:end]
+		data = data[end+2:]
+		word, rest, _ := bytes.Cut(line, []byte(" "))
+
+		switch string(word) {
+		case "VALUE":
+			// VALUE <key> <flags> <bytes> [<cas unique>]
+			fields := bytes.Fields(rest)
+			if len(fields) < 3 {
+				return responses, false
+			}
+			if current == nil && !p.inGet {
+				responses = append(responses, Response{})
+				current = &responses[len(responses)-1]
+			}
+			if current != nil {
+				current.Values++
+			}
+			p.inGet = true
+			size, err := strconv.Atoi(string(fields[2]))
+			if err != nil || size < 0 {
+				return responses, false
+			}
+			if size+2 > len(data) {
+				p.skip = size + 2 - len(data)
+				return responses, true
+			}
+			data = data[size+2:]
+			continue
+		case "STAT":
+			if current == nil && !p.inGet {
+				responses = append(responses, Response{})
+				current = &responses[len(responses)-1]
+			}
+			p.inGet = true
+			continue
+		case "END":
+			if current == nil && !p.inGet {
+				responses = append(responses, Response{})
+			}
+		case "STORED", "DELETED", "TOUCHED", "OK", "VERSION":
+			responses = append(responses, Response{})
+		case "NOT_STORED", "EXISTS", "NOT_FOUND":
+			responses = append(responses, Response{Status: string(word)})
+		case "ERROR", "CLIENT_ERROR", "SERVER_ERROR":
+			responses = append(responses, Response{Status: string(word), Error: true})
+		default:
+			if _, err := strconv.ParseUint(string(line), 10, 64); err != nil {
+				return responses, false
+			}
+			responses = append(responses, Response{})
+		}
+		current = nil
+		p.inGet = false
+	}
+	return responses, true
+}
+
+func (p *ResponseParser) parseBinary(data []byte) ([]Response, bool) {
+	var responses []Response
+	for len(data) > 0 {
+		if data[0] != magicResponse {
+			return responses, false
+		}
+		if len(data) < binaryHeaderLen {
+			p.head = append([]byte(nil), data...)
+			break
+		}
+		op, known := opcodes[data[1]]
+		status := binary.BigEndian.Uint16(data[6:])
+		bodyLen := int(binary.BigEndian.Uint32(data[8:]))
+		if !known {
+			return responses, false
+		}
+
+		resp := Response{Quiet: op.quiet}
+		switch {
+		case op.retrieval && status == 0:
+			resp.Values = 1
+		case op.retrieval && status == 0x01:
+		case status != 0:
+			resp.Status = binaryStatus[status]
+			if resp.Status == "" {
+				resp.Status = "STATUS_" + strconv.Itoa(int(status))
+			}
+			resp.Error = status > 0x02 && status != 0x05
+		}
+		// Quiet writes are only answered when they fail and would otherwise
+		// be taken for the answer to the next command.
+		if !op.quiet || op.retrieval {
+			responses = append(responses, resp)
+		}
+
+		end := binaryHeaderLen + bodyLen
+		if end > len(data) {
+			p.skip = end - len(data)
+			break
+		}
+		data = data[end:]
+	}
+	return responses, true
+}
+
+func HitRatio(hits, keys int) float64 {
+	if keys <= 0 {
+		return 0
+	}
+	return float64(min(hits, keys)) / float64(keys)
+}
@@ -0,0 +1,110 @@
+package memcacheparse
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func packet(magic, op byte, status uint16, key string, body int) []byte {
+	b := []byte{magic, op}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(key)))
+	b = append(b, 0, 0)
+	b = binary.BigEndian.AppendUint16(b, status)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(key)+body))
+	b = append(b, make([]byte, 12)...)
+	b = append(b, key...)
+	return append(b, make([]byte, body)...)
+}
+
+func TestParseTextCommands(t *testing.T) {
+	var p RequestParser
+	cmds := p.Parse([]byte("set user:1 0 60 5\r\nhello\r\nset user:2 0 60 1 noreply\r\nx\r\nget a b c\r\n"))
+	if len(cmds) != 2 || cmds[0].Name != "set" || cmds[0].Key != "user:1" || cmds[0].Keys != 1 {
+		t.Fatalf("unexpected commands %+v", cmds)
+	}
+	if !cmds[1].Retrieval || cmds[1].Keys != 3 || cmds[1].Key != "a" {
+		t.Fatalf("unexpected multi-get %+v", cmds[1])
+	}
+}
+
+func TestParseTextResponses(t *testing.T) {
+	var p ResponseParser
+	resps, ok := p.Parse([]byte("VALUE a 0 2\r\nhi\r\nVALUE c 0 1\r\nx\r\nEND\r\nEND\r\nNOT_FOUND\r\nSERVER_ERROR out of memory\r\n"), 0)
+	if !ok || len(resps) != 4 || resps[0].Values != 2 || resps[1].Values != 0 {
+		t.Fatalf("unexpected responses %+v", resps)
+	}
+	if resps[2].Status != "NOT_FOUND" || resps[2].Error || !resps[3].Error {
+		t.Fatalf("unexpected statuses %+v", resps)
+	}
+}
+
+func TestParseTextResponseContinuation(t *testing.T) {
+	var p ResponseParser
+	resps, ok := p.Parse([]byte("VALUE big 0 20\r\nabc"), 0)
+	if !ok || len(resps) != 1 || resps[0].Values != 1 {
+		t.Fatalf("unexpected first chunk %+v", resps)
+	}
+	// The middle of the value reads like a response of its own.
+	if resps, ok := p.Parse([]byte("\r\nSTORED\r\n"), 0); !ok || len(resps) != 0 {
+		t.Fatalf("expected the middle of a value to yield nothing, got %+v", resps)
+	}
+	resps, ok = p.Parse([]byte("defghij\r\nEND\r\nSTO"), 0)
+	if !ok || len(resps) != 0 {
+		t.Fatalf("expected nothing before the line is complete, got %+v", resps)
+	}
+	resps, ok = p.Parse([]byte("RED\r\n"), 0)
+	if !ok || len(resps) != 1 || resps[0].Status != "" || resps[0].Values != 0 {
+		t.Fatalf("expected only the response after END, got %+v", resps)
+	}
+}
+
+func TestParseResponsesTruncated(t *testing.T) {
+	// Only the start of a large value was captured; the rest of the read
+	// is known to belong to it.
+	var p ResponseParser
+	if resps, ok := p.Parse([]byte("VALUE big 0 10000\r\nabc"), 4096); !ok || len(resps) != 1 {
+		t.Fatalf("first read: %+v ok=%v", resps, ok)
+	}
+	if resps, ok := p.Parse(make([]byte, 100), 10002-4096+len("VALUE big 0 10000\r\n")); !ok || len(resps) != 0 {
+		t.Fatalf("second read: %+v ok=%v", resps, ok)
+	}
+	if resps, ok := p.Parse([]byte("END\r\nSTORED\r\n"), 0); !ok || len(resps) != 1 {
+		t.Fatalf("after the value: %+v ok=%v", resps, ok)
+	}
+
+	// Bytes left out after a complete response may hold further ones.
+	if _, ok := p.Parse([]byte("STORED\r\n"), 100); ok {
+		t.Fatalf("expected uncaptured responses to lose the stream")
+	}
+	if _, ok := new(ResponseParser).Parse([]byte("the middle of a value\r\n"), 0); ok {
+		t.Fatalf("expected data that does not start with a response to be rejected")
+	}
+}
+
+func TestParseBinaryBatch(t *testing.T) {
+	var req RequestParser
+	data := append(packet(magicRequest, 0x0d, 0, "k1", 0), packet(magicRequest, 0x0d, 0, "k2", 0)...)
+	data = append(data, packet(magicRequest, 0x0a, 0, "", 0)...)
+	data = append(data, packet(magicRequest, 0x11, 0, "k3", 8)...)
+	cmds := req.Parse(data)
+	if len(cmds) != 1 || cmds[0].Name != "get" || cmds[0].Keys != 2 || cmds[0].Key != "k1" {
+		t.Fatalf("unexpected commands %+v", cmds)
+	}
+
+	var resp ResponseParser
+	resps, _ := resp.Parse(append(packet(magicResponse, 0x0d, 0, "k1", 4), packet(magicResponse, 0x0a, 0, "", 0)...), 0)
+	if len(resps) != 2 || !resps[0].Quiet || resps[0].Values != 1 || resps[1].Quiet {
+		t.Fatalf("unexpected responses %+v", resps)
+	}
+
+	resps, _ = resp.Parse(packet(magicResponse, 0x04, 0x01, "", 9), 0)
+	if len(resps) != 1 || resps[0].Status != "NOT_FOUND" || resps[0].Error {
+		t.Fatalf("unexpected delete response %+v", resps)
+	}
+}
+
+func TestHitRatio(t *testing.T) {
+	if HitRatio(1, 4) != 0.25 || HitRatio(0, 0) != 0 {
+		t.Fatalf("unexpected hit ratio")
+	}
+}
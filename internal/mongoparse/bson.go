@@ -0,0 +1,130 @@
+package mongoparse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// BSON element types whose values are read; the others are only skipped.
+const (
+	bsonDouble = 0x01
+	bsonString = 0x02
+	bsonDoc    = 0x03
+	bsonArray  = 0x04
+	bsonBool   = 0x08
+	bsonInt32  = 0x10
+	bsonInt64  = 0x12
+)
+
+type element struct {
+	kind  byte
+	name  string
+	value []byte
+}
+
+// elements calls fn for each element of a BSON document until fn returns
+// false. Documents cut off by the capture limit are walked as far as they
+// go.
+func elements(doc []byte, fn func(element) bool) {
+	if len(doc) < 5 {
+		return
+	}
+	if size := int(binary.LittleEndian.Uint32(doc)); size >= 5 && size < len(doc) {
+		doc = doc[:size]
+	}
+	doc = doc[4:]
+	for len(doc) > 0 && doc[0] != 0 {
+		kind := doc[0]
+		end := bytes.IndexByte(doc[1:], 0)
+		if end < 0 {
+			return
+		}
+		name := string(doc[1 : 1+end])
+		doc = doc[2+end:]
+		n := valueLen(kind, doc)
+		if n < 0 || n > len(doc) {
+			return
+		}
+		if !fn(element{kind: kind, name: name, value: doc[:n]}) {
+			return
+		}
+		doc = doc[n:]
+	}
+}
+
+// valueLen returns the size of a value of the given type at the start of b,
+// or -1 if it cannot be told.
+func valueLen(kind byte, b []byte) int {
+	switch kind {
+	case 0x06, 0x0a, 0x7f, 0xff:
+		return 0
+	case bsonBool:
+		return 1
+	case bsonInt32:
+		return 4
+	case bsonDouble, 0x09, 0x11, bsonInt64:
+		return 8
+	case 0x07:
+		return 12
+	case 0x13:
+		return 16
+	case bsonString, 0x0d, 0x0e:
+		if len(b) < 4 {
+			return -1
+		}
+		return 4 + int(int32(binary.LittleEndian.Uint32(b)))
+	case 0x0c:
+		if len(b) < 4 {
+			return -1
+		}
+		return 16 + int(int32(binary.LittleEndian.Uint32(b)))
+	case bsonDoc, bsonArray, 0x0f:
+		if len(b) < 4 {
+			return -1
+		}
+		return int(int32(binary.LittleEndian.Uint32(b)))
+	case 0x05:
+		if len(b) < 4 {
+			return -1
+		}
+		return 5 + int(int32(binary.LittleEndian.Uint32(b)))
+	case 0x0b:
+		first := bytes.IndexByte(b, 0)
+		if first < 0 {
+			return -1
+		}
+		second := bytes.IndexByte(b[first+1:], 0)
+		if second < 0 {
+			return -1
+		}
+		return first + second + 2
+	}
+	return -1
+}
+
+func (e element) str() (string, bool) {
+	if e.kind != bsonString || len(e.value) < 5 {
+		return "", false
+	}
+	return string(e.value[4 : len(e.value)-1]), true
+}
+
+// number converts numeric and boolean values, which servers use
+// interchangeably for fields such as ok.
+func (e element) number() (float64, bool) {
+	switch e.kind {
+	case bsonDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(e.value)), true
+	case bsonInt32:
+		return float64(int32(binary.LittleEndian.Uint32(e.value))), true
+	case bsonInt64:
+		return float64(int64(binary.LittleEndian.Uint64(e.value))), true
+	case bsonBool:
+		if e.value[0] != 0 {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,267 @@
+package mongoparse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+)
+
+// Opcodes of the wire protocol.
+const (
+	OpReply = 1
+	OpQuery = 2004
+	OpMsg   = 2013
+)
+
+const (
+	headerLen = 16
+
+	flagChecksumPresent = 1 << 0
+	flagMoreToCome      = 1 << 1
+
+	sectionBody     = 0
+	sectionSequence = 1
+)
+
+// Header is the standard message header.
+type Header struct {
+	Length     int
+	RequestID  int32
+	ResponseTo int32
+	OpCode     int32
+}
+
+// Command is a request read from an OP_MSG or a legacy OP_QUERY on a $cmd
+// collection. NoReply is set when the client asked for no response.
+type Command struct {
+	RequestID  int32
+	Name       string
+	Database   string
+	Collection string
+	NoReply    bool
+}
+
+// Reply is the outcome of a command. OK defaults to true when the ok field
+// was cut off by the capture limit, since error replies are small. Code is
+// the command's error code, or the first write error's. N is the number of
+// documents a write matched or inserted, when reported.
+type Reply struct {
+	ResponseTo int32
+	OK         bool
+	Code       int32
+	N          uint64
+}
+
+// Messages splits data into messages. The last one may be cut off.
+func Messages(data []byte) [][]byte {
+	var messages [][]byte
+	for len(data) >= headerLen {
+		length := int(binary.LittleEndian.Uint32(data))
+		if length < headerLen {
+			break
+		}
+		if length >= len(data) {
+			messages = append(messages, data)
+			break
+		}
+		messages = append(messages, data[:length])
+		data = data[length:]
+	}
+	return messages
+}
+
+func ParseHeader(msg []byte) (Header, bool) {
+	if len(msg) < headerLen {
+		return Header{}, false
+	}
+	return Header{
+		Length:     int(binary.LittleEndian.Uint32(msg)),
+		RequestID:  int32(binary.LittleEndian.Uint32(msg[4:])),
+		ResponseTo: int32(binary.LittleEndian.Uint32(msg[8:])),
+		OpCode:     int32(binary.LittleEndian.Uint32(msg[12:])),
+	}, true
+}
+
+// ParseCommand reads the command in a request message.
+func ParseCommand(msg []byte) (Command, bool) {
+	h, ok := ParseHeader(msg)
+	if !ok || h.ResponseTo != 0 {
+		return Command{}, false
+	}
+	cmd := Command{RequestID: h.RequestID}
+
+	var doc []byte
+	switch h.OpCode {
+	case OpMsg:
+		flags, body, ok := msgBody(msg)
+		if !ok {
+			return Command{}, false
+		}
+		cmd.NoReply = flags&flagMoreToCome != 0
+		doc = body
+	case OpQuery:
+		// flags, fullCollectionName, numberToSkip, numberToReturn, query.
+		rest := msg[headerLen:]
+		if len(rest) < 4 {
+			return Command{}, false
+		}
+		end := bytes.IndexByte(rest[4:], 0)
+		if end < 0 {
+			return Command{}, false
+		}
+		namespace := string(rest[4 : 4+end])
+		db, coll, _ := strings.Cut(namespace, ".")
+		if coll != "$cmd" {
+			return Command{}, false
+		}
+		cmd.Database = db
+		if len(rest) < 4+end+1+8 {
+			return Command{}, false
+		}
+		doc = rest[4+end+1+8:]
+	default:
+		return Command{}, false
+	}
+
+	first := true
+	elements(doc, func(e element) bool {
+		if first {
+			first = false
+			cmd.Name = e.name
+			cmd.Collection, _ = e.str()
+			return true
+		}
+		switch e.name {
+		case "$db":
+			cmd.Database, _ = e.str()
+		case "collection":
+			if cmd.Name == "getMore" {
+				cmd.Collection, _ = e.str()
+			}
+		}
+		return true
+	})
+	if cmd.Name == "" {
+		return Command{}, false
+	}
+	return cmd, true
+}
+
+// ParseReply reads the reply in a response message.
+func ParseReply(msg []byte) (Reply, bool) {
+	h, ok := ParseHeader(msg)
+	if !ok || h.ResponseTo == 0 {
+		return Reply{}, false
+	}
+	reply := Reply{ResponseTo: h.ResponseTo, OK: true}
+
+	var doc []byte
+	switch h.OpCode {
+	case OpMsg:
+		_, body, ok := msgBody(msg)
+		if !ok {
+			return reply, true
+		}
+		doc = body
+	case OpReply:
+		// responseFlags, cursorID, startingFrom, numberReturned, documents.
+		if len(msg) < headerLen+20 {
+			return reply, true
+		}
+		doc = msg[headerLen+20:]
+	default:
+		return Reply{}, false
+	}
+
+	elements(doc, func(e element) bool {
+		switch e.name {
+		case "ok":
+			if v, ok := e.number(); ok {
+				reply.OK = v == 1
+			}
+		case "code":
+			if v, ok := e.number(); ok && reply.Code == 0 {
+				reply.Code = int32(v)
+			}
+		case "n":
+			if v, ok := e.number(); ok && v > 0 {
+				reply.N = uint64(v)
+			}
+		case "writeErrors":
+			if reply.Code == 0 {
+				reply.Code = firstWriteError(e)
+			}
+		}
+		return true
+	})
+	return reply, true
+}
+
+// Failed reports whether the command failed, including writes acknowledged
+// with ok:1 whose documents were rejected.
+func (r Reply) Failed() bool {
+	return !r.OK || r.Code != 0
+}
+
+// ErrorCode formats the error code of a failed command.
+func (r Reply) ErrorCode() string {
+	if r.Code == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(r.Code))
+}
+
+func firstWriteError(e element) int32 {
+	if e.kind != bsonArray {
+		return 0
+	}
+	var code int32
+	elements(e.value, func(item element) bool {
+		if item.kind == bsonDoc {
+			elements(item.value, func(field element) bool {
+				if field.name == "code" {
+					if v, ok := field.number(); ok {
+						code = int32(v)
+					}
+					return false
+				}
+				return true
+			})
+		}
+		return false
+	})
+	return code
+}
+
+// msgBody returns the flags and the body section of an OP_MSG. Document
+// sequence sections, which carry the documents of bulk writes, are
+// skipped.
+func msgBody(msg []byte) (uint32, []byte, bool) {
+	if len(msg) < headerLen+5 {
+		return 0, nil, false
+	}
+	flags := binary.LittleEndian.Uint32(msg[headerLen:])
+	sections := msg[headerLen+4:]
+	if length := int(binary.LittleEndian.Uint32(msg)); flags&flagChecksumPresent != 0 && length == len(msg) && len(sections) >= 4 {
+		sections = sections[:len(sections)-4]
+	}
+	for len(sections) > 0 {
+		switch sections[0] {
+		case sectionBody:
+			return flags, sections[1:], true
+		case sectionSequence:
+			if len(sections) < 5 {
+				return 0, nil, false
+			}
+			size := int(binary.LittleEndian.Uint32(sections[1:]))
+			if size < 4 || 1+size > len(sections) {
+				return 0, nil, false
+			}
+			sections = sections[1+size:]
+		default:
+			return 0, nil, false
+		}
+	}
+	return 0, nil, false
+}
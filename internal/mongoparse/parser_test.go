@@ -0,0 +1,94 @@
+package mongoparse
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+type field struct {
+	name  string
+	kind  byte
+	value []byte
+}
+
+func doc(fields ...field) []byte {
+	var body []byte
+	for _, f := range fields {
+		body = append(body, f.kind)
+		body = append(body, f.name...)
+		body = append(body, 0)
+		body = append(body, f.value...)
+	}
+	body = append(body, 0)
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(body)+4)), body...)
+}
+
+func str(name, value string) field {
+	v := binary.LittleEndian.AppendUint32(nil, uint32(len(value)+1))
+	return field{name, bsonString, append(append(v, value...), 0)}
+}
+
+func double(name string, value float64) field {
+	return field{name, bsonDouble, binary.LittleEndian.AppendUint64(nil, math.Float64bits(value))}
+}
+
+func int32Field(name string, value int32) field {
+	return field{name, bsonInt32, binary.LittleEndian.AppendUint32(nil, uint32(value))}
+}
+
+func opMsg(requestID, responseTo int32, flags uint32, body []byte) []byte {
+	payload := binary.LittleEndian.AppendUint32(nil, flags)
+	payload = append(payload, sectionBody)
+	payload = append(payload, body...)
+	msg := binary.LittleEndian.AppendUint32(nil, uint32(headerLen+len(payload)))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(requestID))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(responseTo))
+	msg = binary.LittleEndian.AppendUint32(msg, OpMsg)
+	return append(msg, payload...)
+}
+
+func TestParseCommand(t *testing.T) {
+	msg := opMsg(7, 0, 0, doc(str("find", "users"), field{"filter", bsonDoc, doc(int32Field("age", 30))}, str("$db", "app")))
+	cmd, ok := ParseCommand(msg)
+	if !ok || cmd.RequestID != 7 || cmd.Name != "find" || cmd.Collection != "users" || cmd.Database != "app" {
+		t.Fatalf("unexpected command %+v %v", cmd, ok)
+	}
+
+	cmd, ok = ParseCommand(opMsg(8, 0, flagMoreToCome, doc(str("insert", "events"), str("$db", "app"))))
+	if !ok || !cmd.NoReply {
+		t.Fatalf("expected an unacknowledged write, got %+v", cmd)
+	}
+
+	// A body cut off before $db still names the command.
+	cmd, ok = ParseCommand(msg[:len(msg)-12])
+	if !ok || cmd.Name != "find" || cmd.Database != "" {
+		t.Fatalf("unexpected truncated command %+v %v", cmd, ok)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	reply, ok := ParseReply(opMsg(100, 7, 0, doc(int32Field("n", 3), double("ok", 1))))
+	if !ok || reply.ResponseTo != 7 || reply.Failed() || reply.N != 3 {
+		t.Fatalf("unexpected reply %+v %v", reply, ok)
+	}
+
+	reply, _ = ParseReply(opMsg(101, 8, 0, doc(double("ok", 0), str("errmsg", "ns not found"), int32Field("code", 26), str("codeName", "NamespaceNotFound"))))
+	if !reply.Failed() || reply.ErrorCode() != "26" {
+		t.Fatalf("unexpected error reply %+v", reply)
+	}
+
+	writeErrors := doc(field{"0", bsonDoc, doc(int32Field("index", 0), int32Field("code", 11000))})
+	reply, _ = ParseReply(opMsg(102, 9, 0, doc(int32Field("n", 0), field{"writeErrors", bsonArray, writeErrors}, double("ok", 1))))
+	if !reply.Failed() || reply.ErrorCode() != "11000" {
+		t.Fatalf("unexpected write error reply %+v", reply)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	a := opMsg(1, 0, 0, doc(int32Field("ping", 1)))
+	b := opMsg(2, 0, 0, doc(int32Field("ping", 1)))
+	if msgs := Messages(append(append([]byte(nil), a...), b...)); len(msgs) != 2 || len(msgs[0]) != len(a) {
+		t.Fatalf("unexpected messages %d", len(msgs))
+	}
+}
@@ -0,0 +1,229 @@
+package mysqlparse
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+const (
+	ComQuit        = 0x01
+	ComQuery       = 0x03
+	ComStmtPrepare = 0x16
+	ComStmtExecute = 0x17
+	ComStmtClose   = 0x19
+)
+
+const (
+	headerLen = 4
+
+	packetOK  = 0x00
+	packetEOF = 0xfe
+	packetErr = 0xff
+
+	// EOF packets and the OK packets that replace them are shorter than
+	// this; a row can only start with 0xfe when it is much longer.
+	maxEOFLen     = 9
+	classicEOFLen = 5
+
+	serverMoreResultsExists = 0x0008
+)
+
+type Command struct {
+	Kind   byte
+	Query  string
+	StmtID uint32
+}
+
+// Response is the start of the server's answer to a Command. The rows of a
+// result set are only known when all of it was captured.
+type Response struct {
+	Error     bool
+	Code      uint16
+	SQLState  string
+	Rows      uint64
+	RowsKnown bool
+	StmtID    uint32
+}
+
+func ParseCommand(data []byte) (Command, bool) {
+	payload, seq, ok := packet(data)
+	if !ok || seq != 0 || len(payload) == 0 {
+		return Command{}, false
+	}
+	cmd := Command{Kind: payload[0]}
+	switch cmd.Kind {
+	case ComQuery, ComStmtPrepare:
+		cmd.Query = string(payload[1:])
+	case ComStmtExecute, ComStmtClose:
+		if len(payload) < 5 {
+			return Command{}, false
+		}
+		cmd.StmtID = binary.LittleEndian.Uint32(payload[1:])
+	}
+	return cmd, true
+}
+
+// ParseResponse reads the answer at the start of data. Answers start with
+// sequence number 1, but as sequence numbers wrap at 256, callers must only
+// pass data while a command is waiting for its answer.
+func ParseResponse(data []byte, prepare bool) (Response, bool) {
+	payload, seq, ok := packet(data)
+	if !ok || seq != 1 || len(payload) == 0 {
+		return Response{}, false
+	}
+
+	switch payload[0] {
+	case packetErr:
+		return parseErr(payload), true
+	case packetOK:
+		if prepare {
+			if len(payload) < 5 {
+				return Response{}, false
+			}
+			return Response{StmtID: binary.LittleEndian.Uint32(payload[1:])}, true
+		}
+		rows, _, ok := lenenc(payload[1:])
+		return Response{Rows: rows, RowsKnown: ok}, true
+	}
+	if prepare {
+		return Response{}, false
+	}
+
+	rows, ok := countRows(data)
+	return Response{Rows: rows, RowsKnown: ok}, true
+}
+
+func parseErr(payload []byte) Response {
+	resp := Response{Error: true}
+	if len(payload) >= 3 {
+		resp.Code = binary.LittleEndian.Uint16(payload[1:])
+	}
+	if len(payload) >= 9 && payload[3] == '#' {
+		resp.SQLState = string(payload[4:9])
+	}
+	return resp
+}
+
+// countRows walks the result sets in data and returns the number of rows if
+// the last one ends in data.
+func countRows(data []byte) (uint64, bool) {
+	var rows uint64
+	for {
+		payload, _, ok := packet(data)
+		if !ok {
+			return 0, false
+		}
+		// Stored procedures end with a plain OK after their result sets.
+		if payload[0] == packetErr || payload[0] == packetOK {
+			return rows, true
+		}
+		columns, _, ok := lenenc(payload)
+		if !ok {
+			return 0, false
+		}
+		data = data[headerLen+len(payload):]
+		for i := uint64(0); i < columns; i++ {
+			if data, ok = skipPacket(data); !ok {
+				return 0, false
+			}
+		}
+		// Without CLIENT_DEPRECATE_EOF a classic EOF separates the column
+		// definitions from the rows; the OK packet that ends the rows in the
+		// other mode is never that short.
+		if payload, _, ok := packet(data); ok && isEOF(payload) && len(payload) == classicEOFLen {
+			data = data[headerLen+len(payload):]
+		}
+
+		for {
+			payload, _, ok := packet(data)
+			if !ok {
+				return 0, false
+			}
+			data = data[headerLen+len(payload):]
+			if payload[0] == packetErr {
+				return rows, true
+			}
+			if isEOF(payload) {
+				if eofStatus(payload)&serverMoreResultsExists == 0 {
+					return rows, true
+				}
+				break
+			}
+			rows++
+		}
+	}
+}
+
+func isEOF(payload []byte) bool {
+	return payload[0] == packetEOF && len(payload) < maxEOFLen
+}
+
+func eofStatus(payload []byte) uint16 {
+	if len(payload) == classicEOFLen {
+		return binary.LittleEndian.Uint16(payload[3:])
+	}
+	rest := payload[1:]
+	for i := 0; i < 2; i++ {
+		_, n, ok := lenenc(rest)
+		if !ok {
+			return 0
+		}
+		rest = rest[n:]
+	}
+	if len(rest) < 2 {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(rest)
+}
+
+func packet(data []byte) ([]byte, byte, bool) {
+	if len(data) < headerLen {
+		return nil, 0, false
+	}
+	length := int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+	if length == 0 || len(data) < headerLen+length {
+		return nil, 0, false
+	}
+	return data[headerLen : headerLen+length], data[3], true
+}
+
+func skipPacket(data []byte) ([]byte, bool) {
+	payload, _, ok := packet(data)
+	if !ok {
+		return nil, false
+	}
+	return data[headerLen+len(payload):], true
+}
+
+func lenenc(b []byte) (uint64, int, bool) {
+	if len(b) == 0 {
+		return 0, 0, false
+	}
+	switch b[0] {
+	case 0xfc:
+		if len(b) < 3 {
+			return 0, 0, false
+		}
+		return uint64(binary.LittleEndian.Uint16(b[1:])), 3, true
+	case 0xfd:
+		if len(b) < 4 {
+			return 0, 0, false
+		}
+		return uint64(b[1]) | uint64(b[2])<<8 | uint64(b[3])<<16, 4, true
+	case 0xfe:
+		if len(b) < 9 {
+			return 0, 0, false
+		}
+		return binary.LittleEndian.Uint64(b[1:]), 9, true
+	case 0xfb, 0xff:
+		return 0, 0, false
+	}
+	return uint64(b[0]), 1, true
+}
+
+func (r Response) ErrorCode() string {
+	if !r.Error || r.Code == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(r.Code))
+}
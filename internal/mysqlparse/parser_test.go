@@ -0,0 +1,75 @@
+package mysqlparse
+
+import "testing"
+
+func packetOf(seq byte, payload ...byte) []byte {
+	b := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}
+	return append(b, payload...)
+}
+
+func TestParseCommand(t *testing.T) {
+	cmd, ok := ParseCommand(packetOf(0, append([]byte{ComQuery}, "SELECT 1"...)...))
+	if !ok || cmd.Kind != ComQuery || cmd.Query != "SELECT 1" {
+		t.Fatalf("unexpected command %+v %v", cmd, ok)
+	}
+
+	cmd, ok = ParseCommand(packetOf(0, ComStmtExecute, 7, 0, 0, 0, 0, 1, 0, 0, 0))
+	if !ok || cmd.Kind != ComStmtExecute || cmd.StmtID != 7 {
+		t.Fatalf("unexpected command %+v %v", cmd, ok)
+	}
+
+	if _, ok := ParseCommand(packetOf(1, ComQuery, 'x')); ok {
+		t.Fatalf("expected packets after the first to be rejected")
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	resp, ok := ParseResponse(packetOf(1, 0x00, 3, 0, 2, 0, 0, 0), false)
+	if !ok || resp.Error || resp.Rows != 3 || !resp.RowsKnown {
+		t.Fatalf("unexpected OK response %+v", resp)
+	}
+
+	errPacket := append([]byte{0xff, 0x26, 0x04, '#'}, "23000Duplicate entry"...)
+	resp, ok = ParseResponse(packetOf(1, errPacket...), false)
+	if !ok || !resp.Error || resp.ErrorCode() != "1062" || resp.SQLState != "23000" {
+		t.Fatalf("unexpected ERR response %+v", resp)
+	}
+
+	resp, ok = ParseResponse(packetOf(1, 0x00, 9, 0, 0, 0, 1, 0, 1, 0, 0, 0, 0), true)
+	if !ok || resp.StmtID != 9 {
+		t.Fatalf("unexpected prepare response %+v", resp)
+	}
+
+	if _, ok := ParseResponse(packetOf(5, 0x00, 0, 0, 2, 0, 0, 0), false); ok {
+		t.Fatalf("expected continuation packets to be rejected")
+	}
+}
+
+func TestParseResultSetRows(t *testing.T) {
+	column := []byte{3, 'd', 'e', 'f'}
+	classic := packetOf(1, 1)
+	classic = append(classic, packetOf(2, column...)...)
+	classic = append(classic, packetOf(3, 0xfe, 0, 0, 2, 0)...)
+	classic = append(classic, packetOf(4, 1, 'a')...)
+	classic = append(classic, packetOf(5, 1, 'b')...)
+	classic = append(classic, packetOf(6, 0xfe, 0, 0, 2, 0)...)
+
+	resp, ok := ParseResponse(classic, false)
+	if !ok || resp.Rows != 2 || !resp.RowsKnown {
+		t.Fatalf("unexpected classic result set %+v", resp)
+	}
+
+	deprecateEOF := packetOf(1, 1)
+	deprecateEOF = append(deprecateEOF, packetOf(2, column...)...)
+	deprecateEOF = append(deprecateEOF, packetOf(3, 1, 'a')...)
+	deprecateEOF = append(deprecateEOF, packetOf(4, 0xfe, 0, 0, 2, 0, 0, 0)...)
+	resp, ok = ParseResponse(deprecateEOF, false)
+	if !ok || resp.Rows != 1 || !resp.RowsKnown {
+		t.Fatalf("unexpected result set %+v", resp)
+	}
+
+	resp, ok = ParseResponse(classic[:len(classic)-9], false)
+	if !ok || resp.RowsKnown {
+		t.Fatalf("expected a cut-off result set to have unknown rows, got %+v", resp)
+	}
+}
@@ -0,0 +1,110 @@
+package payload
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	bearerPattern = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+	cardPattern   = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	// A number running into the end of the body may have been cut off by the
+	// capture limit, which breaks its checksum.
+	cutCardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,}$`)
+)
+
+// Redactor masks personal data and secrets in body samples: email
+// addresses, card numbers that pass the Luhn check or end the body, bearer
+// tokens, and the values of JSON object keys given by name.
+type Redactor struct {
+	keys  *regexp.Regexp
+	names map[string]bool
+}
+
+// NewRedactor returns a Redactor masking the values of keys, which are
+// matched case-insensitively.
+func NewRedactor(keys []string) *Redactor {
+	r := &Redactor{names: make(map[string]bool, len(keys))}
+	if len(keys) > 0 {
+		quoted := make([]string, len(keys))
+		for i, key := range keys {
+			quoted[i] = regexp.QuoteMeta(key)
+			r.names[strings.ToLower(key)] = true
+		}
+		// A value cut off by the capture limit has no closing quote.
+		r.keys = regexp.MustCompile(`(?i)"(` + strings.Join(quoted, "|") + `)"\s*:\s*("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	}
+	return r
+}
+
+func (r *Redactor) Redact(body string) string {
+	if r != nil && r.keys != nil {
+		body = r.keys.ReplaceAllString(body, `"$1":"[redacted]"`)
+	}
+	body = bearerPattern.ReplaceAllString(body, "Bearer [token]")
+	body = emailPattern.ReplaceAllString(body, "[email]")
+	body = cutCardPattern.ReplaceAllString(body, "[card]")
+	return cardPattern.ReplaceAllStringFunc(body, func(match string) string {
+		if luhn(match) {
+			return "[card]"
+		}
+		return match
+	})
+}
+
+// RedactQuery masks the values of query parameters named like the redacted
+// keys, and the personal data found in the decoded values of the others. A
+// nil Redactor only masks the latter.
+func (r *Redactor) RedactQuery(query string) string {
+	if query == "" {
+		return query
+	}
+	params := strings.Split(query, "&")
+	for i, param := range params {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok || value == "" {
+			continue
+		}
+		key, err := url.QueryUnescape(name)
+		if err != nil {
+			key = name
+		}
+		if r != nil && r.names[strings.ToLower(key)] {
+			params[i] = name + "=[redacted]"
+			continue
+		}
+		decoded, err := url.QueryUnescape(value)
+		if err != nil {
+			decoded = value
+		}
+		if masked := r.Redact(decoded); masked != decoded {
+			params[i] = name + "=" + masked
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum used by card
+// numbers, which keeps timestamps and other long numbers readable.
+func luhn(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,105 @@
+package payload
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+type Config struct {
+	// Namespaces and Routes opt entries in; "*" selects every namespace.
+	// Routes are route templates, or prefixes when they end in "*".
+	Namespaces []string
+	Routes     []string
+	// ContentTypes are media types that may be sampled, with path.Match
+	// patterns such as text/*.
+	ContentTypes []string
+	MaxBytes     int
+	RedactKeys   []string
+}
+
+// Sampler decides which HTTP bodies are kept and prepares them for
+// storage. A nil Sampler samples nothing.
+type Sampler struct {
+	cfg      Config
+	redactor *Redactor
+}
+
+// NewSampler returns nil when no namespace or route opts in.
+func NewSampler(cfg Config) *Sampler {
+	if (len(cfg.Namespaces) == 0 && len(cfg.Routes) == 0) || cfg.MaxBytes <= 0 {
+		return nil
+	}
+	return &Sampler{cfg: cfg, redactor: NewRedactor(cfg.RedactKeys)}
+}
+
+// Selects reports whether entries of namespace and route have their bodies
+// sampled.
+func (s *Sampler) Selects(namespace, route string) bool {
+	if s == nil {
+		return false
+	}
+	for _, ns := range s.cfg.Namespaces {
+		if ns == "*" || ns == namespace {
+			return true
+		}
+	}
+	if route == "" {
+		return false
+	}
+	for _, pattern := range s.cfg.Routes {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(route, prefix) {
+				return true
+			}
+		} else if pattern == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Sample returns the redacted body, cut to MaxBytes, or "" when the body is
+// empty or of a content type that is not sampled.
+func (s *Sampler) Sample(body []byte, contentType string) string {
+	if s == nil || len(body) == 0 || !s.allows(contentType) {
+		return ""
+	}
+	sample := s.redactor.Redact(string(body))
+	if len(sample) > s.cfg.MaxBytes {
+		n := s.cfg.MaxBytes
+		for n > 0 && !utf8.RuneStart(sample[n]) {
+			n--
+		}
+		sample = sample[:n]
+	}
+	return sample
+}
+
+func (s *Sampler) allows(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		return false
+	}
+	for _, pattern := range s.cfg.ContentTypes {
+		if ok, _ := path.Match(strings.ToLower(pattern), mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Body returns what follows the header block of an HTTP/1 message, or nil
+// when the headers were cut off or the body is compressed.
+func Body(message []byte, contentEncoding string) []byte {
+	if contentEncoding != "" && !strings.EqualFold(contentEncoding, "identity") {
+		return nil
+	}
+	_, body, found := bytes.Cut(message, []byte("\r\n\r\n"))
+	if !found {
+		return nil
+	}
+	return body
+}
@@ -0,0 +1,43 @@
+package payload
+
+import "testing"
+
+func TestSamplerSelects(t *testing.T) {
+	if NewSampler(Config{MaxBytes: 64}) != nil {
+		t.Fatalf("expected sampling to be off without namespaces or routes")
+	}
+
+	s := NewSampler(Config{Namespaces: []string{"shop"}, Routes: []string{"/orders/{id}", "/api/v2/*"}, MaxBytes: 64})
+	if !s.Selects("shop", "") || !s.Selects("billing", "/orders/{id}") || !s.Selects("", "/api/v2/carts") {
+		t.Fatalf("expected entries to be selected")
+	}
+	if s.Selects("billing", "/orders/{id}/items") {
+		t.Fatalf("expected an exact route to select only itself")
+	}
+}
+
+func TestSample(t *testing.T) {
+	s := NewSampler(Config{
+		Namespaces:   []string{"*"},
+		ContentTypes: []string{"application/json", "text/*"},
+		MaxBytes:     12,
+	})
+	if got := s.Sample([]byte(`{"mail":"a@b.io"}`), "application/json; charset=utf-8"); got != `{"mail":"[em` {
+		t.Fatalf("unexpected sample %q", got)
+	}
+	if got := s.Sample([]byte("aéééééé"), "text/plain"); got != "aééééé" {
+		t.Fatalf("expected the sample to end on a rune boundary, got %q", got)
+	}
+	if s.Sample([]byte{0x89, 'P', 'N', 'G'}, "image/png") != "" {
+		t.Fatalf("expected binary bodies to be skipped")
+	}
+}
+
+func TestBody(t *testing.T) {
+	if string(Body([]byte("POST / HTTP/1.1\r\nHost: a\r\n\r\n{}"), "")) != "{}" || Body([]byte("GET / HTTP/1.1\r\nHo"), "") != nil {
+		t.Fatalf("unexpected body")
+	}
+	if Body([]byte("HTTP/1.1 200 OK\r\n\r\n\x1f\x8b"), "gzip") != nil {
+		t.Fatalf("expected compressed bodies to be skipped")
+	}
+}
@@ -0,0 +1,270 @@
+package pgparse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/emresahna/heimdall/internal/sqlnorm"
+)
+
+const (
+	protocolVersion = 3 << 16
+	maxMessageLen   = 1 << 30
+
+	maxStatements  = 256
+	maxKeptMessage = 64 << 10
+)
+
+// Request is one round trip the server answers with ReadyForQuery: a simple
+// Query, or the extended-protocol messages up to and including a Sync. Query
+// is empty when the statement was prepared before the capture started.
+type Request struct {
+	Query string
+}
+
+type Response struct {
+	Tag   string
+	Code  string
+	Error bool
+}
+
+// Frontend remembers statements prepared with Parse so later Binds can be
+// named.
+type Frontend struct {
+	statements map[string]string
+	batch      string
+	hasBatch   bool
+}
+
+func NewFrontend() *Frontend {
+	return &Frontend{statements: make(map[string]string)}
+}
+
+func (f *Frontend) Parse(data []byte) []Request {
+	var requests []Request
+	if len(data) >= 8 && data[0] == 0 {
+		// StartupMessage, SSLRequest and CancelRequest carry no type byte.
+		length := int(binary.BigEndian.Uint32(data))
+		if length < 8 || binary.BigEndian.Uint32(data[4:]) < protocolVersion {
+			return nil
+		}
+		if length >= len(data) {
+			return nil
+		}
+		data = data[length:]
+	}
+
+	for len(data) >= 5 {
+		typ := data[0]
+		length := int(binary.BigEndian.Uint32(data[1:]))
+		if !isFrontendType(typ) || length < 4 || length > maxMessageLen {
+			break
+		}
+		body := data[5:]
+		cut := length-4 > len(body)
+		if !cut {
+			body = body[:length-4]
+		}
+
+		switch typ {
+		case 'Q':
+			query, _ := cstring(body)
+			requests = append(requests, Request{Query: sqlnorm.Normalize(query, sqlnorm.Postgres)})
+		case 'P':
+			name, rest := cstring(body)
+			query, _ := cstring(rest)
+			if len(f.statements) >= maxStatements {
+				f.statements = make(map[string]string)
+			}
+			f.statements[name] = sqlnorm.Normalize(query, sqlnorm.Postgres)
+			if !f.hasBatch {
+				f.batch = f.statements[name]
+			}
+			f.hasBatch = true
+		case 'B':
+			_, rest := cstring(body)
+			name, _ := cstring(rest)
+			if query := f.statements[name]; query != "" && (!f.hasBatch || f.batch == "") {
+				f.batch = query
+			}
+			f.hasBatch = true
+		case 'S':
+			requests = append(requests, Request{Query: f.batch})
+			f.batch = ""
+			f.hasBatch = false
+		case 'C':
+			if len(body) > 0 && body[0] == 'S' {
+				name, _ := cstring(body[1:])
+				delete(f.statements, name)
+			}
+		}
+		if cut {
+			break
+		}
+		data = data[1+length:]
+	}
+	return requests
+}
+
+// Backend keeps the outcome of the current request, and its place in the
+// message stream, across reads.
+type Backend struct {
+	current Response
+	head    []byte
+	skip    int
+	lost    bool
+}
+
+// Parse returns the responses completed by data, the captured end of a read
+// of size bytes. ok is false when responses may have been missed; chunks are
+// then searched from the end until one ends with ReadyForQuery.
+func (b *Backend) Parse(data []byte, size int) (responses []Response, ok bool) {
+	if unseen := size - len(data); unseen > 0 {
+		if b.lost || len(b.head) > 0 || b.skip < unseen {
+			b.lost = true
+		} else {
+			b.skip -= unseen
+		}
+	}
+	if b.lost {
+		return b.resync(data), false
+	}
+
+	skip := min(b.skip, len(data))
+	b.skip -= skip
+	data = data[skip:]
+	if len(b.head) > 0 {
+		data = append(b.head, data...)
+		b.head = nil
+	}
+	for len(data) > 0 {
+		if len(data) < 5 {
+			b.head = append([]byte(nil), data...)
+			break
+		}
+		typ := data[0]
+		length := int(binary.BigEndian.Uint32(data[1:]))
+		if !isBackendType(typ) || length < 4 || length > maxMessageLen {
+			return append(responses, b.resync(data)...), false
+		}
+		end := 1 + length
+		if end > len(data) {
+			if (typ == 'C' || typ == 'E' || typ == 'Z') && end <= maxKeptMessage {
+				b.head = append([]byte(nil), data...)
+			} else {
+				b.skip = end - len(data)
+			}
+			break
+		}
+		body := data[5:end]
+		switch typ {
+		case 'C':
+			b.current.Tag, _ = cstring(body)
+		case 'E':
+			b.current.Code = errorCode(body)
+			b.current.Error = true
+		case 'Z':
+			responses = append(responses, b.current)
+			b.current = Response{}
+		}
+		data = data[end:]
+	}
+	return responses, true
+}
+
+func (b *Backend) resync(data []byte) []Response {
+	b.head, b.skip = nil, 0
+	responses := b.parseTail(data)
+	b.lost = len(responses) == 0
+	return responses
+}
+
+// parseTail recovers the outcome from a chunk that ends with ReadyForQuery
+// by finding the CommandComplete or ErrorResponse that ends right before it.
+func (b *Backend) parseTail(data []byte) []Response {
+	if !endsWithReady(data) {
+		return nil
+	}
+	resp := b.current
+	b.current = Response{}
+	ready := len(data) - 6
+	for start := ready - 5; start >= 0; start-- {
+		typ := data[start]
+		if typ != 'C' && typ != 'E' {
+			continue
+		}
+		if int(binary.BigEndian.Uint32(data[start+1:])) != ready-start-1 {
+			continue
+		}
+		body := data[start+5 : ready]
+		if len(body) == 0 || body[len(body)-1] != 0 {
+			continue
+		}
+		if typ == 'C' {
+			tag, _ := cstring(body)
+			resp = Response{Tag: tag}
+		} else {
+			resp = Response{Code: errorCode(body), Error: true}
+		}
+		break
+	}
+	return []Response{resp}
+}
+
+func endsWithReady(data []byte) bool {
+	if len(data) < 6 {
+		return false
+	}
+	tail := data[len(data)-6:]
+	status := tail[5]
+	return tail[0] == 'Z' && binary.BigEndian.Uint32(tail[1:]) == 5 &&
+		(status == 'I' || status == 'T' || status == 'E')
+}
+
+func errorCode(body []byte) string {
+	for len(body) > 0 && body[0] != 0 {
+		field := body[0]
+		value, rest := cstring(body[1:])
+		if field == 'C' {
+			return value
+		}
+		body = rest
+	}
+	return ""
+}
+
+func isFrontendType(typ byte) bool {
+	return bytes.IndexByte([]byte("QPBESDCHXFdcfp"), typ) >= 0
+}
+
+func isBackendType(typ byte) bool {
+	return bytes.IndexByte([]byte("RSKZTDCEINnst12AcdGHWvV"), typ) >= 0
+}
+
+func cstring(b []byte) (string, []byte) {
+	idx := bytes.IndexByte(b, 0)
+	if idx < 0 {
+		return string(b), nil
+	}
+	return string(b[:idx]), b[idx+1:]
+}
+
+// CommandTag splits a CommandComplete tag such as "INSERT 0 5" into the
+// command and the number of rows it affected or returned.
+func CommandTag(tag string) (string, uint64) {
+	fields := strings.Fields(tag)
+	if len(fields) < 2 {
+		return tag, 0
+	}
+	rows, err := strconv.ParseUint(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return tag, 0
+	}
+	command := fields[0]
+	if command != "INSERT" {
+		command = strings.Join(fields[:len(fields)-1], " ")
+	}
+	return command, rows
+}
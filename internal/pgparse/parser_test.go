@@ -0,0 +1,99 @@
+package pgparse
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func message(typ byte, body ...string) []byte {
+	var payload []byte
+	for _, part := range body {
+		payload = append(payload, part...)
+	}
+	b := []byte{typ}
+	b = binary.BigEndian.AppendUint32(b, uint32(4+len(payload)))
+	return append(b, payload...)
+}
+
+func TestFrontendParse(t *testing.T) {
+	startup := binary.BigEndian.AppendUint32(nil, uint32(8+len("user\x00app\x00\x00")))
+	startup = binary.BigEndian.AppendUint32(startup, protocolVersion)
+	startup = append(startup, "user\x00app\x00\x00"...)
+
+	data := append(startup, message('Q', "SELECT 1\x00")...)
+	data = append(data, message('P', "s1\x00", "UPDATE t SET a = 5 WHERE id = $1\x00", "\x00\x00")...)
+	data = append(data, message('B', "\x00", "s1\x00", "\x00\x00\x00\x00\x00\x00")...)
+	data = append(data, message('E', "\x00", "\x00\x00\x00\x00")...)
+	data = append(data, message('S')...)
+
+	f := NewFrontend()
+	requests := f.Parse(data)
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2: %+v", len(requests), requests)
+	}
+	if requests[0].Query != "SELECT ?" || requests[1].Query != "UPDATE t SET a = ? WHERE id = $1" {
+		t.Fatalf("unexpected requests %+v", requests)
+	}
+
+	// A later execution of the prepared statement only binds it.
+	data = append(message('B', "\x00", "s1\x00", "\x00\x00\x00\x00\x00\x00"), message('E', "\x00", "\x00\x00\x00\x00")...)
+	data = append(data, message('S')...)
+	requests = f.Parse(data)
+	if len(requests) != 1 || requests[0].Query != "UPDATE t SET a = ? WHERE id = $1" {
+		t.Fatalf("unexpected requests %+v", requests)
+	}
+}
+
+func TestBackendParse(t *testing.T) {
+	var b Backend
+	data := message('1')
+	data = append(data, message('2')...)
+	data = append(data, message('T', "\x00\x00")...)
+	data = append(data, message('D', "\x00\x00")...)
+	data = append(data, message('C', "SELECT 3\x00")...)
+	data = append(data, message('Z', "I")...)
+	data = append(data, message('E', "SERROR\x00", "C23505\x00", "Mduplicate key\x00", "\x00")...)
+	data = append(data, message('Z', "I")...)
+
+	responses, _ := b.Parse(data, 0)
+	if len(responses) != 2 {
+		t.Fatalf("got %d responses, want 2: %+v", len(responses), responses)
+	}
+	if responses[0].Tag != "SELECT 3" || responses[0].Error {
+		t.Fatalf("unexpected first response %+v", responses[0])
+	}
+	if responses[1].Code != "23505" || !responses[1].Error {
+		t.Fatalf("unexpected second response %+v", responses[1])
+	}
+
+	// The end of a large result set, captured from the middle of a row.
+	tail := append([]byte("row data\x00\x01"), message('C', "SELECT 5000\x00")...)
+	tail = append(tail, message('Z', "T")...)
+	responses, _ = b.Parse(tail, 0)
+	if len(responses) != 1 || responses[0].Tag != "SELECT 5000" {
+		t.Fatalf("unexpected tail responses %+v", responses)
+	}
+
+	if responses, _ := b.Parse([]byte("more row data without an end"), 0); responses != nil {
+		t.Fatalf("unexpected responses %+v", responses)
+	}
+}
+
+func TestCommandTag(t *testing.T) {
+	cases := []struct {
+		tag     string
+		command string
+		rows    uint64
+	}{
+		{"SELECT 3", "SELECT", 3},
+		{"INSERT 0 12", "INSERT", 12},
+		{"CREATE TABLE", "CREATE TABLE", 0},
+		{"BEGIN", "BEGIN", 0},
+	}
+	for _, c := range cases {
+		command, rows := CommandTag(c.tag)
+		if command != c.command || rows != c.rows {
+			t.Fatalf("CommandTag(%q) = %q %d, want %q %d", c.tag, command, rows, c.command, c.rows)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+type connKey struct {
+	pid uint32
+	fd  int32
+}
+
+// connState holds parser state for protocols that need more than one event
+// to make sense of a connection, such as HPACK tables for HTTP/2 or the
+// position in a Redis pipeline.
+type connState struct {
+	socket    uint64
+	lastSeen  time.Time
+	h2        *h2Conn
+	redis     *redisConn
+	postgres  *postgresConn
+	mysql     *mysqlConn
+	kafka     *kafkaConn
+	memcached *memcachedConn
+}
+
+// sequence pairs requests with responses on protocols that answer in order,
+// including clients that pipeline several requests before reading.
+type sequence struct {
+	sent     uint64
+	answered uint64
+	// lost is set once responses went uncaptured and cleared by the next
+	// request; responses in between cannot be placed.
+	lost bool
+}
+
+func (s *sequence) next() uint64 {
+	s.sent++
+	s.lost = false
+	return s.sent
+}
+
+// answer returns the number of the request the next response belongs to.
+// A response with no request outstanding means a request was not captured;
+// the counters are brought back in step for the next one.
+func (s *sequence) answer() (uint64, bool) {
+	if s.answered >= s.sent {
+		s.answered = s.sent
+		return 0, false
+	}
+	s.answered++
+	return s.answered, true
+}
+
+type connTable struct {
+	mu    sync.Mutex
+	conns map[connKey]*connState
+}
+
+func newConnTable() *connTable {
+	return &connTable{conns: make(map[connKey]*connState)}
+}
+
+// get returns the state of the connection on fd. State left by another
+// socket means the fd was reused without its close being seen, and is
+// replaced.
+func (t *connTable) get(pid uint32, fd int32, socket uint64) *connState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := connKey{pid: pid, fd: fd}
+	state, ok := t.conns[key]
+	if !ok || state.socket != socket {
+		state = &connState{socket: socket}
+		t.conns[key] = state
+	}
+	state.lastSeen = time.Now()
+	return state
+}
+
+func (t *connTable) remove(pid uint32, fd int32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.conns, connKey{pid: pid, fd: fd})
+}
+
+// expire drops connections idle for longer than ttl.
+func (t *connTable) expire(now time.Time, ttl time.Duration) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	removed := 0
+	for key, state := range t.conns {
+		if now.Sub(state.lastSeen) > ttl {
+			delete(t.conns, key)
+			removed++
+		}
+	}
+	return removed
+}
@@ -2,9 +2,14 @@ package pipeline
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync/atomic"
 	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
 )
 
 type Snapshot struct {
@@ -13,9 +18,21 @@ type Snapshot struct {
 	ParsedResponses    uint64
 	MatchedResponses   uint64
 	UnmatchedResponses uint64
+	DroppedRequests    uint64
+	OrphanedRequests   uint64
+	AbortedRequests    uint64
 	EnqueueDrops       uint64
 	BatchesSent        uint64
 	SendFailures       uint64
+	HookEvents         map[string]uint64
+
+	KernelReserveFailures uint64
+	KernelReadFailures    uint64
+	KernelTruncated       uint64
+	KernelFiltered        uint64
+	ProbeHits             map[string]uint64
+	ClockOffset           time.Duration
+	ClockSteps            uint64
 }
 
 type Diagnostics struct {
@@ -24,9 +41,13 @@ type Diagnostics struct {
 	parsedResponses    atomic.Uint64
 	matchedResponses   atomic.Uint64
 	unmatchedResponses atomic.Uint64
+	droppedRequests    atomic.Uint64
+	orphanedRequests   atomic.Uint64
+	abortedRequests    atomic.Uint64
 	enqueueDrops       atomic.Uint64
 	batchesSent        atomic.Uint64
 	sendFailures       atomic.Uint64
+	collectorStats     func() collector.Stats
 }
 
 func NewDiagnostics() *Diagnostics {
@@ -53,6 +74,23 @@ func (d *Diagnostics) IncUnmatchedResponses() {
 	d.unmatchedResponses.Add(1)
 }
 
+// IncDroppedRequests counts requests displaced from the correlator before
+// their response arrived.
+func (d *Diagnostics) IncDroppedRequests() {
+	d.droppedRequests.Add(1)
+}
+
+// AddOrphanedRequests counts requests that expired without a response.
+func (d *Diagnostics) AddOrphanedRequests(n uint64) {
+	d.orphanedRequests.Add(n)
+}
+
+// AddAbortedRequests counts requests whose socket closed before the
+// response.
+func (d *Diagnostics) AddAbortedRequests(n uint64) {
+	d.abortedRequests.Add(n)
+}
+
 func (d *Diagnostics) IncEnqueueDrops() {
 	d.enqueueDrops.Add(1)
 }
@@ -65,17 +103,38 @@ func (d *Diagnostics) IncSendFailures() {
 	d.sendFailures.Add(1)
 }
 
+// TrackCollector makes Snapshot include the collector's own counters. It must
+// be called before the diagnostics are shared with other goroutines.
+func (d *Diagnostics) TrackCollector(stats func() collector.Stats) {
+	d.collectorStats = stats
+}
+
 func (d *Diagnostics) Snapshot() Snapshot {
-	return Snapshot{
+	snapshot := Snapshot{
 		EventsRead:         d.eventsRead.Load(),
 		ParsedRequests:     d.parsedRequests.Load(),
 		ParsedResponses:    d.parsedResponses.Load(),
 		MatchedResponses:   d.matchedResponses.Load(),
 		UnmatchedResponses: d.unmatchedResponses.Load(),
+		DroppedRequests:    d.droppedRequests.Load(),
+		OrphanedRequests:   d.orphanedRequests.Load(),
+		AbortedRequests:    d.abortedRequests.Load(),
 		EnqueueDrops:       d.enqueueDrops.Load(),
 		BatchesSent:        d.batchesSent.Load(),
 		SendFailures:       d.sendFailures.Load(),
 	}
+	if d.collectorStats != nil {
+		stats := d.collectorStats()
+		snapshot.HookEvents = stats.HookEvents
+		snapshot.KernelReserveFailures = stats.KernelReserveFailures
+		snapshot.KernelReadFailures = stats.KernelReadFailures
+		snapshot.KernelTruncated = stats.KernelTruncated
+		snapshot.KernelFiltered = stats.KernelFiltered
+		snapshot.ProbeHits = stats.ProbeHits
+		snapshot.ClockOffset = stats.ClockOffset
+		snapshot.ClockSteps = stats.ClockSteps
+	}
+	return snapshot
 }
 
 func StartDiagnosticsReporter(ctx context.Context, diagnostics *Diagnostics, interval time.Duration) {
@@ -95,12 +154,15 @@ func StartDiagnosticsReporter(ctx context.Context, diagnostics *Diagnostics, int
 		case <-ticker.C:
 			current := diagnostics.Snapshot()
 			log.Printf(
-				"agent diagnostics total(events=%d req=%d resp=%d matched=%d unmatched=%d drops=%d batches=%d send_failures=%d) delta(events=%d req=%d resp=%d matched=%d unmatched=%d drops=%d batches=%d send_failures=%d)",
+				"agent diagnostics total(events=%d req=%d resp=%d matched=%d unmatched=%d dropped_req=%d orphaned_req=%d aborted_req=%d drops=%d batches=%d send_failures=%d) delta(events=%d req=%d resp=%d matched=%d unmatched=%d dropped_req=%d orphaned_req=%d aborted_req=%d drops=%d batches=%d send_failures=%d)",
 				current.EventsRead,
 				current.ParsedRequests,
 				current.ParsedResponses,
 				current.MatchedResponses,
 				current.UnmatchedResponses,
+				current.DroppedRequests,
+				current.OrphanedRequests,
+				current.AbortedRequests,
 				current.EnqueueDrops,
 				current.BatchesSent,
 				current.SendFailures,
@@ -109,11 +171,51 @@ func StartDiagnosticsReporter(ctx context.Context, diagnostics *Diagnostics, int
 				current.ParsedResponses-last.ParsedResponses,
 				current.MatchedResponses-last.MatchedResponses,
 				current.UnmatchedResponses-last.UnmatchedResponses,
+				current.DroppedRequests-last.DroppedRequests,
+				current.OrphanedRequests-last.OrphanedRequests,
+				current.AbortedRequests-last.AbortedRequests,
 				current.EnqueueDrops-last.EnqueueDrops,
 				current.BatchesSent-last.BatchesSent,
 				current.SendFailures-last.SendFailures,
 			)
+			if len(current.HookEvents) > 0 {
+				log.Printf("agent diagnostics hooks(%s)", formatCounts(current.HookEvents))
+			}
+			if diagnostics.collectorStats != nil {
+				log.Printf(
+					"agent diagnostics kernel(reserve_failures=%d read_failures=%d truncated=%d filtered=%d) delta(reserve_failures=%d read_failures=%d truncated=%d filtered=%d) probes(%s)",
+					current.KernelReserveFailures,
+					current.KernelReadFailures,
+					current.KernelTruncated,
+					current.KernelFiltered,
+					current.KernelReserveFailures-last.KernelReserveFailures,
+					current.KernelReadFailures-last.KernelReadFailures,
+					current.KernelTruncated-last.KernelTruncated,
+					current.KernelFiltered-last.KernelFiltered,
+					formatCounts(current.ProbeHits),
+				)
+				log.Printf(
+					"agent diagnostics clock(boot=%s offset_ns=%d steps=%d)",
+					time.Unix(0, int64(current.ClockOffset)).UTC().Format(time.RFC3339Nano),
+					int64(current.ClockOffset),
+					current.ClockSteps,
+				)
+			}
 			last = current
 		}
 	}
 }
+
+func formatCounts(counts map[string]uint64) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, counts[name]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,53 @@
+package pipeline
+
+import (
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/correlation"
+	"github.com/emresahna/heimdall/internal/dnsparse"
+)
+
+// handleDNS pairs queries and responses by transaction ID. Each datagram is
+// a whole message, so no connection state is needed; resolvers that send
+// the A and AAAA queries on one socket use a different ID for each.
+func (p *Processor) handleDNS(ev collector.Event) {
+	msg, ok := dnsparse.Parse(ev.Data)
+	if !ok || msg.Response != (ev.Direction == collector.DirectionResponse) {
+		return
+	}
+	key := requestKey(ev, uint64(msg.ID))
+
+	if !msg.Response {
+		if p.diagnostics != nil {
+			p.diagnostics.IncParsedRequests()
+		}
+		p.add(correlation.Request{
+			Key:      key,
+			Tid:      ev.Tid,
+			CgroupID: ev.CgroupID,
+			Role:     ev.Role().String(),
+			Type:     "dns",
+			Method:   dnsparse.TypeName(msg.Type),
+			Path:     msg.Name,
+			Local:    ev.Local,
+			Remote:   ev.Remote,
+			Started:  ev.Timestamp,
+		})
+		return
+	}
+
+	if p.diagnostics != nil {
+		p.diagnostics.IncParsedResponses()
+	}
+	req, ok := p.match(key)
+	if !ok {
+		return
+	}
+	var errorCode string
+	if msg.RCode != 0 {
+		errorCode = dnsparse.RCodeName(msg.RCode)
+	}
+	p.complete(req, ev.Timestamp, outcome{
+		err:       msg.RCode != 0,
+		errorCode: errorCode,
+	})
+}
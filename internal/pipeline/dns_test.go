@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/telemetry"
+)
+
+func dnsMessage(id, flags uint16, name string, qtype uint16) []byte {
+	msg := []byte{byte(id >> 8), byte(id), byte(flags >> 8), byte(flags), 0, 1, 0, 0, 0, 0, 0, 0}
+	msg = append(msg, name...)
+	return append(msg, byte(qtype>>8), byte(qtype), 0, 1)
+}
+
+func TestHandleDNS(t *testing.T) {
+	const name = "\x03api\x07example\x03com\x00"
+	type write struct {
+		dir  collector.Direction
+		data []byte
+	}
+	tests := []struct {
+		name   string
+		writes []write
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "queries answered out of order",
+			writes: []write{
+				{collector.DirectionRequest, dnsMessage(1, 0x0100, name, 1)},
+				{collector.DirectionRequest, dnsMessage(2, 0x0100, name, 28)},
+				{collector.DirectionResponse, dnsMessage(2, 0x8180, name, 28)},
+				{collector.DirectionResponse, dnsMessage(1, 0x8180, name, 1)},
+			},
+			want: []telemetry.LogEntry{
+				{Method: "AAAA", Path: "api.example.com"},
+				{Method: "A", Path: "api.example.com"},
+			},
+		},
+		{
+			name: "error code",
+			writes: []write{
+				{collector.DirectionRequest, dnsMessage(7, 0x0100, name, 1)},
+				{collector.DirectionResponse, dnsMessage(7, 0x8183, name, 1)},
+			},
+			want: []telemetry.LogEntry{{Method: "A", Path: "api.example.com", Error: true, ErrorCode: "NXDOMAIN"}},
+		},
+		{
+			name: "response seen as a request",
+			writes: []write{
+				{collector.DirectionRequest, dnsMessage(7, 0x8180, name, 1)},
+				{collector.DirectionResponse, dnsMessage(7, 0x8180, name, 1)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{})
+			start := time.Unix(100, 0)
+			for i, w := range tt.writes {
+				p.HandleEvent(collector.Event{
+					Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+					Pid:       1,
+					Fd:        3,
+					Direction: w.dir,
+					Protocol:  collector.ProtocolDNS,
+					Data:      w.data,
+				})
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Type != "dns" || g.Method != want.Method || g.Path != want.Path || g.Error != want.Error || g.ErrorCode != want.ErrorCode {
+					t.Errorf("entry %d: got %s %s %s error=%v %q, want %s %s error=%v %q", i, g.Type, g.Method, g.Path, g.Error, g.ErrorCode, want.Method, want.Path, want.Error, want.ErrorCode)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/emresahna/heimdall/internal/correlation"
+	"github.com/emresahna/heimdall/internal/httpparse"
+)
+
+// setRequestHeaders copies the headers found through get, which looks up a
+// header by lowercase name, onto req: Host, User-Agent, the trace context
+// and the allowlisted headers.
+func (p *Processor) setRequestHeaders(req *correlation.Request, get func(string) string) {
+	req.Host = get("host")
+	req.UserAgent = get("user-agent")
+	req.Attributes = p.captureHeaders(get)
+	if tc, ok := httpparse.ParseTraceContext(get); ok {
+		req.TraceID = tc.TraceID
+		req.SpanID = tc.SpanID
+		req.TraceSampled = tc.Sampled
+	}
+}
+
+// setResponseHeaders copies Content-Type, Content-Length and the
+// allowlisted headers of a response onto out.
+func (p *Processor) setResponseHeaders(out *outcome, get func(string) string) {
+	out.contentType = get("content-type")
+	out.contentLength, _ = strconv.ParseUint(get("content-length"), 10, 64)
+	out.attributes = p.captureHeaders(get)
+}
+
+func (p *Processor) captureHeaders(get func(string) string) map[string]string {
+	var attrs map[string]string
+	for _, name := range p.opts.HTTPHeaders {
+		name = strings.ToLower(name)
+		value := get(name)
+		if value == "" {
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		attrs[name] = value
+	}
+	return attrs
+}
+
+// mergeAttributes combines the headers captured from a request and its
+// response. A header sent in both keeps the request's value.
+func mergeAttributes(request, response map[string]string) map[string]string {
+	if len(response) == 0 {
+		return request
+	}
+	if len(request) == 0 {
+		return response
+	}
+	merged := make(map[string]string, len(request)+len(response))
+	for name, value := range response {
+		merged[name] = value
+	}
+	for name, value := range request {
+		merged[name] = value
+	}
+	return merged
+}
@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/telemetry"
+)
+
+func TestCaptureHeaders(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	type write struct {
+		dir  collector.Direction
+		data []byte
+	}
+	tests := []struct {
+		name     string
+		protocol collector.Protocol
+		writes   func(req, resp *h2Writer) []write
+	}{
+		{
+			name: "http/1",
+			writes: func(req, resp *h2Writer) []write {
+				return []write{
+					{collector.DirectionRequest, []byte("GET /a HTTP/1.1\r\nHost: api\r\nUser-Agent: curl/8\r\nTraceparent: " + traceparent + "\r\nX-Tenant: t1\r\nX-Cache: req\r\n\r\n")},
+					{collector.DirectionResponse, []byte("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 2\r\nX-Cache: HIT\r\nX-Region: eu\r\n\r\nok")},
+				}
+			},
+		},
+		{
+			name:     "http/2",
+			protocol: collector.ProtocolHTTP2,
+			writes: func(req, resp *h2Writer) []write {
+				return []write{
+					{collector.DirectionRequest, req.headers(1, h2EndStream, ":method", "GET", ":path", "/a", ":authority", "api", "user-agent", "curl/8", "traceparent", traceparent, "x-tenant", "t1", "x-cache", "req")},
+					{collector.DirectionResponse, resp.headers(1, h2EndStream, ":status", "200", "content-type", "text/plain", "content-length", "2", "x-cache", "HIT", "x-region", "eu")},
+				}
+			},
+		},
+	}
+	want := telemetry.LogEntry{
+		Host:          "api",
+		UserAgent:     "curl/8",
+		ContentType:   "text/plain",
+		ContentLength: 2,
+		TraceID:       "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:        "00f067aa0ba902b7",
+		TraceSampled:  true,
+		Attributes:    map[string]string{"x-tenant": "t1", "x-cache": "req", "x-region": "eu"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{HTTPHeaders: []string{"X-Tenant", "X-Cache", "X-Region"}})
+			start := time.Unix(100, 0)
+			for i, w := range tt.writes(newH2Writer(), newH2Writer()) {
+				p.HandleEvent(collector.Event{
+					Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+					Pid:       1,
+					Fd:        3,
+					Direction: w.dir,
+					Protocol:  tt.protocol,
+					Data:      w.data,
+				})
+			}
+			got := entries(p)
+			if len(got) != 1 {
+				t.Fatalf("expected 1 entry, got %d", len(got))
+			}
+			g := got[0]
+			if g.Host != want.Host || g.UserAgent != want.UserAgent || g.ContentType != want.ContentType || g.ContentLength != want.ContentLength {
+				t.Errorf("unexpected headers %q %q %q %d", g.Host, g.UserAgent, g.ContentType, g.ContentLength)
+			}
+			if g.TraceID != want.TraceID || g.SpanID != want.SpanID || g.TraceSampled != want.TraceSampled {
+				t.Errorf("unexpected trace context %q %q %v", g.TraceID, g.SpanID, g.TraceSampled)
+			}
+			if !maps.Equal(g.Attributes, want.Attributes) {
+				t.Errorf("unexpected attributes %v", g.Attributes)
+			}
+		})
+	}
+}
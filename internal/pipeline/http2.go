@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/correlation"
+	"github.com/emresahna/heimdall/internal/h2parse"
+	"golang.org/x/net/http2/hpack"
+)
+
+// Responses whose end has not been seen are dropped once a connection has
+// this many, which bounds memory when END_STREAM frames are not captured.
+const maxPendingH2Responses = 1024
+
+// grpcUnknown is reported when a gRPC call ends without a grpc-status.
+const grpcUnknown = 2
+
+type h2Conn struct {
+	requests  *h2parse.Decoder
+	responses *h2parse.Decoder
+	pending   map[uint32]h2Response
+}
+
+type h2Response struct {
+	status        uint32
+	grpcStatus    uint32
+	hasGRPCStatus bool
+	// The outcome fields read from the response headers.
+	headers outcome
+}
+
+func newH2Conn() *h2Conn {
+	return &h2Conn{
+		requests:  h2parse.NewDecoder(),
+		responses: h2parse.NewDecoder(),
+		pending:   make(map[uint32]h2Response),
+	}
+}
+
+func (p *Processor) handleHTTP2(ev collector.Event) {
+	state := p.conns.get(ev.Pid, ev.Fd, ev.Socket)
+	if state.h2 == nil {
+		state.h2 = newH2Conn()
+	}
+	conn := state.h2
+	// Once either side's header blocks cannot be decoded, requests could no
+	// longer be told apart from their responses.
+	if conn.requests.Failed() || conn.responses.Failed() {
+		conn.pending = nil
+		return
+	}
+
+	if ev.Direction == collector.DirectionRequest {
+		frames, _ := conn.requests.Parse(ev.Data, int(ev.Length))
+		for _, frame := range frames {
+			p.addHTTP2Request(ev, frame)
+		}
+		return
+	}
+
+	frames, _ := conn.responses.Parse(ev.Data, int(ev.Length))
+	for _, frame := range frames {
+		p.handleHTTP2Response(ev, conn, frame)
+	}
+}
+
+func (p *Processor) addHTTP2Request(ev collector.Event, frame h2parse.Frame) {
+	method := h2parse.Header(frame.Headers, ":method")
+	if method == "" {
+		return
+	}
+	if p.diagnostics != nil {
+		p.diagnostics.IncParsedRequests()
+	}
+
+	typ := "http"
+	if h2parse.IsGRPC(frame.Headers) {
+		typ = "grpc"
+	}
+	req := correlation.Request{
+		Key:      requestKey(ev, uint64(frame.StreamID)),
+		Tid:      ev.Tid,
+		CgroupID: ev.CgroupID,
+		Role:     ev.Role().String(),
+		Type:     typ,
+		Method:   method,
+		Path:     h2parse.Header(frame.Headers, ":path"),
+		Local:    ev.Local,
+		Remote:   ev.Remote,
+		Started:  ev.Timestamp,
+	}
+	p.setRequestHeaders(&req, h2Header(frame.Headers))
+	p.add(req)
+}
+
+// h2Header looks up headers by name, reading Host from :authority as
+// HTTP/2 clients send it there instead.
+func h2Header(fields []hpack.HeaderField) func(string) string {
+	return func(name string) string {
+		if name == "host" {
+			if authority := h2parse.Header(fields, ":authority"); authority != "" {
+				return authority
+			}
+		}
+		return h2parse.Header(fields, name)
+	}
+}
+
+// handleHTTP2Response completes plain HTTP/2 requests on the response
+// headers, like HTTP/1, and gRPC calls once the trailers carrying
+// grpc-status end the stream.
+func (p *Processor) handleHTTP2Response(ev collector.Event, conn *h2Conn, frame h2parse.Frame) {
+	resp, seen := conn.pending[frame.StreamID]
+	if status, ok := h2parse.Status(frame.Headers); ok {
+		resp.status = status
+		seen = true
+		p.setResponseHeaders(&resp.headers, h2Header(frame.Headers))
+		if p.diagnostics != nil {
+			p.diagnostics.IncParsedResponses()
+		}
+	}
+	if status, ok := h2parse.GRPCStatus(frame.Headers); ok {
+		resp.grpcStatus = status
+		resp.hasGRPCStatus = true
+	}
+	if !seen {
+		return
+	}
+
+	key := requestKey(ev, uint64(frame.StreamID))
+	grpc := h2parse.IsGRPC(frame.Headers) || resp.hasGRPCStatus
+	if !frame.EndStream && (grpc || resp.status == 0) {
+		if len(conn.pending) >= maxPendingH2Responses {
+			conn.pending = make(map[uint32]h2Response)
+		}
+		conn.pending[frame.StreamID] = resp
+		return
+	}
+	delete(conn.pending, frame.StreamID)
+
+	req, ok := p.match(key)
+	if !ok {
+		return
+	}
+
+	out := resp.headers
+	out.status = resp.status
+	if req.Type == "grpc" {
+		out.status = grpcUnknown
+		if resp.hasGRPCStatus {
+			out.status = resp.grpcStatus
+		}
+		if out.status != 0 {
+			out.err = true
+			out.errorCode = h2parse.GRPCStatusName(out.status)
+		}
+	}
+	p.complete(req, ev.Timestamp, out)
+}
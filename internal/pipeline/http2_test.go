@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/h2parse"
+	"github.com/emresahna/heimdall/internal/telemetry"
+	"golang.org/x/net/http2/hpack"
+)
+
+const (
+	h2EndStream  = 0x1
+	h2EndHeaders = 0x4
+)
+
+func h2Frame(typ, flags uint8, stream uint32, payload []byte) []byte {
+	b := []byte{byte(len(payload) >> 16), byte(len(payload) >> 8), byte(len(payload)), typ, flags}
+	b = binary.BigEndian.AppendUint32(b, stream)
+	return append(b, payload...)
+}
+
+// h2Writer encodes the frames sent in one direction, sharing HPACK state
+// between them like a real connection.
+type h2Writer struct {
+	buf bytes.Buffer
+	enc *hpack.Encoder
+}
+
+func newH2Writer() *h2Writer {
+	w := &h2Writer{}
+	w.enc = hpack.NewEncoder(&w.buf)
+	return w
+}
+
+func (w *h2Writer) headers(stream uint32, flags uint8, fields ...string) []byte {
+	w.buf.Reset()
+	for i := 0; i+1 < len(fields); i += 2 {
+		w.enc.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]})
+	}
+	return h2Frame(0x1, flags|h2EndHeaders, stream, w.buf.Bytes())
+}
+
+func h2Data(stream uint32, flags uint8, data string) []byte {
+	return h2Frame(0x0, flags, stream, []byte(data))
+}
+
+func TestHandleHTTP2(t *testing.T) {
+	type write struct {
+		dir  collector.Direction
+		data []byte
+	}
+	tests := []struct {
+		name   string
+		writes func(req, resp *h2Writer) []write
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "plain request",
+			writes: func(req, resp *h2Writer) []write {
+				return []write{
+					{collector.DirectionRequest, append(append([]byte{}, h2parse.Preface...), req.headers(1, h2EndStream, ":method", "GET", ":path", "/a", ":scheme", "https")...)},
+					{collector.DirectionResponse, resp.headers(1, 0, ":status", "200")},
+				}
+			},
+			want: []telemetry.LogEntry{{Type: "http", Method: "GET", Path: "/a", Status: 200}},
+		},
+		{
+			name: "grpc status from trailers",
+			writes: func(req, resp *h2Writer) []write {
+				return []write{
+					{collector.DirectionRequest, append(req.headers(1, 0, ":method", "POST", ":path", "/pkg.Svc/Get", "content-type", "application/grpc"), h2Data(1, h2EndStream, "msg")...)},
+					{collector.DirectionResponse, append(resp.headers(1, 0, ":status", "200", "content-type", "application/grpc"), h2Data(1, 0, "msg")...)},
+					{collector.DirectionResponse, resp.headers(1, h2EndStream, "grpc-status", "5")},
+				}
+			},
+			want: []telemetry.LogEntry{{Type: "grpc", Method: "POST", Path: "/pkg.Svc/Get", Status: 5}},
+		},
+		{
+			name: "grpc stream ended without trailers",
+			writes: func(req, resp *h2Writer) []write {
+				return []write{
+					{collector.DirectionRequest, req.headers(1, h2EndStream, ":method", "POST", ":path", "/pkg.Svc/Get", "content-type", "application/grpc")},
+					{collector.DirectionResponse, resp.headers(1, 0, ":status", "200", "content-type", "application/grpc")},
+					{collector.DirectionResponse, h2Data(1, h2EndStream, "")},
+				}
+			},
+			want: []telemetry.LogEntry{{Type: "grpc", Method: "POST", Path: "/pkg.Svc/Get", Status: grpcUnknown}},
+		},
+		{
+			name: "interleaved streams",
+			writes: func(req, resp *h2Writer) []write {
+				return []write{
+					{collector.DirectionRequest, append(req.headers(1, h2EndStream, ":method", "GET", ":path", "/a"), req.headers(3, h2EndStream, ":method", "GET", ":path", "/b")...)},
+					{collector.DirectionResponse, resp.headers(3, h2EndStream, ":status", "404")},
+					{collector.DirectionResponse, resp.headers(1, h2EndStream, ":status", "200")},
+				}
+			},
+			want: []telemetry.LogEntry{
+				{Type: "http", Method: "GET", Path: "/b", Status: 404},
+				{Type: "http", Method: "GET", Path: "/a", Status: 200},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{})
+			start := time.Unix(100, 0)
+			for i, w := range tt.writes(newH2Writer(), newH2Writer()) {
+				p.HandleEvent(collector.Event{
+					Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+					Pid:       1,
+					Fd:        3,
+					Direction: w.dir,
+					Protocol:  collector.ProtocolHTTP2,
+					Data:      w.data,
+				})
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Type != want.Type || g.Method != want.Method || g.Path != want.Path || g.Status != want.Status {
+					t.Errorf("entry %d: got %s %s %s %d, want %s %s %s %d", i, g.Type, g.Method, g.Path, g.Status, want.Type, want.Method, want.Path, want.Status)
+				}
+			}
+		})
+	}
+}
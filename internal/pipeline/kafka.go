@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"strings"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/correlation"
+	"github.com/emresahna/heimdall/internal/kafkaparse"
+)
+
+const maxKafkaInflight = 1024
+
+type kafkaRequest struct {
+	apiKey     int16
+	apiVersion int16
+}
+
+// kafkaConn remembers which API each correlation_id belongs to, since the
+// response header does not repeat it and the body layout depends on it.
+type kafkaConn struct {
+	requests  kafkaparse.Framer
+	responses kafkaparse.Framer
+	inflight  map[int32]kafkaRequest
+}
+
+func (p *Processor) handleKafka(ev collector.Event) {
+	state := p.conns.get(ev.Pid, ev.Fd, ev.Socket)
+	if state.kafka == nil {
+		state.kafka = &kafkaConn{inflight: make(map[int32]kafkaRequest)}
+	}
+	conn := state.kafka
+
+	if ev.Direction == collector.DirectionRequest {
+		for _, msg := range conn.requests.Messages(ev.Data) {
+			req, ok := kafkaparse.ParseRequest(msg)
+			if !ok || req.NoResponse {
+				continue
+			}
+			if p.diagnostics != nil {
+				p.diagnostics.IncParsedRequests()
+			}
+			if len(conn.inflight) >= maxKafkaInflight {
+				conn.inflight = make(map[int32]kafkaRequest)
+			}
+			conn.inflight[req.CorrelationID] = kafkaRequest{apiKey: req.APIKey, apiVersion: req.APIVersion}
+
+			p.add(correlation.Request{
+				Key:      requestKey(ev, uint64(uint32(req.CorrelationID))),
+				Tid:      ev.Tid,
+				CgroupID: ev.CgroupID,
+				Role:     ev.Role().String(),
+				Type:     "kafka",
+				Method:   kafkaparse.APIName(req.APIKey),
+				Path:     strings.Join(req.Topics, ","),
+				Local:    ev.Local,
+				Remote:   ev.Remote,
+				Started:  ev.Timestamp,
+			})
+		}
+		return
+	}
+
+	for _, msg := range conn.responses.Messages(ev.Data) {
+		id, ok := kafkaparse.CorrelationID(msg)
+		if !ok {
+			continue
+		}
+		sent, ok := conn.inflight[id]
+		if !ok {
+			continue
+		}
+		delete(conn.inflight, id)
+		if p.diagnostics != nil {
+			p.diagnostics.IncParsedResponses()
+		}
+
+		resp := kafkaparse.ParseResponse(msg, sent.apiKey, sent.apiVersion)
+		req, ok := p.match(requestKey(ev, uint64(uint32(id))))
+		if !ok {
+			continue
+		}
+		var errorCode string
+		if resp.ErrorCode != 0 {
+			errorCode = kafkaparse.ErrorName(resp.ErrorCode)
+		}
+		p.complete(req, ev.Timestamp, outcome{
+			err:       resp.ErrorCode != 0,
+			errorCode: errorCode,
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/telemetry"
+)
+
+func kafkaSized(msg []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(msg))), msg...)
+}
+
+func kafkaString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func kafkaRequestHeader(apiKey, version int16, id int32) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(apiKey))
+	b = binary.BigEndian.AppendUint16(b, uint16(version))
+	b = binary.BigEndian.AppendUint32(b, uint32(id))
+	return kafkaString(b, "client")
+}
+
+func kafkaProduce(id int32, acks int16) []byte {
+	b := kafkaRequestHeader(0, 7, id)
+	b = binary.BigEndian.AppendUint16(b, 0xffff)
+	b = binary.BigEndian.AppendUint16(b, uint16(acks))
+	b = binary.BigEndian.AppendUint32(b, 30000)
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = kafkaString(b, "orders")
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = binary.BigEndian.AppendUint32(b, 3)
+	return kafkaSized(append(b, 'a', 'b', 'c'))
+}
+
+func kafkaProduceResponse(id int32, code int16) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(id))
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = kafkaString(b, "orders")
+	b = binary.BigEndian.AppendUint32(b, 1)
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = binary.BigEndian.AppendUint16(b, uint16(code))
+	b = append(b, make([]byte, 24)...)
+	return kafkaSized(binary.BigEndian.AppendUint32(b, 0))
+}
+
+func TestHandleKafka(t *testing.T) {
+	metadata := kafkaSized(append(kafkaRequestHeader(3, 1, 2), 0, 0, 0, 0))
+	apiVersions := kafkaSized(kafkaRequestHeader(18, 0, 1))
+	type write struct {
+		dir  collector.Direction
+		data []byte
+	}
+	tests := []struct {
+		name   string
+		writes []write
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "produce",
+			writes: []write{
+				{collector.DirectionRequest, kafkaProduce(5, 1)},
+				{collector.DirectionResponse, kafkaProduceResponse(5, 0)},
+			},
+			want: []telemetry.LogEntry{{Method: "Produce", Path: "orders"}},
+		},
+		{
+			name: "partition error",
+			writes: []write{
+				{collector.DirectionRequest, kafkaProduce(5, -1)},
+				{collector.DirectionResponse, kafkaProduceResponse(5, 6)},
+			},
+			want: []telemetry.LogEntry{{Method: "Produce", Path: "orders", Error: true, ErrorCode: "NOT_LEADER_OR_FOLLOWER"}},
+		},
+		{
+			name: "produce without acks",
+			writes: []write{
+				{collector.DirectionRequest, kafkaProduce(5, 0)},
+				{collector.DirectionResponse, kafkaProduceResponse(5, 0)},
+			},
+		},
+		{
+			name: "responses paired by correlation id",
+			writes: []write{
+				{collector.DirectionRequest, append(append([]byte{}, apiVersions...), metadata...)},
+				{collector.DirectionResponse, kafkaSized(binary.BigEndian.AppendUint32(nil, 2))},
+				{collector.DirectionResponse, kafkaSized(binary.BigEndian.AppendUint32(nil, 1))},
+			},
+			want: []telemetry.LogEntry{{Method: "Metadata"}, {Method: "ApiVersions"}},
+		},
+		{
+			name: "size written separately",
+			writes: []write{
+				{collector.DirectionRequest, kafkaProduce(5, 1)[:4]},
+				{collector.DirectionRequest, kafkaProduce(5, 1)[4:]},
+				{collector.DirectionResponse, kafkaProduceResponse(5, 0)[:4]},
+				{collector.DirectionResponse, kafkaProduceResponse(5, 0)[4:]},
+			},
+			want: []telemetry.LogEntry{{Method: "Produce", Path: "orders"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{})
+			start := time.Unix(100, 0)
+			for i, w := range tt.writes {
+				p.HandleEvent(collector.Event{
+					Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+					Pid:       1,
+					Fd:        3,
+					Direction: w.dir,
+					Protocol:  collector.ProtocolKafka,
+					Data:      w.data,
+				})
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Type != "kafka" || g.Method != want.Method || g.Path != want.Path || g.Error != want.Error || g.ErrorCode != want.ErrorCode {
+					t.Errorf("entry %d: got %s %s %s error=%v %q, want %s %s error=%v %q", i, g.Type, g.Method, g.Path, g.Error, g.ErrorCode, want.Method, want.Path, want.Error, want.ErrorCode)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/correlation"
+	"github.com/emresahna/heimdall/internal/memcacheparse"
+	"github.com/emresahna/heimdall/internal/respparse"
+)
+
+const maxMemcachedPending = 1024
+
+type memcachedRequest struct {
+	keys      int
+	retrieval bool
+}
+
+// memcachedConn numbers commands and responses like redisConn; memcached
+// answers in order in both protocols. hits collects the answers to quiet
+// binary gets until the response that ends their batch.
+type memcachedConn struct {
+	requests  memcacheparse.RequestParser
+	responses memcacheparse.ResponseParser
+	seq       sequence
+	pending   map[uint64]memcachedRequest
+	hits      int
+}
+
+func (p *Processor) handleMemcached(ev collector.Event) {
+	state := p.conns.get(ev.Pid, ev.Fd, ev.Socket)
+	if state.memcached == nil {
+		state.memcached = &memcachedConn{pending: make(map[uint64]memcachedRequest)}
+	}
+	conn := state.memcached
+
+	if ev.Direction == collector.DirectionRequest {
+		for _, cmd := range conn.requests.Parse(ev.Data) {
+			if p.diagnostics != nil {
+				p.diagnostics.IncParsedRequests()
+			}
+			if len(conn.pending) >= maxMemcachedPending {
+				conn.pending = make(map[uint64]memcachedRequest)
+			}
+			stream := conn.seq.next()
+			conn.pending[stream] = memcachedRequest{keys: cmd.Keys, retrieval: cmd.Retrieval}
+
+			p.add(correlation.Request{
+				Key:      requestKey(ev, stream),
+				Tid:      ev.Tid,
+				CgroupID: ev.CgroupID,
+				Role:     ev.Role().String(),
+				Type:     "memcached",
+				Method:   cmd.Name,
+				Path:     respparse.FormatKey(cmd.Key, p.opts.MemcachedKeys),
+				Local:    ev.Local,
+				Remote:   ev.Remote,
+				Started:  ev.Timestamp,
+			})
+		}
+		return
+	}
+
+	if conn.seq.lost {
+		return
+	}
+	responses, ok := conn.responses.Parse(ev.Data, int(ev.Length))
+	for _, resp := range responses {
+		if resp.Quiet {
+			conn.hits += resp.Values
+			continue
+		}
+		hits := conn.hits + resp.Values
+		conn.hits = 0
+		if p.diagnostics != nil {
+			p.diagnostics.IncParsedResponses()
+		}
+		stream, ok := conn.seq.answer()
+		if !ok {
+			if p.diagnostics != nil {
+				p.diagnostics.IncUnmatchedResponses()
+			}
+			continue
+		}
+		sent := conn.pending[stream]
+		delete(conn.pending, stream)
+
+		req, ok := p.match(requestKey(ev, stream))
+		if !ok {
+			continue
+		}
+		out := outcome{
+			err:       resp.Error,
+			errorCode: resp.Status,
+			keys:      uint32(sent.keys),
+		}
+		if sent.retrieval {
+			out.rows = uint64(hits)
+			out.hitRatio = memcacheparse.HitRatio(hits, sent.keys)
+		}
+		p.complete(req, ev.Timestamp, out)
+	}
+	if !ok {
+		p.resync(ev, &conn.seq)
+		conn.pending = make(map[uint64]memcachedRequest)
+		conn.hits = 0
+	}
+}
@@ -0,0 +1,167 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/respparse"
+	"github.com/emresahna/heimdall/internal/telemetry"
+)
+
+// memcachedBinary builds a binary protocol packet; status is the vbucket
+// field of a request.
+func memcachedBinary(magic, op byte, status uint16, extras, key, value string) []byte {
+	b := []byte{magic, op}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(key)))
+	b = append(b, byte(len(extras)), 0)
+	b = binary.BigEndian.AppendUint16(b, status)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(extras)+len(key)+len(value)))
+	b = append(b, make([]byte, 12)...)
+	return append(b, extras+key+value...)
+}
+
+func TestHandleMemcached(t *testing.T) {
+	type write struct {
+		dir  collector.Direction
+		data []byte
+	}
+	tests := []struct {
+		name   string
+		writes []write
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "text multi-get",
+			writes: []write{
+				{collector.DirectionRequest, []byte("get a b c\r\n")},
+				{collector.DirectionResponse, []byte("VALUE a 0 1\r\nx\r\nVALUE c 0 1\r\ny\r\nEND\r\n")},
+			},
+			want: []telemetry.LogEntry{{Method: "get", Path: "a", Keys: 3, Rows: 2, HitRatio: 2.0 / 3}},
+		},
+		{
+			name: "text pipeline",
+			writes: []write{
+				{collector.DirectionRequest, []byte("set k 0 0 1\r\nv\r\ndelete k\r\nincr n 1\r\n")},
+				{collector.DirectionResponse, []byte("STORED\r\nNOT_FOUND\r\nCLIENT_ERROR bad\r\n")},
+			},
+			want: []telemetry.LogEntry{
+				{Method: "set", Path: "k", Keys: 1},
+				{Method: "delete", Path: "k", Keys: 1, ErrorCode: "NOT_FOUND"},
+				{Method: "incr", Path: "n", Keys: 1, Error: true, ErrorCode: "CLIENT_ERROR"},
+			},
+		},
+		{
+			name: "binary quiet gets",
+			writes: []write{
+				{collector.DirectionRequest, append(append(
+					memcachedBinary(0x80, 0x09, 0, "", "a", ""),
+					memcachedBinary(0x80, 0x09, 0, "", "b", "")...),
+					memcachedBinary(0x80, 0x0a, 0, "", "", "")...)},
+				{collector.DirectionResponse, append(
+					memcachedBinary(0x81, 0x09, 0, "\x00\x00\x00\x00", "", "x"),
+					memcachedBinary(0x81, 0x0a, 0, "", "", "")...)},
+			},
+			want: []telemetry.LogEntry{{Method: "get", Path: "a", Keys: 2, Rows: 1, HitRatio: 0.5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{MemcachedKeys: respparse.KeyPlain})
+			start := time.Unix(100, 0)
+			for i, w := range tt.writes {
+				p.HandleEvent(collector.Event{
+					Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+					Pid:       1,
+					Fd:        3,
+					Direction: w.dir,
+					Protocol:  collector.ProtocolMemcached,
+					Data:      w.data,
+				})
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Type != "memcached" || g.Method != want.Method || g.Path != want.Path || g.Keys != want.Keys || g.Rows != want.Rows ||
+					g.HitRatio != want.HitRatio || g.Error != want.Error || g.ErrorCode != want.ErrorCode {
+					t.Errorf("entry %d: got %+v, want %+v", i, g, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleMemcachedPartialResponses(t *testing.T) {
+	value := strings.Repeat("x", 10) + "\r\nEND\r\nNOT_STORED\r\n" + strings.Repeat("x", 10)
+	text := "VALUE a 0 " + strconv.Itoa(len(value)) + "\r\n" + value + "\r\nEND\r\nSTORED\r\n"
+	binaryGet := memcachedBinary(0x80, 0x00, 0, "", "a", "")
+	binarySet := memcachedBinary(0x80, 0x01, 0, "\x00\x00\x00\x00\x00\x00\x00\x00", "b", "v")
+	binaryReplies := append(
+		memcachedBinary(0x81, 0x00, 0, "\x00\x00\x00\x00", "", strings.Repeat("\x81", 100)),
+		memcachedBinary(0x81, 0x01, 0x02, "", "", "")...)
+
+	tests := []struct {
+		name   string
+		events []collector.Event
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "text value split across reads",
+			events: []collector.Event{
+				{Direction: collector.DirectionRequest, Data: []byte("get a\r\nset b 0 0 1\r\nx\r\n")},
+				{Direction: collector.DirectionResponse, Data: []byte(text[:30])},
+				{Direction: collector.DirectionResponse, Data: []byte(text[30:])},
+			},
+			want: []telemetry.LogEntry{{Method: "get", Path: "a", Keys: 1, Rows: 1, HitRatio: 1}, {Method: "set", Path: "b", Keys: 1}},
+		},
+		{
+			name: "binary body split across reads",
+			events: []collector.Event{
+				{Direction: collector.DirectionRequest, Data: append(binaryGet, binarySet...)},
+				{Direction: collector.DirectionResponse, Data: binaryReplies[:50]},
+				{Direction: collector.DirectionResponse, Data: binaryReplies[50:]},
+			},
+			want: []telemetry.LogEntry{{Method: "get", Path: "a", Keys: 1, Rows: 1, HitRatio: 1}, {Method: "set", Path: "b", Keys: 1, ErrorCode: "EXISTS"}},
+		},
+		{
+			name: "responses lost with the capture",
+			events: []collector.Event{
+				{Direction: collector.DirectionRequest, Data: []byte("get a\r\nget b\r\n")},
+				{Direction: collector.DirectionResponse, Data: []byte("VALUE a 0 1\r\nx\r\nEND\r\n"), Length: uint32(len("VALUE a 0 1\r\nx\r\nEND\r\nEND\r\n"))},
+				{Direction: collector.DirectionRequest, Data: []byte("delete c\r\n")},
+				{Direction: collector.DirectionResponse, Data: []byte("NOT_FOUND\r\n")},
+			},
+			want: []telemetry.LogEntry{{Method: "get", Path: "a", Keys: 1, Rows: 1, HitRatio: 1}, {Method: "delete", Path: "c", Keys: 1, ErrorCode: "NOT_FOUND"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{MemcachedKeys: respparse.KeyPlain})
+			start := time.Unix(100, 0)
+			for i, ev := range tt.events {
+				ev.Timestamp = start.Add(time.Duration(i) * time.Millisecond)
+				ev.Pid = 1
+				ev.Fd = 3
+				ev.Protocol = collector.ProtocolMemcached
+				p.HandleEvent(ev)
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Method != want.Method || g.Path != want.Path || g.Keys != want.Keys || g.Rows != want.Rows ||
+					g.HitRatio != want.HitRatio || g.Error != want.Error || g.ErrorCode != want.ErrorCode {
+					t.Errorf("entry %d: got %+v, want %+v", i, g, want)
+				}
+			}
+		})
+	}
+}
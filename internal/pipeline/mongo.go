@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/correlation"
+	"github.com/emresahna/heimdall/internal/mongoparse"
+)
+
+// handleMongo pairs commands with replies through requestID and responseTo,
+// which every message carries, so no connection state is kept.
+func (p *Processor) handleMongo(ev collector.Event) {
+	for _, msg := range mongoparse.Messages(ev.Data) {
+		if ev.Direction == collector.DirectionRequest {
+			cmd, ok := mongoparse.ParseCommand(msg)
+			if !ok || cmd.NoReply {
+				continue
+			}
+			if p.diagnostics != nil {
+				p.diagnostics.IncParsedRequests()
+			}
+			p.add(correlation.Request{
+				Key:      requestKey(ev, uint64(uint32(cmd.RequestID))),
+				Tid:      ev.Tid,
+				CgroupID: ev.CgroupID,
+				Role:     ev.Role().String(),
+				Type:     "mongodb",
+				Method:   cmd.Name,
+				Path:     mongoNamespace(cmd),
+				Local:    ev.Local,
+				Remote:   ev.Remote,
+				Started:  ev.Timestamp,
+			})
+			continue
+		}
+
+		reply, ok := mongoparse.ParseReply(msg)
+		if !ok {
+			continue
+		}
+		if p.diagnostics != nil {
+			p.diagnostics.IncParsedResponses()
+		}
+		req, ok := p.match(requestKey(ev, uint64(uint32(reply.ResponseTo))))
+		if !ok {
+			continue
+		}
+		p.complete(req, ev.Timestamp, outcome{
+			err:       reply.Failed(),
+			errorCode: reply.ErrorCode(),
+			rows:      reply.N,
+		})
+	}
+}
+
+func mongoNamespace(cmd mongoparse.Command) string {
+	switch {
+	case cmd.Database == "":
+		return cmd.Collection
+	case cmd.Collection == "":
+		return cmd.Database
+	}
+	return cmd.Database + "." + cmd.Collection
+}
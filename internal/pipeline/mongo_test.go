@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/telemetry"
+)
+
+func bsonDoc(fields ...[]byte) []byte {
+	var body []byte
+	for _, f := range fields {
+		body = append(body, f...)
+	}
+	body = append(body, 0)
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(body)+4)), body...)
+}
+
+func bsonString(name, value string) []byte {
+	b := append([]byte{0x02}, name...)
+	b = binary.LittleEndian.AppendUint32(append(b, 0), uint32(len(value)+1))
+	return append(append(b, value...), 0)
+}
+
+func bsonDouble(name string, value float64) []byte {
+	b := append([]byte{0x01}, name...)
+	return binary.LittleEndian.AppendUint64(append(b, 0), math.Float64bits(value))
+}
+
+func bsonInt32(name string, value int32) []byte {
+	b := append([]byte{0x10}, name...)
+	return binary.LittleEndian.AppendUint32(append(b, 0), uint32(value))
+}
+
+func mongoMsg(requestID, responseTo int32, flags uint32, body []byte) []byte {
+	payload := binary.LittleEndian.AppendUint32(nil, flags)
+	payload = append(append(payload, 0), body...)
+	msg := binary.LittleEndian.AppendUint32(nil, uint32(16+len(payload)))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(requestID))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(responseTo))
+	msg = binary.LittleEndian.AppendUint32(msg, 2013)
+	return append(msg, payload...)
+}
+
+func TestHandleMongo(t *testing.T) {
+	ok := bsonDoc(bsonDouble("ok", 1))
+	type write struct {
+		dir  collector.Direction
+		data []byte
+	}
+	tests := []struct {
+		name   string
+		writes []write
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "find",
+			writes: []write{
+				{collector.DirectionRequest, mongoMsg(7, 0, 0, bsonDoc(bsonString("find", "users"), bsonString("$db", "app")))},
+				{collector.DirectionResponse, mongoMsg(100, 7, 0, ok)},
+			},
+			want: []telemetry.LogEntry{{Method: "find", Path: "app.users"}},
+		},
+		{
+			name: "insert count",
+			writes: []write{
+				{collector.DirectionRequest, mongoMsg(7, 0, 0, bsonDoc(bsonString("insert", "events"), bsonString("$db", "app")))},
+				{collector.DirectionResponse, mongoMsg(100, 7, 0, bsonDoc(bsonInt32("n", 3), bsonDouble("ok", 1)))},
+			},
+			want: []telemetry.LogEntry{{Method: "insert", Path: "app.events", Rows: 3}},
+		},
+		{
+			name: "command error",
+			writes: []write{
+				{collector.DirectionRequest, mongoMsg(7, 0, 0, bsonDoc(bsonString("drop", "gone"), bsonString("$db", "app")))},
+				{collector.DirectionResponse, mongoMsg(100, 7, 0, bsonDoc(bsonDouble("ok", 0), bsonInt32("code", 26)))},
+			},
+			want: []telemetry.LogEntry{{Method: "drop", Path: "app.gone", Error: true, ErrorCode: "26"}},
+		},
+		{
+			name: "unacknowledged write",
+			writes: []write{
+				{collector.DirectionRequest, mongoMsg(7, 0, 2, bsonDoc(bsonString("insert", "events"), bsonString("$db", "app")))},
+			},
+		},
+		{
+			name: "replies out of order",
+			writes: []write{
+				{collector.DirectionRequest, append(
+					mongoMsg(1, 0, 0, bsonDoc(bsonString("find", "a"), bsonString("$db", "app"))),
+					mongoMsg(2, 0, 0, bsonDoc(bsonString("find", "b"), bsonString("$db", "app")))...,
+				)},
+				{collector.DirectionResponse, append(mongoMsg(100, 2, 0, ok), mongoMsg(101, 1, 0, ok)...)},
+			},
+			want: []telemetry.LogEntry{{Method: "find", Path: "app.b"}, {Method: "find", Path: "app.a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{})
+			start := time.Unix(100, 0)
+			for i, w := range tt.writes {
+				p.HandleEvent(collector.Event{
+					Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+					Pid:       1,
+					Fd:        3,
+					Direction: w.dir,
+					Protocol:  collector.ProtocolMongo,
+					Data:      w.data,
+				})
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Type != "mongodb" || g.Method != want.Method || g.Path != want.Path || g.Rows != want.Rows || g.Error != want.Error || g.ErrorCode != want.ErrorCode {
+					t.Errorf("entry %d: got %s %s %s rows=%d error=%v %q, want %s %s rows=%d error=%v %q", i, g.Type, g.Method, g.Path, g.Rows, g.Error, g.ErrorCode, want.Method, want.Path, want.Rows, want.Error, want.ErrorCode)
+				}
+			}
+		})
+	}
+}
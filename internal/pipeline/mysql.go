@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/correlation"
+	"github.com/emresahna/heimdall/internal/mysqlparse"
+	"github.com/emresahna/heimdall/internal/sqlnorm"
+)
+
+const maxMySQLStatements = 256
+
+// mysqlConn tracks one connection; MySQL answers one command at a time.
+// Sequence numbers wrap at 256, so a chunk deep in a large result set can
+// look like the start of an answer; awaiting limits parsing to the first
+// answer after a command.
+type mysqlConn struct {
+	statements map[uint32]string
+	preparing  string
+	prepare    bool
+	awaiting   bool
+}
+
+func (p *Processor) handleMySQL(ev collector.Event) {
+	state := p.conns.get(ev.Pid, ev.Fd, ev.Socket)
+	if state.mysql == nil {
+		state.mysql = &mysqlConn{statements: make(map[uint32]string)}
+	}
+	conn := state.mysql
+
+	if ev.Direction == collector.DirectionRequest {
+		cmd, ok := mysqlparse.ParseCommand(ev.Data)
+		if !ok {
+			return
+		}
+		conn.awaiting = false
+		var query string
+		switch cmd.Kind {
+		case mysqlparse.ComQuery:
+			query = sqlnorm.Normalize(cmd.Query, sqlnorm.MySQL)
+		case mysqlparse.ComStmtPrepare:
+			conn.preparing = sqlnorm.Normalize(cmd.Query, sqlnorm.MySQL)
+			conn.prepare = true
+			conn.awaiting = true
+			return
+		case mysqlparse.ComStmtExecute:
+			query = conn.statements[cmd.StmtID]
+		case mysqlparse.ComStmtClose:
+			delete(conn.statements, cmd.StmtID)
+			return
+		default:
+			return
+		}
+		if p.diagnostics != nil {
+			p.diagnostics.IncParsedRequests()
+		}
+		conn.awaiting = true
+
+		method := sqlnorm.Command(query)
+		if method == "" && cmd.Kind == mysqlparse.ComStmtExecute {
+			method = "EXECUTE"
+		}
+		p.add(correlation.Request{
+			Key:      requestKey(ev, 0),
+			Tid:      ev.Tid,
+			CgroupID: ev.CgroupID,
+			Role:     ev.Role().String(),
+			Type:     "mysql",
+			Method:   method,
+			Path:     query,
+			Local:    ev.Local,
+			Remote:   ev.Remote,
+			Started:  ev.Timestamp,
+		})
+		return
+	}
+
+	if !conn.awaiting {
+		return
+	}
+	resp, ok := mysqlparse.ParseResponse(ev.Data, conn.prepare)
+	if !ok {
+		return
+	}
+	conn.awaiting = false
+	if conn.prepare {
+		conn.prepare = false
+		if !resp.Error {
+			if len(conn.statements) >= maxMySQLStatements {
+				conn.statements = make(map[uint32]string)
+			}
+			conn.statements[resp.StmtID] = conn.preparing
+		}
+		conn.preparing = ""
+		return
+	}
+	if p.diagnostics != nil {
+		p.diagnostics.IncParsedResponses()
+	}
+
+	req, ok := p.match(requestKey(ev, 0))
+	if !ok {
+		return
+	}
+	out := outcome{err: resp.Error, errorCode: resp.ErrorCode()}
+	if resp.RowsKnown {
+		out.rows = resp.Rows
+	}
+	p.complete(req, ev.Timestamp, out)
+}
@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/telemetry"
+)
+
+func mysqlPacket(seq byte, payload ...byte) []byte {
+	return append([]byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}, payload...)
+}
+
+func mysqlPackets(packets ...[]byte) []byte {
+	var b []byte
+	for _, p := range packets {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestHandleMySQL(t *testing.T) {
+	type write struct {
+		dir  collector.Direction
+		data []byte
+	}
+	tests := []struct {
+		name   string
+		writes []write
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "ok packet",
+			writes: []write{
+				{collector.DirectionRequest, mysqlPacket(0, append([]byte{0x03}, "INSERT INTO t VALUES (1), (2)"...)...)},
+				{collector.DirectionResponse, mysqlPacket(1, 0x00, 2, 0, 2, 0, 0, 0)},
+			},
+			want: []telemetry.LogEntry{{Method: "INSERT", Rows: 2}},
+		},
+		{
+			name: "result set",
+			writes: []write{
+				{collector.DirectionRequest, mysqlPacket(0, append([]byte{0x03}, "SELECT a FROM t"...)...)},
+				{collector.DirectionResponse, mysqlPackets(
+					mysqlPacket(1, 1),
+					mysqlPacket(2, append([]byte{3}, "def"...)...),
+					mysqlPacket(3, 0xfe, 0, 0, 2, 0),
+					mysqlPacket(4, 1, 'x'),
+					mysqlPacket(5, 1, 'y'),
+					mysqlPacket(6, 0xfe, 0, 0, 2, 0),
+				)},
+			},
+			want: []telemetry.LogEntry{{Method: "SELECT", Rows: 2}},
+		},
+		{
+			name: "error",
+			writes: []write{
+				{collector.DirectionRequest, mysqlPacket(0, append([]byte{0x03}, "SELEC 1"...)...)},
+				{collector.DirectionResponse, mysqlPacket(1, append([]byte{0xff, 0x28, 0x04, '#'}, "42000syntax"...)...)},
+			},
+			want: []telemetry.LogEntry{{Method: "SELEC", Error: true, ErrorCode: "1064"}},
+		},
+		{
+			name: "prepared statement",
+			writes: []write{
+				{collector.DirectionRequest, mysqlPacket(0, append([]byte{0x16}, "UPDATE t SET a = ?"...)...)},
+				{collector.DirectionResponse, mysqlPacket(1, 0x00, 7, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0)},
+				{collector.DirectionRequest, mysqlPacket(0, 0x17, 7, 0, 0, 0, 0, 1, 0, 0, 0)},
+				{collector.DirectionResponse, mysqlPacket(1, 0x00, 1, 0, 2, 0, 0, 0)},
+			},
+			want: []telemetry.LogEntry{{Method: "UPDATE", Rows: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{})
+			start := time.Unix(100, 0)
+			for i, w := range tt.writes {
+				p.HandleEvent(collector.Event{
+					Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+					Pid:       1,
+					Fd:        3,
+					Direction: w.dir,
+					Protocol:  collector.ProtocolMySQL,
+					Data:      w.data,
+				})
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Type != "mysql" || g.Method != want.Method || g.Rows != want.Rows || g.Error != want.Error || g.ErrorCode != want.ErrorCode {
+					t.Errorf("entry %d: got %s %s rows=%d error=%v %q, want %s rows=%d error=%v %q", i, g.Type, g.Method, g.Rows, g.Error, g.ErrorCode, want.Method, want.Rows, want.Error, want.ErrorCode)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/correlation"
+	"github.com/emresahna/heimdall/internal/pgparse"
+	"github.com/emresahna/heimdall/internal/sqlnorm"
+)
+
+// postgresConn follows both directions of a connection. Every simple Query
+// and every Sync is answered by one ReadyForQuery, in order.
+type postgresConn struct {
+	frontend *pgparse.Frontend
+	backend  pgparse.Backend
+	seq      sequence
+}
+
+func (p *Processor) handlePostgres(ev collector.Event) {
+	state := p.conns.get(ev.Pid, ev.Fd, ev.Socket)
+	if state.postgres == nil {
+		state.postgres = &postgresConn{frontend: pgparse.NewFrontend()}
+	}
+	conn := state.postgres
+
+	if ev.Direction == collector.DirectionRequest {
+		for _, req := range conn.frontend.Parse(ev.Data) {
+			if p.diagnostics != nil {
+				p.diagnostics.IncParsedRequests()
+			}
+			p.add(correlation.Request{
+				Key:      requestKey(ev, conn.seq.next()),
+				Tid:      ev.Tid,
+				CgroupID: ev.CgroupID,
+				Role:     ev.Role().String(),
+				Type:     "postgres",
+				Method:   sqlnorm.Command(req.Query),
+				Path:     req.Query,
+				Local:    ev.Local,
+				Remote:   ev.Remote,
+				Started:  ev.Timestamp,
+			})
+		}
+		return
+	}
+
+	responses, ok := conn.backend.Parse(ev.Data, int(ev.Length))
+	// With one query outstanding, missing bytes are the middle of its result
+	// set, and the capture keeps the end that holds its ReadyForQuery.
+	if !ok && conn.seq.sent-conn.seq.answered > 1 {
+		p.resync(ev, &conn.seq)
+	}
+	if conn.seq.lost {
+		return
+	}
+	for _, resp := range responses {
+		if p.diagnostics != nil {
+			p.diagnostics.IncParsedResponses()
+		}
+		stream, ok := conn.seq.answer()
+		if !ok {
+			if p.diagnostics != nil {
+				p.diagnostics.IncUnmatchedResponses()
+			}
+			continue
+		}
+		req, ok := p.match(requestKey(ev, stream))
+		if !ok {
+			continue
+		}
+		command, rows := pgparse.CommandTag(resp.Tag)
+		p.complete(req, ev.Timestamp, outcome{
+			err:       resp.Error,
+			errorCode: resp.Code,
+			command:   command,
+			rows:      rows,
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/telemetry"
+)
+
+func pgMessage(typ byte, body string) []byte {
+	b := []byte{typ}
+	b = binary.BigEndian.AppendUint32(b, uint32(4+len(body)))
+	return append(b, body...)
+}
+
+func pgMessages(msgs ...[]byte) []byte {
+	var b []byte
+	for _, m := range msgs {
+		b = append(b, m...)
+	}
+	return b
+}
+
+func TestHandlePostgres(t *testing.T) {
+	ready := pgMessage('Z', "I")
+	type write struct {
+		dir  collector.Direction
+		data []byte
+	}
+	tests := []struct {
+		name   string
+		writes []write
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "simple query",
+			writes: []write{
+				{collector.DirectionRequest, pgMessage('Q', "SELECT * FROM t\x00")},
+				{collector.DirectionResponse, pgMessages(pgMessage('D', "\x00\x01\x00\x00\x00\x01a"), pgMessage('C', "SELECT 1\x00"), ready)},
+			},
+			want: []telemetry.LogEntry{{Method: "SELECT", Rows: 1}},
+		},
+		{
+			name: "error",
+			writes: []write{
+				{collector.DirectionRequest, pgMessage('Q', "SELEC 1\x00")},
+				{collector.DirectionResponse, pgMessages(pgMessage('E', "SERROR\x00C42601\x00Msyntax error\x00\x00"), ready)},
+			},
+			want: []telemetry.LogEntry{{Method: "SELEC", Error: true, ErrorCode: "42601"}},
+		},
+		{
+			name: "extended protocol",
+			writes: []write{
+				{collector.DirectionRequest, pgMessages(
+					pgMessage('P', "s1\x00INSERT INTO t VALUES ($1)\x00\x00\x00"),
+					pgMessage('B', "\x00s1\x00\x00\x00\x00\x00\x00\x00"),
+					pgMessage('E', "\x00\x00\x00\x00\x00"),
+					pgMessage('S', ""),
+				)},
+				{collector.DirectionResponse, pgMessages(pgMessage('1', ""), pgMessage('2', ""), pgMessage('C', "INSERT 0 3\x00"), ready)},
+			},
+			want: []telemetry.LogEntry{{Method: "INSERT", Rows: 3}},
+		},
+		{
+			name: "pipelined queries",
+			writes: []write{
+				{collector.DirectionRequest, pgMessages(pgMessage('Q', "BEGIN\x00"), pgMessage('Q', "DELETE FROM t\x00"))},
+				{collector.DirectionResponse, pgMessages(pgMessage('C', "BEGIN\x00"), pgMessage('Z', "T"), pgMessage('C', "DELETE 2\x00"), pgMessage('Z', "T"))},
+			},
+			want: []telemetry.LogEntry{{Method: "BEGIN"}, {Method: "DELETE", Rows: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{})
+			start := time.Unix(100, 0)
+			for i, w := range tt.writes {
+				p.HandleEvent(collector.Event{
+					Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+					Pid:       1,
+					Fd:        3,
+					Direction: w.dir,
+					Protocol:  collector.ProtocolPostgres,
+					Data:      w.data,
+				})
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Type != "postgres" || g.Method != want.Method || g.Rows != want.Rows || g.Error != want.Error || g.ErrorCode != want.ErrorCode {
+					t.Errorf("entry %d: got %s %s rows=%d error=%v %q, want %s rows=%d error=%v %q", i, g.Type, g.Method, g.Rows, g.Error, g.ErrorCode, want.Method, want.Rows, want.Error, want.ErrorCode)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlePostgresPartialResponses(t *testing.T) {
+	ready := pgMessage('Z', "I")
+	row := pgMessage('D', "\x00\x01\x00\x00\x00\x03abc")
+	rows := func(n int) []byte {
+		var b []byte
+		for range n {
+			b = append(b, row...)
+		}
+		return b
+	}
+	pipelined := pgMessages(pgMessage('Q', "SELECT a FROM t\x00"), pgMessage('Q', "SELECT b FROM t\x00"))
+	split := pgMessages(row, pgMessage('C', "SELECT 1\x00"), ready, rows(2), pgMessage('C', "SELECT 2\x00"), ready)
+	large := pgMessages(rows(1000), pgMessage('C', "SELECT 1000\x00"), ready)
+	results := pgMessages(rows(1000), pgMessage('C', "SELECT 1000\x00"), ready, pgMessage('C', "SELECT 0\x00"), ready)
+	end := func(b []byte, n int) []byte { return b[len(b)-n:] }
+
+	tests := []struct {
+		name   string
+		events []collector.Event
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "message split across reads",
+			events: []collector.Event{
+				{Direction: collector.DirectionRequest, Data: pipelined},
+				{Direction: collector.DirectionResponse, Data: split[:len(row)+3]},
+				{Direction: collector.DirectionResponse, Data: split[len(row)+3:]},
+			},
+			want: []telemetry.LogEntry{{Method: "SELECT", Rows: 1}, {Method: "SELECT", Rows: 2}},
+		},
+		{
+			name: "large result of a single query",
+			events: []collector.Event{
+				{Direction: collector.DirectionRequest, Data: pgMessage('Q', "SELECT * FROM t\x00")},
+				{Direction: collector.DirectionResponse, Data: large[5000:9096], Length: 9096},
+				{Direction: collector.DirectionResponse, Data: end(large, 4096), Length: uint32(len(large) - 9096)},
+			},
+			want: []telemetry.LogEntry{{Method: "SELECT", Rows: 1000}},
+		},
+		{
+			name: "pipelined results cut off by the capture",
+			events: []collector.Event{
+				{Direction: collector.DirectionRequest, Data: pipelined},
+				{Direction: collector.DirectionResponse, Data: end(results, 4096), Length: uint32(len(results))},
+				{Direction: collector.DirectionRequest, Data: pgMessage('Q', "DELETE FROM t\x00")},
+				{Direction: collector.DirectionResponse, Data: pgMessages(pgMessage('C', "DELETE 4\x00"), ready)},
+			},
+			want: []telemetry.LogEntry{{Method: "DELETE", Rows: 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{})
+			start := time.Unix(100, 0)
+			for i, ev := range tt.events {
+				ev.Timestamp = start.Add(time.Duration(i) * time.Millisecond)
+				ev.Pid = 1
+				ev.Fd = 3
+				ev.Protocol = collector.ProtocolPostgres
+				p.HandleEvent(ev)
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Method != want.Method || g.Rows != want.Rows {
+					t.Errorf("entry %d: got %s rows=%d, want %s rows=%d", i, g.Method, g.Rows, want.Method, want.Rows)
+				}
+			}
+		})
+	}
+}
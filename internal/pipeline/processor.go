@@ -1,18 +1,49 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
+	"net/netip"
+	"sync/atomic"
 	"time"
 
 	"github.com/emresahna/heimdall/internal/collector"
 	"github.com/emresahna/heimdall/internal/correlation"
 	"github.com/emresahna/heimdall/internal/enrichment"
 	"github.com/emresahna/heimdall/internal/httpparse"
+	"github.com/emresahna/heimdall/internal/payload"
+	"github.com/emresahna/heimdall/internal/respparse"
+	"github.com/emresahna/heimdall/internal/route"
 	"github.com/emresahna/heimdall/internal/telemetry"
 )
 
 const maintenanceInterval = 10 * time.Second
 
+// Parser state of idle connections is kept much longer than pending requests:
+// dropping an HTTP/2 HPACK table garbles every later header block on it.
+const connIdleTTL = 10 * time.Minute
+
+// Options tunes how protocol payloads are reported.
+type Options struct {
+	RedisKeys     respparse.KeyMode
+	MemcachedKeys respparse.KeyMode
+	Routes        *route.Normalizer
+	// Headers stored in the attributes of HTTP entries, besides Host,
+	// User-Agent, Content-Type and Content-Length.
+	HTTPHeaders []string
+	// Payloads samples HTTP/1 bodies; nil leaves Payload empty.
+	Payloads *payload.Sampler
+	// Redactor masks secrets in the query of HTTP and gRPC entries; nil
+	// masks only emails, card numbers and bearer tokens.
+	Redactor *payload.Redactor
+	// EmitAborted stores requests whose socket closed before the response
+	// as errors with error_code ABORTED instead of dropping them.
+	EmitAborted bool
+	// EventClock expires pending requests against the newest event timestamp
+	// rather than the wall clock, for replayed input whose times are past.
+	EventClock bool
+}
+
 type Processor struct {
 	ctx         context.Context
 	correlator  *correlation.Correlator
@@ -21,6 +52,10 @@ type Processor struct {
 	node        string
 	sampleMax   int
 	diagnostics *Diagnostics
+	conns       *connTable
+	opts        Options
+	// latest is the newest event timestamp in Unix nanoseconds.
+	latest atomic.Int64
 }
 
 func NewProcessor(
@@ -31,6 +66,7 @@ func NewProcessor(
 	node string,
 	sampleMax int,
 	diagnostics *Diagnostics,
+	opts Options,
 ) *Processor {
 	return &Processor{
 		ctx:         ctx,
@@ -40,6 +76,8 @@ func NewProcessor(
 		node:        node,
 		sampleMax:   sampleMax,
 		diagnostics: diagnostics,
+		conns:       newConnTable(),
+		opts:        opts,
 	}
 }
 
@@ -47,70 +85,236 @@ func (p *Processor) HandleEvent(ev collector.Event) {
 	if p.diagnostics != nil {
 		p.diagnostics.IncEventsRead()
 	}
-	if p.sampleMax > 0 && len(ev.Data) > p.sampleMax {
-		ev.Data = ev.Data[:p.sampleMax]
+	if p.opts.EventClock && ev.Timestamp.UnixNano() > p.latest.Load() {
+		p.latest.Store(ev.Timestamp.UnixNano())
+	}
+	if ev.Direction == collector.DirectionClose {
+		p.handleClose(ev)
+		return
 	}
+	switch ev.Protocol {
+	case collector.ProtocolHTTP2:
+		p.handleHTTP2(ev)
+	case collector.ProtocolRedis:
+		p.handleRedis(ev)
+	case collector.ProtocolPostgres:
+		p.handlePostgres(ev)
+	case collector.ProtocolMySQL:
+		p.handleMySQL(ev)
+	case collector.ProtocolKafka:
+		p.handleKafka(ev)
+	case collector.ProtocolDNS:
+		p.handleDNS(ev)
+	case collector.ProtocolMongo:
+		p.handleMongo(ev)
+	case collector.ProtocolMemcached:
+		p.handleMemcached(ev)
+	default:
+		p.handleHTTP(ev)
+	}
+}
+
+func (p *Processor) handleHTTP(ev collector.Event) {
 	switch ev.Direction {
 	case collector.DirectionRequest:
-		method, path, ok := httpparse.ParseRequestLine(ev.Data)
-		if !ok {
-			return
-		}
-		if p.diagnostics != nil {
-			p.diagnostics.IncParsedRequests()
+		for _, parsed := range httpparse.ParseRequests(ev.Data) {
+			if p.diagnostics != nil {
+				p.diagnostics.IncParsedRequests()
+			}
+			req := correlation.Request{
+				Key:      requestKey(ev, 0),
+				Tid:      ev.Tid,
+				CgroupID: ev.CgroupID,
+				Role:     ev.Role().String(),
+				Type:     "http",
+				Method:   parsed.Method,
+				Path:     parsed.Path,
+				Local:    ev.Local,
+				Remote:   ev.Remote,
+				Started:  ev.Timestamp,
+			}
+			p.setRequestHeaders(&req, parsed.Header.Get)
+			if p.opts.Payloads != nil {
+				req.Body = bytes.Clone(p.capSample(payload.Body(parsed.Raw, parsed.Header.Get("content-encoding"))))
+				req.BodyType = parsed.Header.Get("content-type")
+			}
+			p.add(req)
 		}
-		p.correlator.Add(correlation.Request{
-			Key: correlation.RequestKey{
-				Pid: ev.Pid,
-				Fd:  ev.Fd,
-			},
-			Tid:      ev.Tid,
-			CgroupID: ev.CgroupID,
-			Method:   method,
-			Path:     path,
-			Started:  ev.Timestamp,
-		})
 	case collector.DirectionResponse:
-		status, ok := httpparse.ParseResponseLine(ev.Data)
-		if !ok {
-			return
+		var methods []string
+		for _, req := range p.correlator.Pending(requestKey(ev, 0)) {
+			methods = append(methods, req.Method)
 		}
-		if p.diagnostics != nil {
-			p.diagnostics.IncParsedResponses()
-		}
-		req, ok := p.correlator.Match(ev.Pid, ev.Fd)
-		if !ok {
+		for _, resp := range httpparse.ParseResponses(ev.Data, methods) {
 			if p.diagnostics != nil {
-				p.diagnostics.IncUnmatchedResponses()
+				p.diagnostics.IncParsedResponses()
 			}
-			return
+			// Interim responses such as 100 Continue precede the final one,
+			// which the request must still be waiting for.
+			if resp.Status >= 100 && resp.Status < 200 && resp.Status != 101 {
+				continue
+			}
+			req, ok := p.match(requestKey(ev, 0))
+			if !ok {
+				continue
+			}
+			out := outcome{status: resp.Status}
+			p.setResponseHeaders(&out, resp.Header.Get)
+			if p.opts.Payloads != nil {
+				out.body = p.capSample(payload.Body(resp.Raw, resp.Header.Get("content-encoding")))
+			}
+			p.complete(req, ev.Timestamp, out)
 		}
-		if p.diagnostics != nil {
+	}
+}
+
+// capSample cuts a body to the sample size, which replayed and pcap events
+// are not held to by the kernel.
+func (p *Processor) capSample(body []byte) []byte {
+	if p.sampleMax > 0 && len(body) > p.sampleMax {
+		return body[:p.sampleMax]
+	}
+	return body
+}
+
+func requestKey(ev collector.Event, stream uint64) correlation.RequestKey {
+	return correlation.RequestKey{Pid: ev.Pid, Fd: ev.Fd, Socket: ev.Socket, Stream: stream}
+}
+
+// handleClose settles the requests still waiting on a socket that was
+// closed, since no response can arrive for them any more.
+func (p *Processor) handleClose(ev collector.Event) {
+	p.conns.remove(ev.Pid, ev.Fd)
+	pending := p.correlator.Close(correlation.ConnKey{Pid: ev.Pid, Fd: ev.Fd, Socket: ev.Socket})
+	if len(pending) == 0 {
+		return
+	}
+	if p.diagnostics != nil {
+		p.diagnostics.AddAbortedRequests(uint64(len(pending)))
+	}
+	if !p.opts.EmitAborted {
+		return
+	}
+	for _, req := range pending {
+		p.complete(req, ev.Timestamp, outcome{err: true, errorCode: "ABORTED"})
+	}
+}
+
+// add hands req to the correlator and counts a request it displaced.
+func (p *Processor) add(req correlation.Request) {
+	if p.correlator.Add(req) && p.diagnostics != nil {
+		p.diagnostics.IncDroppedRequests()
+	}
+}
+
+// match finds the request a response belongs to and counts the attempt.
+func (p *Processor) match(key correlation.RequestKey) (correlation.Request, bool) {
+	req, ok := p.correlator.MatchKey(key)
+	if p.diagnostics != nil {
+		if ok {
 			p.diagnostics.IncMatchedResponses()
+		} else {
+			p.diagnostics.IncUnmatchedResponses()
 		}
+	}
+	return req, ok
+}
 
-		duration := ev.Timestamp.Sub(req.Started)
-		if duration < 0 {
-			duration = 0
+// resync gives up on the requests waiting on seq once some of their
+// responses were not captured, since the responses that follow could no
+// longer be told apart.
+func (p *Processor) resync(ev collector.Event, seq *sequence) {
+	for seq.answered < seq.sent {
+		seq.answered++
+		if _, ok := p.correlator.MatchKey(requestKey(ev, seq.answered)); ok && p.diagnostics != nil {
+			p.diagnostics.IncDroppedRequests()
 		}
+	}
+	seq.lost = true
+}
 
-		entry := telemetry.LogEntry{
-			Timestamp:  req.Started,
-			Pid:        req.Key.Pid,
-			Tid:        req.Tid,
-			Fd:         req.Key.Fd,
-			CgroupID:   req.CgroupID,
-			Type:       "http",
-			Status:     status,
-			Method:     req.Method,
-			Path:       req.Path,
-			DurationNs: uint64(duration.Nanoseconds()),
-			Node:       p.node,
-		}
+// outcome is what the response to a request reported.
+// A command, when set, replaces the method guessed from the request.
+type outcome struct {
+	status    uint32
+	err       bool
+	errorCode string
+	command   string
+	rows      uint64
+	keys      uint32
+	hitRatio  float64
+
+	contentType   string
+	contentLength uint64
+	attributes    map[string]string
+	body          []byte
+}
+
+// complete turns a matched request into a log entry and queues it.
+func (p *Processor) complete(req correlation.Request, end time.Time, out outcome) {
+	duration := end.Sub(req.Started)
+	if duration < 0 {
+		duration = 0
+	}
+
+	entry := telemetry.LogEntry{
+		Timestamp:     req.Started,
+		Pid:           req.Key.Pid,
+		Tid:           req.Tid,
+		Fd:            req.Key.Fd,
+		CgroupID:      req.CgroupID,
+		Type:          req.Type,
+		Role:          req.Role,
+		Status:        out.status,
+		Method:        req.Method,
+		Path:          req.Path,
+		DurationNs:    uint64(duration.Nanoseconds()),
+		Node:          p.node,
+		Error:         out.err,
+		ErrorCode:     out.errorCode,
+		Rows:          out.rows,
+		Keys:          out.keys,
+		HitRatio:      out.hitRatio,
+		TraceID:       req.TraceID,
+		SpanID:        req.SpanID,
+		TraceSampled:  req.TraceSampled,
+		Host:          req.Host,
+		UserAgent:     req.UserAgent,
+		ContentType:   out.contentType,
+		ContentLength: out.contentLength,
+		Attributes:    mergeAttributes(req.Attributes, out.attributes),
+	}
+	if out.command != "" {
+		entry.Method = out.command
+	}
+	setSocketFields(&entry, req.Local, req.Remote)
 
-		p.enricher.Enrich(p.ctx, entry.Pid, entry.CgroupID, &entry)
-		p.batcher.Enqueue(entry)
+	p.enricher.Enrich(p.ctx, entry.Pid, entry.CgroupID, &entry)
+	if entry.Type == "http" || entry.Type == "grpc" {
+		entry.Path, entry.Query = route.Split(entry.Path)
+		entry.Query = p.opts.Redactor.RedactQuery(entry.Query)
+		entry.Route = p.opts.Routes.Template(entry.Path, entry.Container, entry.Namespace)
 	}
+	if p.opts.Payloads.Selects(entry.Namespace, entry.Route) {
+		entry.Payload = p.opts.Payloads.Sample(req.Body, req.BodyType)
+		entry.ResponsePayload = p.opts.Payloads.Sample(out.body, out.contentType)
+	}
+	p.batcher.Enqueue(entry)
+}
+
+func setSocketFields(entry *telemetry.LogEntry, local, remote netip.AddrPort) {
+	if !local.IsValid() {
+		return
+	}
+	if local.Addr().Is4() {
+		entry.Family = "ipv4"
+	} else {
+		entry.Family = "ipv6"
+	}
+	entry.LocalIP = local.Addr().String()
+	entry.LocalPort = local.Port()
+	entry.RemoteIP = remote.Addr().String()
+	entry.RemotePort = remote.Port()
 }
 
 func (p *Processor) RunMaintenance(ctx context.Context, interval time.Duration) {
@@ -126,7 +330,23 @@ func (p *Processor) RunMaintenance(ctx context.Context, interval time.Duration)
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			p.correlator.Expire(time.Now())
+			p.expire(time.Now())
 		}
 	}
 }
+
+// expire drops idle connection state and orphans requests that waited past
+// the correlator TTL.
+func (p *Processor) expire(now time.Time) {
+	p.conns.expire(now, connIdleTTL)
+	if p.opts.EventClock {
+		latest := p.latest.Load()
+		if latest == 0 {
+			return
+		}
+		now = time.Unix(0, latest)
+	}
+	if expired := p.correlator.Expire(now); expired > 0 && p.diagnostics != nil {
+		p.diagnostics.AddOrphanedRequests(uint64(expired))
+	}
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/correlation"
+	"github.com/emresahna/heimdall/internal/enrichment"
+	"github.com/emresahna/heimdall/internal/telemetry"
+)
+
+func newTestProcessor(opts Options) *Processor {
+	batcher := NewBatcher(0, 0, 0, nil, nil)
+	return NewProcessor(context.Background(), correlation.NewCorrelator(time.Minute), enrichment.NoopEnricher{}, batcher, "node", 0, NewDiagnostics(), opts)
+}
+
+// entries drains what the processor has queued for sending.
+func entries(p *Processor) []telemetry.LogEntry {
+	var out []telemetry.LogEntry
+	for {
+		select {
+		case entry := <-p.batcher.in:
+			out = append(out, entry)
+		default:
+			return out
+		}
+	}
+}
+
+func TestHTTPPipelinedRequests(t *testing.T) {
+	p := newTestProcessor(Options{})
+	start := time.Unix(100, 0)
+	ev := collector.Event{Timestamp: start, Pid: 1, Fd: 3, Socket: 7, Direction: collector.DirectionRequest}
+
+	ev.Data = []byte("GET /a HTTP/1.1\r\nHost: x\r\n\r\nGET /b HTTP/1.1\r\nHost: x\r\n\r\n")
+	p.HandleEvent(ev)
+
+	ev.Direction = collector.DirectionResponse
+	ev.Timestamp = start.Add(time.Millisecond)
+	ev.Data = []byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\nHTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n")
+	p.HandleEvent(ev)
+
+	got := entries(p)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(got))
+	}
+	if got[0].Path != "/a" || got[0].Status != 200 || got[1].Path != "/b" || got[1].Status != 404 {
+		t.Fatalf("unexpected entries %+v", got)
+	}
+}
+
+func TestHTTPParsesBeyondSampleSize(t *testing.T) {
+	p := newTestProcessor(Options{})
+	p.sampleMax = 16
+	start := time.Unix(100, 0)
+	ev := collector.Event{Timestamp: start, Pid: 1, Fd: 3, Socket: 7, Direction: collector.DirectionRequest}
+
+	ev.Data = []byte("GET /a HTTP/1.1\r\nHost: x\r\n\r\nGET /b HTTP/1.1\r\nHost: x\r\n\r\n")
+	p.HandleEvent(ev)
+
+	ev.Direction = collector.DirectionResponse
+	ev.Timestamp = start.Add(time.Millisecond)
+	ev.Data = []byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\nHTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n")
+	p.HandleEvent(ev)
+
+	got := entries(p)
+	if len(got) != 2 || got[0].Status != 200 || got[1].Path != "/b" || got[1].Status != 404 {
+		t.Fatalf("expected both exchanges past the sample size, got %+v", got)
+	}
+}
+
+func TestHTTPHeadResponses(t *testing.T) {
+	p := newTestProcessor(Options{})
+	start := time.Unix(100, 0)
+	ev := collector.Event{Timestamp: start, Pid: 1, Fd: 3, Socket: 7, Direction: collector.DirectionRequest}
+
+	ev.Data = []byte("HEAD /a HTTP/1.1\r\nHost: x\r\n\r\nGET /b HTTP/1.1\r\nHost: x\r\n\r\n")
+	p.HandleEvent(ev)
+
+	ev.Direction = collector.DirectionResponse
+	ev.Timestamp = start.Add(time.Millisecond)
+	ev.Data = []byte("HTTP/1.1 200 OK\r\nContent-Length: 512\r\n\r\nHTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n")
+	p.HandleEvent(ev)
+
+	got := entries(p)
+	if len(got) != 2 || got[0].Method != "HEAD" || got[0].Status != 200 || got[1].Path != "/b" || got[1].Status != 404 {
+		t.Fatalf("expected the response after a HEAD response to be matched, got %+v", got)
+	}
+}
+
+func TestEventClockExpiry(t *testing.T) {
+	p := newTestProcessor(Options{EventClock: true})
+	start := time.Unix(100, 0)
+	ev := collector.Event{Timestamp: start, Pid: 1, Fd: 3, Direction: collector.DirectionRequest}
+	ev.Data = []byte("GET /a HTTP/1.1\r\n\r\n")
+	p.HandleEvent(ev)
+
+	p.expire(time.Now())
+	if p.diagnostics.Snapshot().OrphanedRequests != 0 {
+		t.Fatalf("expected a replayed request to survive wall-clock expiry")
+	}
+
+	ev.Timestamp = start.Add(2 * time.Minute)
+	ev.Data = []byte("GET /b HTTP/1.1\r\n\r\n")
+	ev.Fd = 4
+	p.HandleEvent(ev)
+	p.expire(time.Now())
+	if got := p.diagnostics.Snapshot().OrphanedRequests; got != 1 {
+		t.Fatalf("expected 1 orphaned request, got %d", got)
+	}
+}
+
+func TestMySQLIgnoresUnrequestedAnswers(t *testing.T) {
+	p := newTestProcessor(Options{})
+	ev := collector.Event{Timestamp: time.Unix(100, 0), Pid: 1, Fd: 3, Protocol: collector.ProtocolMySQL}
+
+	ev.Direction = collector.DirectionRequest
+	ev.Data = mysqlPacket(0, append([]byte{0x03}, "SELECT a FROM t"...)...)
+	p.HandleEvent(ev)
+
+	// A result set whose rows outrun the capture, then a later chunk of it
+	// whose sequence number wrapped around to 1.
+	ev.Direction = collector.DirectionResponse
+	ev.Data = append(mysqlPacket(1, 1), mysqlPacket(2, 3, 'd', 'e', 'f')...)
+	p.HandleEvent(ev)
+	ev.Data = mysqlPacket(1, 0x00, 5, 0, 2, 0, 0, 0)
+	p.HandleEvent(ev)
+
+	got := entries(p)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(got))
+	}
+	if got[0].Rows != 0 || got[0].Error {
+		t.Fatalf("expected unknown rows to stay unset, got %+v", got[0])
+	}
+	if snap := p.diagnostics.Snapshot(); snap.ParsedResponses != 1 || snap.UnmatchedResponses != 0 {
+		t.Fatalf("expected the later chunk to be skipped, got %d parsed and %d unmatched", snap.ParsedResponses, snap.UnmatchedResponses)
+	}
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/correlation"
+	"github.com/emresahna/heimdall/internal/respparse"
+)
+
+// redisConn numbers the commands and replies of a connection. Redis answers
+// in order, so the nth reply belongs to the nth command even when a client
+// pipelines several before reading. Replies in pub/sub mode have no command
+// and are counted as unmatched. Once replies go uncaptured the count is lost,
+// and the commands still waiting are dropped.
+type redisConn struct {
+	seq     sequence
+	replies respparse.ReplyReader
+}
+
+func (p *Processor) handleRedis(ev collector.Event) {
+	state := p.conns.get(ev.Pid, ev.Fd, ev.Socket)
+	if state.redis == nil {
+		state.redis = &redisConn{}
+	}
+	conn := state.redis
+
+	if ev.Direction == collector.DirectionRequest {
+		for _, cmd := range respparse.ParseCommands(ev.Data) {
+			if p.diagnostics != nil {
+				p.diagnostics.IncParsedRequests()
+			}
+			p.add(correlation.Request{
+				Key:      requestKey(ev, conn.seq.next()),
+				Tid:      ev.Tid,
+				CgroupID: ev.CgroupID,
+				Role:     ev.Role().String(),
+				Type:     "redis",
+				Method:   cmd.Name,
+				Path:     respparse.FormatKey(cmd.Key, p.opts.RedisKeys),
+				Local:    ev.Local,
+				Remote:   ev.Remote,
+				Started:  ev.Timestamp,
+			})
+		}
+		return
+	}
+
+	if conn.seq.lost {
+		return
+	}
+	replies, ok := conn.replies.Read(ev.Data, int(ev.Length))
+	for _, reply := range replies {
+		if p.diagnostics != nil {
+			p.diagnostics.IncParsedResponses()
+		}
+		stream, ok := conn.seq.answer()
+		if !ok {
+			if p.diagnostics != nil {
+				p.diagnostics.IncUnmatchedResponses()
+			}
+			continue
+		}
+		req, ok := p.match(requestKey(ev, stream))
+		if !ok {
+			continue
+		}
+		p.complete(req, ev.Timestamp, outcome{err: reply.Error})
+	}
+	if !ok {
+		p.resync(ev, &conn.seq)
+	}
+}
@@ -0,0 +1,144 @@
+package pipeline
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emresahna/heimdall/internal/collector"
+	"github.com/emresahna/heimdall/internal/respparse"
+	"github.com/emresahna/heimdall/internal/telemetry"
+)
+
+func TestHandleRedis(t *testing.T) {
+	type write struct {
+		dir  collector.Direction
+		data string
+	}
+	tests := []struct {
+		name   string
+		writes []write
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "single command",
+			writes: []write{
+				{collector.DirectionRequest, "*2\r\n$3\r\nGET\r\n$1\r\nk\r\n"},
+				{collector.DirectionResponse, "$1\r\nv\r\n"},
+			},
+			want: []telemetry.LogEntry{{Method: "GET", Path: "k"}},
+		},
+		{
+			name: "pipelined commands",
+			writes: []write{
+				{collector.DirectionRequest, "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n*2\r\n$4\r\nINCR\r\n$1\r\nk\r\n"},
+				{collector.DirectionResponse, "+OK\r\n-ERR value is not an integer\r\n"},
+			},
+			want: []telemetry.LogEntry{
+				{Method: "SET", Path: "k"},
+				{Method: "INCR", Path: "k", Error: true},
+			},
+		},
+		{
+			name: "reply without a command",
+			writes: []write{
+				{collector.DirectionResponse, "+OK\r\n"},
+				{collector.DirectionRequest, "*1\r\n$4\r\nPING\r\n"},
+				{collector.DirectionResponse, "+PONG\r\n"},
+			},
+			want: []telemetry.LogEntry{{Method: "PING"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{RedisKeys: respparse.KeyPlain})
+			start := time.Unix(100, 0)
+			for i, w := range tt.writes {
+				p.HandleEvent(collector.Event{
+					Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+					Pid:       1,
+					Fd:        3,
+					Direction: w.dir,
+					Protocol:  collector.ProtocolRedis,
+					Data:      []byte(w.data),
+				})
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Type != "redis" || g.Method != want.Method || g.Path != want.Path || g.Error != want.Error {
+					t.Errorf("entry %d: got %s %s %s error=%v, want %s %s error=%v", i, g.Type, g.Method, g.Path, g.Error, want.Method, want.Path, want.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleRedisPartialReplies(t *testing.T) {
+	// A value whose second half reads like an error reply.
+	value := strings.Repeat("x", 3000) + "-ERR not a reply\r\n" + strings.Repeat("x", 2000)
+	bulk := "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+	array := "*2\r\n$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n$1\r\nb\r\n"
+
+	tests := []struct {
+		name   string
+		events []collector.Event
+		want   []telemetry.LogEntry
+	}{
+		{
+			name: "bulk reply split across reads",
+			events: []collector.Event{
+				{Direction: collector.DirectionRequest, Data: []byte("*2\r\n$3\r\nGET\r\n$1\r\nk\r\n*1\r\n$4\r\nPING\r\n")},
+				{Direction: collector.DirectionResponse, Data: []byte(bulk[:3007])},
+				{Direction: collector.DirectionResponse, Data: []byte(bulk[3007:] + "+PONG\r\n")},
+			},
+			want: []telemetry.LogEntry{{Method: "GET", Path: "k"}, {Method: "PING"}},
+		},
+		{
+			name: "bulk reply cut off by the capture",
+			events: []collector.Event{
+				{Direction: collector.DirectionRequest, Data: []byte("*2\r\n$3\r\nGET\r\n$1\r\nk\r\n*1\r\n$4\r\nPING\r\n")},
+				{Direction: collector.DirectionResponse, Data: []byte(bulk[:1000]), Length: 4000},
+				{Direction: collector.DirectionResponse, Data: []byte(bulk[4000:] + "+PONG\r\n")},
+			},
+			want: []telemetry.LogEntry{{Method: "GET", Path: "k"}, {Method: "PING"}},
+		},
+		{
+			name: "replies lost with the capture",
+			events: []collector.Event{
+				{Direction: collector.DirectionRequest, Data: []byte("*4\r\n$6\r\nLRANGE\r\n$1\r\nl\r\n$1\r\n0\r\n$1\r\n1\r\n*1\r\n$4\r\nPING\r\n")},
+				{Direction: collector.DirectionResponse, Data: []byte(array[:1000]), Length: uint32(len(array) + len("+PONG\r\n"))},
+				{Direction: collector.DirectionRequest, Data: []byte("*2\r\n$3\r\nGET\r\n$1\r\nk\r\n")},
+				{Direction: collector.DirectionResponse, Data: []byte("-ERR wrong type\r\n")},
+			},
+			want: []telemetry.LogEntry{{Method: "LRANGE", Path: "l"}, {Method: "GET", Path: "k", Error: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProcessor(Options{RedisKeys: respparse.KeyPlain})
+			start := time.Unix(100, 0)
+			for i, ev := range tt.events {
+				ev.Timestamp = start.Add(time.Duration(i) * time.Millisecond)
+				ev.Pid = 1
+				ev.Fd = 3
+				ev.Protocol = collector.ProtocolRedis
+				p.HandleEvent(ev)
+			}
+			got := entries(p)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d entries, got %d: %+v", len(tt.want), len(got), got)
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Method != want.Method || g.Path != want.Path || g.Error != want.Error {
+					t.Errorf("entry %d: got %s %s error=%v, want %s %s error=%v", i, g.Method, g.Path, g.Error, want.Method, want.Path, want.Error)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package respparse
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Nesting deeper than this is not expected from a server and is treated as
+// garbage rather than followed.
+const maxDepth = 16
+
+// Type lines longer than this are taken for garbage.
+const maxLine = 64 << 10
+
+// KeyMode controls how command keys are reported.
+type KeyMode string
+
+const (
+	KeyPlain  KeyMode = "plain"
+	KeyHash   KeyMode = "hash"
+	KeyRedact KeyMode = "redact"
+)
+
+// Commands whose first argument is not a key, or is a secret.
+var keylessCommands = map[string]struct{}{
+	"AUTH": {}, "HELLO": {}, "PING": {}, "ECHO": {}, "SELECT": {}, "INFO": {},
+	"CONFIG": {}, "CLIENT": {}, "CLUSTER": {}, "COMMAND": {}, "MULTI": {},
+	"EXEC": {}, "DISCARD": {}, "QUIT": {}, "DBSIZE": {}, "FLUSHDB": {},
+	"FLUSHALL": {}, "SCAN": {}, "TIME": {}, "SAVE": {}, "BGSAVE": {},
+	"SUBSCRIBE": {}, "PSUBSCRIBE": {}, "SSUBSCRIBE": {}, "UNSUBSCRIBE": {},
+	"PUNSUBSCRIBE": {}, "PUBLISH": {}, "SCRIPT": {}, "EVAL": {}, "EVALSHA": {},
+	"EVAL_RO": {}, "EVALSHA_RO": {}, "FCALL": {}, "FUNCTION": {}, "SLOWLOG": {},
+	"MEMORY": {}, "LATENCY": {}, "ACL": {}, "READONLY": {}, "READWRITE": {},
+}
+
+// Command is one request sent to the server. Key is empty for commands that
+// do not take one.
+type Command struct {
+	Name string
+	Key  string
+}
+
+// Reply is one top-level reply. Out-of-band push messages are not replies.
+type Reply struct {
+	Error bool
+}
+
+// ParseCommands returns the commands in a request buffer, in order, so that
+// pipelined commands can be matched to their replies. A command cut off at
+// the end of the buffer is returned once its name has been read.
+func ParseCommands(data []byte) []Command {
+	var commands []Command
+	pos := 0
+	for pos < len(data) && data[pos] == '*' {
+		line, next, ok := readLine(data, pos)
+		if !ok {
+			break
+		}
+		count, ok := parseInt(line[1:])
+		if !ok || count <= 0 {
+			break
+		}
+		pos = next
+
+		var args []string
+		complete := true
+		for i := 0; i < count; i++ {
+			if pos >= len(data) || data[pos] != '$' {
+				complete = false
+				break
+			}
+			line, next, ok := readLine(data, pos)
+			if !ok {
+				complete = false
+				break
+			}
+			size, ok := parseInt(line[1:])
+			if !ok || size < 0 {
+				return commands
+			}
+			pos = next
+			end := pos + size
+			if end+2 > len(data) {
+				if i < 2 && end <= len(data) {
+					args = append(args, string(data[pos:end]))
+				}
+				complete = false
+				break
+			}
+			if data[end] != '\r' || data[end+1] != '\n' {
+				return commands
+			}
+			if i < 2 {
+				args = append(args, string(data[pos:end]))
+			}
+			pos = end + 2
+		}
+		if len(args) == 0 {
+			break
+		}
+
+		cmd := Command{Name: strings.ToUpper(args[0])}
+		if _, keyless := keylessCommands[cmd.Name]; !keyless && len(args) > 1 {
+			cmd.Key = args[1]
+		}
+		commands = append(commands, cmd)
+		if !complete {
+			break
+		}
+	}
+	return commands
+}
+
+// ReplyReader follows the replies of one connection across reads. Large
+// values span several reads, and the reader keeps its place in them so that
+// their contents are not taken for replies.
+type ReplyReader struct {
+	// open holds the elements still to come of each unfinished aggregate,
+	// outermost first.
+	open []int
+	// bulk is what remains of a bulk string, including its CRLF.
+	bulk int
+	// line is the start of a line cut off at the end of the last read.
+	line []byte
+}
+
+// Read returns the replies that start in data, the captured start of a read
+// of size bytes; a smaller size means data is all of it. A reply counts once
+// its first line is read. ok is false when the stream cannot be followed,
+// either because data is not RESP where a value should start or because the
+// bytes left out of the capture went past the value being read. The reader
+// then starts over with the next read.
+func (r *ReplyReader) Read(data []byte, size int) (replies []Reply, ok bool) {
+	unseen := size - len(data)
+	for len(data) > 0 {
+		if r.bulk > 0 {
+			n := min(r.bulk, len(data))
+			r.bulk -= n
+			data = data[n:]
+			if r.bulk == 0 {
+				r.endValue()
+			}
+			continue
+		}
+		line, rest, found := r.readLine(data)
+		if !found {
+			if len(r.line) > maxLine {
+				r.reset()
+				return replies, false
+			}
+			break
+		}
+		data = rest
+		if len(line) == 0 {
+			r.reset()
+			return replies, false
+		}
+		// Push messages and attributes are not answers to a command.
+		if typ := line[0]; len(r.open) == 0 && typ != '>' && typ != '|' {
+			replies = append(replies, Reply{Error: typ == '-' || typ == '!'})
+		}
+		if !r.startValue(line) {
+			r.reset()
+			return replies, false
+		}
+	}
+
+	if unseen > 0 {
+		if len(r.line) > 0 || r.bulk < unseen {
+			r.reset()
+			return replies, false
+		}
+		r.bulk -= unseen
+		if r.bulk == 0 {
+			r.endValue()
+		}
+	}
+	return replies, true
+}
+
+// startValue follows the type line of a value.
+func (r *ReplyReader) startValue(line []byte) bool {
+	switch typ := line[0]; typ {
+	case '+', '-', ':', ',', '#', '_', '(':
+		r.endValue()
+	case '$', '!', '=':
+		size, valid := parseInt(line[1:])
+		if !valid {
+			return false
+		}
+		if size < 0 {
+			r.endValue()
+		} else {
+			r.bulk = size + 2
+		}
+	case '*', '~', '>', '%', '|':
+		count, valid := parseInt(line[1:])
+		if !valid {
+			return false
+		}
+		if typ == '%' || typ == '|' {
+			count *= 2
+		}
+		if count <= 0 {
+			r.endValue()
+			return true
+		}
+		if len(r.open) >= maxDepth {
+			return false
+		}
+		r.open = append(r.open, count)
+	default:
+		return false
+	}
+	return true
+}
+
+// endValue counts a finished value against the aggregates it belongs to,
+// closing those it completes.
+func (r *ReplyReader) endValue() {
+	for len(r.open) > 0 {
+		last := len(r.open) - 1
+		r.open[last]--
+		if r.open[last] > 0 {
+			return
+		}
+		r.open = r.open[:last]
+	}
+}
+
+// readLine returns the line at the start of data, joined to the part of it
+// cut off at the end of the last read.
+func (r *ReplyReader) readLine(data []byte) (line, rest []byte, found bool) {
+	carried := len(r.line)
+	joined := data
+	if carried > 0 {
+		joined = append(r.line, data...)
+	}
+	idx := bytes.Index(joined, []byte("\r\n"))
+	if idx < 0 {
+		if carried == 0 {
+			joined = append([]byte(nil), data...)
+		}
+		r.line = joined
+		return nil, nil, false
+	}
+	r.line = nil
+	return joined[:idx], data[idx+2-carried:], true
+}
+
+func (r *ReplyReader) reset() {
+	r.open = r.open[:0]
+	r.bulk = 0
+	r.line = nil
+}
+
+func readLine(data []byte, pos int) ([]byte, int, bool) {
+	idx := bytes.Index(data[pos:], []byte("\r\n"))
+	if idx < 0 {
+		return nil, 0, false
+	}
+	return data[pos : pos+idx], pos + idx + 2, true
+}
+
+func parseInt(b []byte) (int, bool) {
+	if len(b) == 0 || len(b) > 10 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(b))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// FormatKey applies mode to a key. Unknown modes hash, so a typo never leaks
+// keys.
+func FormatKey(key string, mode KeyMode) string {
+	if key == "" {
+		return ""
+	}
+	switch mode {
+	case KeyPlain:
+		return key
+	case KeyRedact:
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
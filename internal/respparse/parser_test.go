@@ -0,0 +1,106 @@
+package respparse
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseCommandsPipelined(t *testing.T) {
+	data := []byte("*3\r\n$3\r\nset\r\n$7\r\nuser:42\r\n$2\r\nok\r\n" +
+		"*1\r\n$4\r\nPING\r\n" +
+		"*2\r\n$4\r\nAUTH\r\n$6\r\nsecret\r\n" +
+		"*2\r\n$3\r\nGET\r\n$10\r\ncut")
+
+	commands := ParseCommands(data)
+	want := []Command{
+		{Name: "SET", Key: "user:42"},
+		{Name: "PING"},
+		{Name: "AUTH"},
+		{Name: "GET"},
+	}
+	if len(commands) != len(want) {
+		t.Fatalf("got %d commands, want %d: %+v", len(commands), len(want), commands)
+	}
+	for i, w := range want {
+		if commands[i] != w {
+			t.Fatalf("command %d = %+v, want %+v", i, commands[i], w)
+		}
+	}
+}
+
+func TestParseCommandsRejectsGarbage(t *testing.T) {
+	if commands := ParseCommands([]byte("GET / HTTP/1.1\r\n\r\n")); commands != nil {
+		t.Fatalf("unexpected commands %+v", commands)
+	}
+	if commands := ParseCommands([]byte("*2\r\n$3\r\nGETX\r\n")); commands != nil {
+		t.Fatalf("unexpected commands %+v", commands)
+	}
+}
+
+func TestReplyReader(t *testing.T) {
+	data := []byte("+OK\r\n" +
+		"-WRONGTYPE Operation against a key holding the wrong kind of value\r\n" +
+		"$-1\r\n" +
+		">3\r\n$7\r\nmessage\r\n$2\r\nch\r\n$2\r\nhi\r\n" +
+		"*2\r\n:1\r\n*1\r\n$3\r\nfoo\r\n" +
+		"$100\r\ntruncated")
+
+	var r ReplyReader
+	replies, ok := r.Read(data, 0)
+	want := []bool{false, true, false, false, false}
+	if !ok || len(replies) != len(want) {
+		t.Fatalf("got %d replies ok=%v, want %d: %+v", len(replies), ok, len(want), replies)
+	}
+	for i, w := range want {
+		if replies[i].Error != w {
+			t.Fatalf("reply %d error = %v, want %v", i, replies[i].Error, w)
+		}
+	}
+
+	// The rest of the bulk string, which reads like a reply of its own,
+	// then a line split across reads.
+	tail := append(bytes.Repeat([]byte("x"), 84), "+fake\r\n\r\n-ER"...)
+	if replies, ok := r.Read(tail, 0); !ok || len(replies) != 0 {
+		t.Fatalf("tail: %+v ok=%v", replies, ok)
+	}
+	if replies, ok := r.Read([]byte("R\r\n:1\r\n"), 0); !ok || len(replies) != 2 || !replies[0].Error {
+		t.Fatalf("after split line: %+v ok=%v", replies, ok)
+	}
+
+	if _, ok := new(ReplyReader).Read([]byte("tail of a large value\r\n"), 0); ok {
+		t.Fatalf("expected data that does not start with a reply to be rejected")
+	}
+}
+
+func TestReplyReaderTruncated(t *testing.T) {
+	// Only the first bytes of a large bulk string were captured; the rest of
+	// the read is known to belong to the same string.
+	var r ReplyReader
+	if replies, ok := r.Read([]byte("$1000\r\nabc"), 500); !ok || len(replies) != 1 {
+		t.Fatalf("first read: %+v ok=%v", replies, ok)
+	}
+	if replies, ok := r.Read(bytes.Repeat([]byte("x"), 100), 509); !ok || len(replies) != 0 {
+		t.Fatalf("second read: %+v ok=%v", replies, ok)
+	}
+	if replies, ok := r.Read([]byte("+OK\r\n"), 0); !ok || len(replies) != 1 {
+		t.Fatalf("next reply: %+v ok=%v", replies, ok)
+	}
+
+	// Bytes left out after an array element may hold further replies.
+	if _, ok := r.Read([]byte("*100\r\n:1\r\n"), 2000); ok {
+		t.Fatalf("expected uncaptured replies to lose the stream")
+	}
+}
+
+func TestFormatKey(t *testing.T) {
+	if got := FormatKey("user:42", KeyPlain); got != "user:42" {
+		t.Fatalf("plain = %q", got)
+	}
+	if got := FormatKey("user:42", KeyRedact); got != "" {
+		t.Fatalf("redact = %q", got)
+	}
+	hashed := FormatKey("user:42", KeyHash)
+	if len(hashed) != 16 || hashed != FormatKey("user:42", "bogus") {
+		t.Fatalf("hash = %q", hashed)
+	}
+}
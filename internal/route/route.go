@@ -0,0 +1,189 @@
+package route
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Placeholders for the path segments the heuristics replace.
+const (
+	placeholderID   = "{id}"
+	placeholderUUID = "{uuid}"
+	placeholderHash = "{hash}"
+)
+
+// Rule is a user-supplied template such as /orders/{order}/items/{item}.
+// Segments in braces match any single segment. A rule with a Service only
+// applies to entries whose container or namespace has that name.
+type Rule struct {
+	Service  string
+	Template string
+	segments []string
+}
+
+// ParseRules reads rules written as "template" or "service=template".
+func ParseRules(specs []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(specs))
+	for _, spec := range specs {
+		var rule Rule
+		service, template, found := strings.Cut(spec, "=")
+		if found {
+			rule.Service = strings.TrimSpace(service)
+			rule.Template = strings.TrimSpace(template)
+		} else {
+			rule.Template = strings.TrimSpace(spec)
+		}
+		if !strings.HasPrefix(rule.Template, "/") || (found && rule.Service == "") {
+			return nil, fmt.Errorf("invalid route template %q", spec)
+		}
+		rule.segments = strings.Split(rule.Template, "/")
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Normalizer turns request paths into route templates. A nil Normalizer
+// uses the heuristics only.
+type Normalizer struct {
+	rules []Rule
+}
+
+func NewNormalizer(rules []Rule) *Normalizer {
+	return &Normalizer{rules: rules}
+}
+
+// Split separates the query string from a request target. Absolute-form
+// targets sent to proxies are reduced to their path.
+func Split(target string) (path, query string) {
+	if rest, ok := strings.CutPrefix(target, "http://"); ok {
+		target = hostless(rest)
+	} else if rest, ok := strings.CutPrefix(target, "https://"); ok {
+		target = hostless(rest)
+	}
+	path, query, _ = strings.Cut(target, "?")
+	path, _, _ = strings.Cut(path, "#")
+	return path, query
+}
+
+func hostless(rest string) string {
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		if rest[i] == '?' {
+			return "/" + rest[i:]
+		}
+		return rest[i:]
+	}
+	return "/"
+}
+
+// Template returns the route of path, which must not carry a query string.
+// Rules for one of services are tried first, then rules for every service,
+// then the heuristics.
+func (n *Normalizer) Template(path string, services ...string) string {
+	if path == "" || path == "*" {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	if n != nil {
+		for _, rule := range n.rules {
+			if rule.Service != "" && contains(services, rule.Service) && rule.matches(segments) {
+				return rule.Template
+			}
+		}
+		for _, rule := range n.rules {
+			if rule.Service == "" && rule.matches(segments) {
+				return rule.Template
+			}
+		}
+	}
+
+	for i, segment := range segments {
+		segments[i] = normalizeSegment(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func (r Rule) matches(segments []string) bool {
+	if len(r.segments) != len(segments) {
+		return false
+	}
+	for i, want := range r.segments {
+		if strings.HasPrefix(want, "{") && strings.HasSuffix(want, "}") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if want != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeSegment replaces segments that look like identifiers: numbers,
+// UUIDs, hex digests and long tokens with several digits.
+func normalizeSegment(segment string) string {
+	if segment == "" {
+		return segment
+	}
+	var digits, hex, letters int
+	numeric := true
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+			hex++
+		case (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'):
+			hex++
+			letters++
+			numeric = false
+		case (c >= 'g' && c <= 'z') || (c >= 'G' && c <= 'Z'):
+			letters++
+			numeric = false
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			numeric = false
+		}
+	}
+
+	switch {
+	case digits > 0 && numeric:
+		return placeholderID
+	case isUUID(segment):
+		return placeholderUUID
+	case hex == len(segment) && len(segment) >= 16 && digits > 0:
+		return placeholderHash
+	case len(segment) >= 8 && digits >= 3 && letters > 0:
+		return placeholderID
+	}
+	return segment
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			if c != '-' {
+				return false
+			}
+			continue
+		}
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') && !(c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
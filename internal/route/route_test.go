@@ -0,0 +1,53 @@
+package route
+
+import "testing"
+
+func TestSplit(t *testing.T) {
+	path, query := Split("/users/8123/orders/55?x=1")
+	if path != "/users/8123/orders/55" || query != "x=1" {
+		t.Fatalf("unexpected split %q %q", path, query)
+	}
+	if path, _ := Split("http://example.com/a?b"); path != "/a" {
+		t.Fatalf("unexpected absolute-form path %q", path)
+	}
+}
+
+func TestTemplateHeuristics(t *testing.T) {
+	var n *Normalizer
+	cases := map[string]string{
+		"/users/8123/orders/55":                                "/users/{id}/orders/{id}",
+		"/files/3f2b9c1e8a7d6b5c4e3f2a1b0c9d8e7f":              "/files/{hash}",
+		"/sessions/123e4567-e89b-12d3-a456-426614174000/close": "/sessions/{uuid}/close",
+		"/customers/cus_J4k2LmN8pQ":                            "/customers/{id}",
+		"/api/v1/oauth2callback":                               "/api/v1/oauth2callback",
+		"/reports/2024-01-31":                                  "/reports/{id}",
+		"/":                                                    "/",
+	}
+	for in, want := range cases {
+		if got := n.Template(in); got != want {
+			t.Fatalf("Template(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTemplateRules(t *testing.T) {
+	rules, err := ParseRules([]string{"checkout=/carts/{cart}/items/{sku}", "/static/{file}"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := NewNormalizer(rules)
+
+	if got := n.Template("/carts/42/items/ABC-77", "checkout", "shop"); got != "/carts/{cart}/items/{sku}" {
+		t.Fatalf("unexpected service rule result %q", got)
+	}
+	if got := n.Template("/carts/42/items/ABC-77", "billing"); got != "/carts/{id}/items/ABC-77" {
+		t.Fatalf("expected other services to use heuristics, got %q", got)
+	}
+	if got := n.Template("/static/app.js"); got != "/static/{file}" {
+		t.Fatalf("unexpected global rule result %q", got)
+	}
+
+	if _, err := ParseRules([]string{"checkout=carts"}); err == nil {
+		t.Fatalf("expected a template without a leading slash to be rejected")
+	}
+}
@@ -23,25 +23,47 @@ const (
 )
 
 type LogEntry struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	Pid           uint32                 `protobuf:"varint,2,opt,name=pid,proto3" json:"pid,omitempty"`
-	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
-	Payload       string                 `protobuf:"bytes,4,opt,name=payload,proto3" json:"payload,omitempty"`
-	DurationNs    uint64                 `protobuf:"varint,5,opt,name=duration_ns,json=durationNs,proto3" json:"duration_ns,omitempty"`
-	Status        uint32                 `protobuf:"varint,6,opt,name=status,proto3" json:"status,omitempty"`
-	Method        string                 `protobuf:"bytes,7,opt,name=method,proto3" json:"method,omitempty"`
-	Path          string                 `protobuf:"bytes,8,opt,name=path,proto3" json:"path,omitempty"`
-	Tid           uint32                 `protobuf:"varint,9,opt,name=tid,proto3" json:"tid,omitempty"`
-	Fd            int32                  `protobuf:"zigzag32,10,opt,name=fd,proto3" json:"fd,omitempty"`
-	CgroupId      uint64                 `protobuf:"varint,11,opt,name=cgroup_id,json=cgroupId,proto3" json:"cgroup_id,omitempty"`
-	Node          string                 `protobuf:"bytes,12,opt,name=node,proto3" json:"node,omitempty"`
-	Namespace     string                 `protobuf:"bytes,13,opt,name=namespace,proto3" json:"namespace,omitempty"`
-	Pod           string                 `protobuf:"bytes,14,opt,name=pod,proto3" json:"pod,omitempty"`
-	Container     string                 `protobuf:"bytes,15,opt,name=container,proto3" json:"container,omitempty"`
-	ContainerId   string                 `protobuf:"bytes,16,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp       *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Pid             uint32                 `protobuf:"varint,2,opt,name=pid,proto3" json:"pid,omitempty"`
+	Type            string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Payload         string                 `protobuf:"bytes,4,opt,name=payload,proto3" json:"payload,omitempty"`
+	DurationNs      uint64                 `protobuf:"varint,5,opt,name=duration_ns,json=durationNs,proto3" json:"duration_ns,omitempty"`
+	Status          uint32                 `protobuf:"varint,6,opt,name=status,proto3" json:"status,omitempty"`
+	Method          string                 `protobuf:"bytes,7,opt,name=method,proto3" json:"method,omitempty"`
+	Path            string                 `protobuf:"bytes,8,opt,name=path,proto3" json:"path,omitempty"`
+	Tid             uint32                 `protobuf:"varint,9,opt,name=tid,proto3" json:"tid,omitempty"`
+	Fd              int32                  `protobuf:"zigzag32,10,opt,name=fd,proto3" json:"fd,omitempty"`
+	CgroupId        uint64                 `protobuf:"varint,11,opt,name=cgroup_id,json=cgroupId,proto3" json:"cgroup_id,omitempty"`
+	Node            string                 `protobuf:"bytes,12,opt,name=node,proto3" json:"node,omitempty"`
+	Namespace       string                 `protobuf:"bytes,13,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Pod             string                 `protobuf:"bytes,14,opt,name=pod,proto3" json:"pod,omitempty"`
+	Container       string                 `protobuf:"bytes,15,opt,name=container,proto3" json:"container,omitempty"`
+	ContainerId     string                 `protobuf:"bytes,16,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	Family          string                 `protobuf:"bytes,17,opt,name=family,proto3" json:"family,omitempty"`
+	LocalIp         string                 `protobuf:"bytes,18,opt,name=local_ip,json=localIp,proto3" json:"local_ip,omitempty"`
+	LocalPort       uint32                 `protobuf:"varint,19,opt,name=local_port,json=localPort,proto3" json:"local_port,omitempty"`
+	RemoteIp        string                 `protobuf:"bytes,20,opt,name=remote_ip,json=remoteIp,proto3" json:"remote_ip,omitempty"`
+	RemotePort      uint32                 `protobuf:"varint,21,opt,name=remote_port,json=remotePort,proto3" json:"remote_port,omitempty"`
+	Role            string                 `protobuf:"bytes,22,opt,name=role,proto3" json:"role,omitempty"`
+	Error           bool                   `protobuf:"varint,23,opt,name=error,proto3" json:"error,omitempty"`
+	ErrorCode       string                 `protobuf:"bytes,24,opt,name=error_code,json=errorCode,proto3" json:"error_code,omitempty"`
+	Rows            uint64                 `protobuf:"varint,25,opt,name=rows,proto3" json:"rows,omitempty"`
+	Keys            uint32                 `protobuf:"varint,26,opt,name=keys,proto3" json:"keys,omitempty"`
+	HitRatio        float64                `protobuf:"fixed64,27,opt,name=hit_ratio,json=hitRatio,proto3" json:"hit_ratio,omitempty"`
+	TraceId         string                 `protobuf:"bytes,28,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
+	SpanId          string                 `protobuf:"bytes,29,opt,name=span_id,json=spanId,proto3" json:"span_id,omitempty"`
+	TraceSampled    bool                   `protobuf:"varint,30,opt,name=trace_sampled,json=traceSampled,proto3" json:"trace_sampled,omitempty"`
+	Route           string                 `protobuf:"bytes,31,opt,name=route,proto3" json:"route,omitempty"`
+	Query           string                 `protobuf:"bytes,32,opt,name=query,proto3" json:"query,omitempty"`
+	Host            string                 `protobuf:"bytes,33,opt,name=host,proto3" json:"host,omitempty"`
+	UserAgent       string                 `protobuf:"bytes,34,opt,name=user_agent,json=userAgent,proto3" json:"user_agent,omitempty"`
+	ContentType     string                 `protobuf:"bytes,35,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	ContentLength   uint64                 `protobuf:"varint,36,opt,name=content_length,json=contentLength,proto3" json:"content_length,omitempty"`
+	Attributes      map[string]string      `protobuf:"bytes,37,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ResponsePayload string                 `protobuf:"bytes,38,opt,name=response_payload,json=responsePayload,proto3" json:"response_payload,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *LogEntry) Reset() {
@@ -186,6 +208,160 @@ func (x *LogEntry) GetContainerId() string {
 	return ""
 }
 
+func (x *LogEntry) GetFamily() string {
+	if x != nil {
+		return x.Family
+	}
+	return ""
+}
+
+func (x *LogEntry) GetLocalIp() string {
+	if x != nil {
+		return x.LocalIp
+	}
+	return ""
+}
+
+func (x *LogEntry) GetLocalPort() uint32 {
+	if x != nil {
+		return x.LocalPort
+	}
+	return 0
+}
+
+func (x *LogEntry) GetRemoteIp() string {
+	if x != nil {
+		return x.RemoteIp
+	}
+	return ""
+}
+
+func (x *LogEntry) GetRemotePort() uint32 {
+	if x != nil {
+		return x.RemotePort
+	}
+	return 0
+}
+
+func (x *LogEntry) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *LogEntry) GetError() bool {
+	if x != nil {
+		return x.Error
+	}
+	return false
+}
+
+func (x *LogEntry) GetErrorCode() string {
+	if x != nil {
+		return x.ErrorCode
+	}
+	return ""
+}
+
+func (x *LogEntry) GetRows() uint64 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *LogEntry) GetKeys() uint32 {
+	if x != nil {
+		return x.Keys
+	}
+	return 0
+}
+
+func (x *LogEntry) GetHitRatio() float64 {
+	if x != nil {
+		return x.HitRatio
+	}
+	return 0
+}
+
+func (x *LogEntry) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *LogEntry) GetSpanId() string {
+	if x != nil {
+		return x.SpanId
+	}
+	return ""
+}
+
+func (x *LogEntry) GetTraceSampled() bool {
+	if x != nil {
+		return x.TraceSampled
+	}
+	return false
+}
+
+func (x *LogEntry) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *LogEntry) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *LogEntry) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *LogEntry) GetUserAgent() string {
+	if x != nil {
+		return x.UserAgent
+	}
+	return ""
+}
+
+func (x *LogEntry) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *LogEntry) GetContentLength() uint64 {
+	if x != nil {
+		return x.ContentLength
+	}
+	return 0
+}
+
+func (x *LogEntry) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *LogEntry) GetResponsePayload() string {
+	if x != nil {
+		return x.ResponsePayload
+	}
+	return ""
+}
+
 type LogBatch struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Entries       []*LogEntry            `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
@@ -286,7 +462,7 @@ var File_internal_sender_log_proto protoreflect.FileDescriptor
 
 const file_internal_sender_log_proto_rawDesc = "" +
 	"\n" +
-	"\x19internal/sender/log.proto\x12\x03log\x1a\x1fgoogle/protobuf/timestamp.proto\"\xad\x03\n" +
+	"\x19internal/sender/log.proto\x12\x03log\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf6\b\n" +
 	"\bLogEntry\x128\n" +
 	"\ttimestamp\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x10\n" +
 	"\x03pid\x18\x02 \x01(\rR\x03pid\x12\x12\n" +
@@ -305,7 +481,38 @@ const file_internal_sender_log_proto_rawDesc = "" +
 	"\tnamespace\x18\r \x01(\tR\tnamespace\x12\x10\n" +
 	"\x03pod\x18\x0e \x01(\tR\x03pod\x12\x1c\n" +
 	"\tcontainer\x18\x0f \x01(\tR\tcontainer\x12!\n" +
-	"\fcontainer_id\x18\x10 \x01(\tR\vcontainerId\"3\n" +
+	"\fcontainer_id\x18\x10 \x01(\tR\vcontainerId\x12\x16\n" +
+	"\x06family\x18\x11 \x01(\tR\x06family\x12\x19\n" +
+	"\blocal_ip\x18\x12 \x01(\tR\alocalIp\x12\x1d\n" +
+	"\n" +
+	"local_port\x18\x13 \x01(\rR\tlocalPort\x12\x1b\n" +
+	"\tremote_ip\x18\x14 \x01(\tR\bremoteIp\x12\x1f\n" +
+	"\vremote_port\x18\x15 \x01(\rR\n" +
+	"remotePort\x12\x12\n" +
+	"\x04role\x18\x16 \x01(\tR\x04role\x12\x14\n" +
+	"\x05error\x18\x17 \x01(\bR\x05error\x12\x1d\n" +
+	"\n" +
+	"error_code\x18\x18 \x01(\tR\terrorCode\x12\x12\n" +
+	"\x04rows\x18\x19 \x01(\x04R\x04rows\x12\x12\n" +
+	"\x04keys\x18\x1a \x01(\rR\x04keys\x12\x1b\n" +
+	"\thit_ratio\x18\x1b \x01(\x01R\bhitRatio\x12\x19\n" +
+	"\btrace_id\x18\x1c \x01(\tR\atraceId\x12\x17\n" +
+	"\aspan_id\x18\x1d \x01(\tR\x06spanId\x12#\n" +
+	"\rtrace_sampled\x18\x1e \x01(\bR\ftraceSampled\x12\x14\n" +
+	"\x05route\x18\x1f \x01(\tR\x05route\x12\x14\n" +
+	"\x05query\x18  \x01(\tR\x05query\x12\x12\n" +
+	"\x04host\x18! \x01(\tR\x04host\x12\x1d\n" +
+	"\n" +
+	"user_agent\x18\" \x01(\tR\tuserAgent\x12!\n" +
+	"\fcontent_type\x18# \x01(\tR\vcontentType\x12%\n" +
+	"\x0econtent_length\x18$ \x01(\x04R\rcontentLength\x12=\n" +
+	"\n" +
+	"attributes\x18% \x03(\v2\x1d.log.LogEntry.AttributesEntryR\n" +
+	"attributes\x12)\n" +
+	"\x10response_payload\x18& \x01(\tR\x0fresponsePayload\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"3\n" +
 	"\bLogBatch\x12'\n" +
 	"\aentries\x18\x01 \x03(\v2\r.log.LogEntryR\aentries\">\n" +
 	"\bResponse\x12\x18\n" +
@@ -327,23 +534,25 @@ func file_internal_sender_log_proto_rawDescGZIP() []byte {
 	return file_internal_sender_log_proto_rawDescData
 }
 
-var file_internal_sender_log_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_internal_sender_log_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_internal_sender_log_proto_goTypes = []any{
 	(*LogEntry)(nil),              // 0: log.LogEntry
 	(*LogBatch)(nil),              // 1: log.LogBatch
 	(*Response)(nil),              // 2: log.Response
-	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+	nil,                           // 3: log.LogEntry.AttributesEntry
+	(*timestamppb.Timestamp)(nil), // 4: google.protobuf.Timestamp
 }
 var file_internal_sender_log_proto_depIdxs = []int32{
-	3, // 0: log.LogEntry.timestamp:type_name -> google.protobuf.Timestamp
-	0, // 1: log.LogBatch.entries:type_name -> log.LogEntry
-	1, // 2: log.LogService.SendLogs:input_type -> log.LogBatch
-	2, // 3: log.LogService.SendLogs:output_type -> log.Response
-	3, // [3:4] is the sub-list for method output_type
-	2, // [2:3] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	4, // 0: log.LogEntry.timestamp:type_name -> google.protobuf.Timestamp
+	3, // 1: log.LogEntry.attributes:type_name -> log.LogEntry.AttributesEntry
+	0, // 2: log.LogBatch.entries:type_name -> log.LogEntry
+	1, // 3: log.LogService.SendLogs:input_type -> log.LogBatch
+	2, // 4: log.LogService.SendLogs:output_type -> log.Response
+	4, // [4:5] is the sub-list for method output_type
+	3, // [3:4] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_internal_sender_log_proto_init() }
@@ -357,7 +566,7 @@ func file_internal_sender_log_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_sender_log_proto_rawDesc), len(file_internal_sender_log_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -23,22 +23,44 @@ func (s *GrpcServer) SendLogs(ctx context.Context, req *pb.LogBatch) (*pb.Respon
 	logs := make([]telemetry.LogEntry, 0, len(req.Entries))
 	for _, entry := range req.Entries {
 		logs = append(logs, telemetry.LogEntry{
-			Timestamp:   entry.Timestamp.AsTime(),
-			Pid:         entry.Pid,
-			Tid:         entry.Tid,
-			Fd:          entry.Fd,
-			CgroupID:    entry.CgroupId,
-			Type:        entry.Type,
-			Payload:     entry.Payload,
-			DurationNs:  entry.DurationNs,
-			Status:      entry.Status,
-			Method:      entry.Method,
-			Path:        entry.Path,
-			Node:        entry.Node,
-			Namespace:   entry.Namespace,
-			Pod:         entry.Pod,
-			Container:   entry.Container,
-			ContainerID: entry.ContainerId,
+			Timestamp:       entry.Timestamp.AsTime(),
+			Pid:             entry.Pid,
+			Tid:             entry.Tid,
+			Fd:              entry.Fd,
+			CgroupID:        entry.CgroupId,
+			Type:            entry.Type,
+			Role:            entry.Role,
+			Payload:         entry.Payload,
+			DurationNs:      entry.DurationNs,
+			Status:          entry.Status,
+			Method:          entry.Method,
+			Path:            entry.Path,
+			Node:            entry.Node,
+			Namespace:       entry.Namespace,
+			Pod:             entry.Pod,
+			Container:       entry.Container,
+			ContainerID:     entry.ContainerId,
+			Family:          entry.Family,
+			LocalIP:         entry.LocalIp,
+			LocalPort:       uint16(entry.LocalPort),
+			RemoteIP:        entry.RemoteIp,
+			RemotePort:      uint16(entry.RemotePort),
+			Error:           entry.Error,
+			ErrorCode:       entry.ErrorCode,
+			Rows:            entry.Rows,
+			Keys:            entry.Keys,
+			HitRatio:        entry.HitRatio,
+			TraceID:         entry.TraceId,
+			SpanID:          entry.SpanId,
+			TraceSampled:    entry.TraceSampled,
+			Route:           entry.Route,
+			Query:           entry.Query,
+			Host:            entry.Host,
+			UserAgent:       entry.UserAgent,
+			ContentType:     entry.ContentType,
+			ContentLength:   entry.ContentLength,
+			Attributes:      entry.Attributes,
+			ResponsePayload: entry.ResponsePayload,
 		})
 	}
 
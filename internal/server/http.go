@@ -3,6 +3,7 @@ package server
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -23,6 +24,8 @@ func (s *HttpServer) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", s.handleHealth)
 	mux.HandleFunc("/api/logs", s.handleLogs)
+	mux.HandleFunc("/api/error_codes", s.handleErrorCodes)
+	mux.HandleFunc("/api/routes", s.handleRoutes)
 	mux.Handle("/", http.FileServer(http.FS(web.FS)))
 	return mux
 }
@@ -33,6 +36,57 @@ func (s *HttpServer) handleHealth(w http.ResponseWriter, _ *http.Request) {
 }
 
 func (s *HttpServer) handleLogs(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.db.QueryLogs(r.Context(), parseFilter(r))
+	if err != nil {
+		http.Error(w, "query failed", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Entries any `json:"entries"`
+	}{
+		Entries: entries,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (s *HttpServer) handleErrorCodes(w http.ResponseWriter, r *http.Request) {
+	counts, err := s.db.CountErrorCodes(r.Context(), parseFilter(r))
+	if err != nil {
+		http.Error(w, "query failed", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Counts any `json:"counts"`
+	}{
+		Counts: counts,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func (s *HttpServer) handleRoutes(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.db.RouteStats(r.Context(), parseFilter(r))
+	if err != nil {
+		http.Error(w, "query failed", http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		Routes any `json:"routes"`
+	}{
+		Routes: stats,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func parseFilter(r *http.Request) storage.QueryFilter {
 	query := r.URL.Query()
 
 	now := time.Now()
@@ -56,32 +110,45 @@ func (s *HttpServer) handleLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	filter := storage.QueryFilter{
-		From:      from,
-		To:        to,
-		Limit:     limit,
-		Offset:    offset,
-		Method:    strings.ToUpper(query.Get("method")),
-		Status:    status,
-		Namespace: query.Get("namespace"),
-		Pod:       query.Get("pod"),
-		Path:      query.Get("path"),
-	}
-
-	entries, err := s.db.QueryLogs(r.Context(), filter)
-	if err != nil {
-		http.Error(w, "query failed", http.StatusInternalServerError)
-		return
+	return storage.QueryFilter{
+		From:       from,
+		To:         to,
+		Limit:      limit,
+		Offset:     offset,
+		Method:     strings.ToUpper(query.Get("method")),
+		Status:     status,
+		Namespace:  query.Get("namespace"),
+		Pod:        query.Get("pod"),
+		Path:       query.Get("path"),
+		Family:     query.Get("family"),
+		LocalIP:    query.Get("local_ip"),
+		LocalPort:  parsePort(query.Get("local_port")),
+		RemoteIP:   query.Get("remote_ip"),
+		RemotePort: parsePort(query.Get("remote_port")),
+		Role:       strings.ToLower(query.Get("role")),
+		Type:       strings.ToLower(query.Get("type")),
+		Error:      parseBool(query.Get("error")),
+		ErrorCode:  strings.ToUpper(query.Get("error_code")),
+		TraceID:    strings.ToLower(query.Get("trace_id")),
+		Route:      query.Get("route"),
+		Headers:    parseHeaderFilters(query),
 	}
+}
 
-	response := struct {
-		Entries any `json:"entries"`
-	}{
-		Entries: entries,
+// parseHeaderFilters reads header.<name>=value parameters.
+func parseHeaderFilters(query url.Values) map[string]string {
+	var headers map[string]string
+	for key, values := range query {
+		name, ok := strings.CutPrefix(key, "header.")
+		if !ok || name == "" || len(values) == 0 {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[strings.ToLower(name)] = values[0]
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(response)
+	return headers
 }
 
 func parseTime(value string, fallback time.Time) time.Time {
@@ -100,6 +167,29 @@ func parseTime(value string, fallback time.Time) time.Time {
 	return fallback
 }
 
+func parsePort(value string) *uint16 {
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return nil
+	}
+	port := uint16(parsed)
+	return &port
+}
+
+func parseBool(value string) *bool {
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
+
 func parseInt(value string, fallback int) int {
 	if value == "" {
 		return fallback
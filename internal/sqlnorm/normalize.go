@@ -0,0 +1,194 @@
+package sqlnorm
+
+import "strings"
+
+// Dialect selects the quoting rules of a database.
+type Dialect int
+
+const (
+	// Postgres quotes identifiers with double quotes and has $1 parameters
+	// and $tag$ strings.
+	Postgres Dialect = iota
+	// MySQL quotes identifiers with backticks, treats double-quoted text as
+	// a string, allows backslash escapes and # comments.
+	MySQL
+)
+
+// Normalize replaces literals in a statement with "?" and folds comments and
+// runs of whitespace into single spaces, so that executions of the same
+// statement with different values look alike. Bind parameters and quoted
+// identifiers are kept.
+func Normalize(query string, dialect Dialect) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	space := false
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+			i++
+			continue
+		case c == '-' && strings.HasPrefix(query[i:], "--"), c == '#' && dialect == MySQL:
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			space = true
+			i += end
+			continue
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+			space = true
+			continue
+		}
+
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+
+		switch {
+		case c == '\'' || (c == '"' && dialect == MySQL):
+			prefix := trimStringPrefix(&b)
+			i = skipQuoted(query, i, c, dialect == MySQL || prefix == 'e')
+			b.WriteByte('?')
+		case c == '"' || (c == '`' && dialect == MySQL):
+			end := skipQuoted(query, i, c, false)
+			b.WriteString(query[i:end])
+			i = end
+		case c == '$' && dialect == Postgres && i+1 < len(query) && isDigit(query[i+1]):
+			end := i + 1
+			for end < len(query) && isDigit(query[end]) {
+				end++
+			}
+			b.WriteString(query[i:end])
+			i = end
+		case c == '$' && dialect == Postgres:
+			end, ok := skipDollarQuoted(query, i)
+			if !ok {
+				b.WriteByte(c)
+				i++
+				continue
+			}
+			b.WriteByte('?')
+			i = end
+		case isDigit(c) && !followsWord(&b):
+			i = skipNumber(query, i)
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// Command returns the leading keyword of a statement.
+func Command(query string) string {
+	end := 0
+	for end < len(query) && isWordByte(query[end]) {
+		end++
+	}
+	return strings.ToUpper(query[:end])
+}
+
+// skipQuoted returns the offset after the quoted section starting at i,
+// where a doubled quote is an escaped one.
+func skipQuoted(query string, i int, quote byte, backslash bool) int {
+	for i++; i < len(query); i++ {
+		if backslash && query[i] == '\\' {
+			i++
+			continue
+		}
+		if query[i] != quote {
+			continue
+		}
+		if i+1 < len(query) && query[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(query)
+}
+
+// skipDollarQuoted returns the offset after a $tag$...$tag$ string starting
+// at i.
+func skipDollarQuoted(query string, i int) (int, bool) {
+	end := i + 1
+	for end < len(query) && isWordByte(query[end]) {
+		end++
+	}
+	if end >= len(query) || query[end] != '$' {
+		return 0, false
+	}
+	tag := query[i : end+1]
+	closing := strings.Index(query[end+1:], tag)
+	if closing < 0 {
+		return len(query), true
+	}
+	return end + 1 + closing + len(tag), true
+}
+
+func skipNumber(query string, i int) int {
+	for i < len(query) {
+		c := query[i]
+		switch {
+		case isDigit(c) || c == '.':
+			i++
+		case (c == 'e' || c == 'E') && i+1 < len(query):
+			next := query[i+1]
+			if (next == '+' || next == '-') && i+2 < len(query) && isDigit(query[i+2]) {
+				i += 3
+			} else if isDigit(next) {
+				i += 2
+			} else {
+				return i
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// trimStringPrefix drops the E, B, X or N that introduces an escape, bit,
+// hex or national string constant and returns it in lower case.
+func trimStringPrefix(b *strings.Builder) byte {
+	s := b.String()
+	if len(s) == 0 {
+		return 0
+	}
+	prefix := s[len(s)-1] | 0x20
+	switch prefix {
+	case 'e', 'b', 'x', 'n':
+	default:
+		return 0
+	}
+	if len(s) > 1 && isWordByte(s[len(s)-2]) {
+		return 0
+	}
+	kept := s[:len(s)-1]
+	b.Reset()
+	b.WriteString(kept)
+	return prefix
+}
+
+func followsWord(b *strings.Builder) bool {
+	s := b.String()
+	return len(s) > 0 && (isWordByte(s[len(s)-1]) || s[len(s)-1] == '.')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z') || c >= 0x80
+}
@@ -0,0 +1,38 @@
+package sqlnorm
+
+import "testing"
+
+func TestNormalizePostgres(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM users WHERE id = 42 AND name = 'o''brien'":             "SELECT * FROM users WHERE id = ? AND name = ?",
+		"select  a1, \"Col 2\"\n from t -- trailing\n where x in (1.5, 2e-3)": "select a1, \"Col 2\" from t where x in (?, ?)",
+		"INSERT INTO t VALUES ($1, E'\\n', $$body$$) /* c */":                 "INSERT INTO t VALUES ($1, ?, ?)",
+		"SELECT $tag$it's$tag$, x.y2 FROM s.t2":                               "SELECT ?, x.y2 FROM s.t2",
+	}
+	for in, want := range cases {
+		if got := Normalize(in, Postgres); got != want {
+			t.Fatalf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeMySQL(t *testing.T) {
+	cases := map[string]string{
+		"SELECT * FROM `order` WHERE note = \"it\\\"s\" AND id IN (?, 7) # done": "SELECT * FROM `order` WHERE note = ? AND id IN (?, ?)",
+		"UPDATE t SET name = 'a\\'b', n = n + 1 WHERE k = x'ff'":                 "UPDATE t SET name = ?, n = n + ? WHERE k = ?",
+	}
+	for in, want := range cases {
+		if got := Normalize(in, MySQL); got != want {
+			t.Fatalf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	if got := Command("select 1"); got != "SELECT" {
+		t.Fatalf("Command = %q", got)
+	}
+	if got := Command("(SELECT 1)"); got != "" {
+		t.Fatalf("Command = %q", got)
+	}
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -65,7 +66,30 @@ func (db *DB) Migrate() error {
 		namespace String,
 		pod String,
 		container String,
-		container_id String
+		container_id String,
+		family String,
+		local_ip String,
+		local_port UInt16,
+		remote_ip String,
+		remote_port UInt16,
+		role String,
+		error Bool,
+		error_code String,
+		rows UInt64,
+		keys UInt32,
+		hit_ratio Float64,
+		trace_id String,
+		span_id String,
+		trace_sampled Bool,
+		route String,
+		query String,
+		host String,
+		user_agent String,
+		content_type String,
+		content_length UInt64,
+		attributes Map(String, String),
+		response_payload String,
+		INDEX idx_trace_id trace_id TYPE bloom_filter(0.01) GRANULARITY 4
 	) ENGINE = MergeTree()
 	PARTITION BY toDate(timestamp)
 	ORDER BY (timestamp, pid, fd)
@@ -84,6 +108,28 @@ func (db *DB) Migrate() error {
 		"pod String",
 		"container String",
 		"container_id String",
+		"family String",
+		"local_ip String",
+		"local_port UInt16",
+		"remote_ip String",
+		"remote_port UInt16",
+		"role String",
+		"error Bool",
+		"error_code String",
+		"rows UInt64",
+		"keys UInt32",
+		"hit_ratio Float64",
+		"trace_id String",
+		"span_id String",
+		"trace_sampled Bool",
+		"route String",
+		"query String",
+		"host String",
+		"user_agent String",
+		"content_type String",
+		"content_length UInt64",
+		"attributes Map(String, String)",
+		"response_payload String",
 	}
 	for _, col := range columns {
 		stmt := fmt.Sprintf("ALTER TABLE http_logs ADD COLUMN IF NOT EXISTS %s", col)
@@ -92,6 +138,11 @@ func (db *DB) Migrate() error {
 		}
 	}
 
+	index := "ALTER TABLE http_logs ADD INDEX IF NOT EXISTS idx_trace_id trace_id TYPE bloom_filter(0.01) GRANULARITY 4"
+	if err := db.conn.Exec(context.Background(), index); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -105,7 +156,11 @@ func (db *DB) InsertBatch(logs []telemetry.LogEntry) error {
 	batch, err := db.conn.PrepareBatch(ctx, `
 		INSERT INTO http_logs (
 			timestamp, pid, tid, fd, cgroup_id, type, status, method, path,
-			payload, duration_ns, node, namespace, pod, container, container_id
+			payload, duration_ns, node, namespace, pod, container, container_id,
+			family, local_ip, local_port, remote_ip, remote_port, role, error,
+			error_code, rows, keys, hit_ratio, trace_id, span_id, trace_sampled,
+			route, query, host, user_agent, content_type, content_length,
+			attributes, response_payload
 		)`)
 	if err != nil {
 		return err
@@ -129,6 +184,28 @@ func (db *DB) InsertBatch(logs []telemetry.LogEntry) error {
 			log.Pod,
 			log.Container,
 			log.ContainerID,
+			log.Family,
+			log.LocalIP,
+			log.LocalPort,
+			log.RemoteIP,
+			log.RemotePort,
+			log.Role,
+			log.Error,
+			log.ErrorCode,
+			log.Rows,
+			log.Keys,
+			log.HitRatio,
+			log.TraceID,
+			log.SpanID,
+			log.TraceSampled,
+			log.Route,
+			log.Query,
+			log.Host,
+			log.UserAgent,
+			log.ContentType,
+			log.ContentLength,
+			log.Attributes,
+			log.ResponsePayload,
 		)
 		if err != nil {
 			return err
@@ -139,18 +216,41 @@ func (db *DB) InsertBatch(logs []telemetry.LogEntry) error {
 }
 
 type QueryFilter struct {
-	From      time.Time
-	To        time.Time
-	Limit     int
-	Offset    int
-	Method    string
-	Status    *uint32
-	Namespace string
-	Pod       string
-	Path      string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+	Method     string
+	Status     *uint32
+	Namespace  string
+	Pod        string
+	Path       string
+	Family     string
+	LocalIP    string
+	LocalPort  *uint16
+	RemoteIP   string
+	RemotePort *uint16
+	Role       string
+	Type       string
+	Error      *bool
+	ErrorCode  string
+	TraceID    string
+	Route      string
+	// Headers filters on captured headers by lowercased name.
+	Headers map[string]string
 }
 
-func (db *DB) QueryLogs(ctx context.Context, f QueryFilter) ([]telemetry.LogEntry, error) {
+// headerColumns are the headers stored in their own column rather than in
+// attributes.
+var headerColumns = map[string]string{
+	"host":           "host",
+	"user-agent":     "user_agent",
+	"content-type":   "content_type",
+	"content-length": "toString(content_length)",
+}
+
+// where builds the WHERE clause shared by the queries over http_logs.
+func (f QueryFilter) where() (string, []any) {
 	conditions := []string{"timestamp >= ?", "timestamp <= ?"}
 	args := []any{f.From, f.To}
 
@@ -174,13 +274,80 @@ func (db *DB) QueryLogs(ctx context.Context, f QueryFilter) ([]telemetry.LogEntr
 		conditions = append(conditions, "path LIKE ?")
 		args = append(args, "%"+f.Path+"%")
 	}
+	if f.Family != "" {
+		conditions = append(conditions, "family = ?")
+		args = append(args, f.Family)
+	}
+	if f.LocalIP != "" {
+		conditions = append(conditions, "local_ip = ?")
+		args = append(args, f.LocalIP)
+	}
+	if f.LocalPort != nil {
+		conditions = append(conditions, "local_port = ?")
+		args = append(args, *f.LocalPort)
+	}
+	if f.RemoteIP != "" {
+		conditions = append(conditions, "remote_ip = ?")
+		args = append(args, f.RemoteIP)
+	}
+	if f.RemotePort != nil {
+		conditions = append(conditions, "remote_port = ?")
+		args = append(args, *f.RemotePort)
+	}
+	if f.Role != "" {
+		conditions = append(conditions, "role = ?")
+		args = append(args, f.Role)
+	}
+	if f.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, f.Type)
+	}
+	if f.Error != nil {
+		conditions = append(conditions, "error = ?")
+		args = append(args, *f.Error)
+	}
+	if f.ErrorCode != "" {
+		conditions = append(conditions, "error_code = ?")
+		args = append(args, f.ErrorCode)
+	}
+	if f.TraceID != "" {
+		conditions = append(conditions, "trace_id = ?")
+		args = append(args, f.TraceID)
+	}
+	if f.Route != "" {
+		conditions = append(conditions, "route = ?")
+		args = append(args, f.Route)
+	}
+	names := make([]string, 0, len(f.Headers))
+	for name := range f.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if column, ok := headerColumns[name]; ok {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, f.Headers[name])
+		} else {
+			conditions = append(conditions, "attributes[?] = ?")
+			args = append(args, name, f.Headers[name])
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
 
+func (db *DB) QueryLogs(ctx context.Context, f QueryFilter) ([]telemetry.LogEntry, error) {
+	where, args := f.where()
 	query := `
 		SELECT
 			timestamp, pid, tid, fd, cgroup_id, type, status, method, path,
-			payload, duration_ns, node, namespace, pod, container, container_id
+			payload, duration_ns, node, namespace, pod, container, container_id,
+			family, local_ip, local_port, remote_ip, remote_port, role, error,
+			error_code, rows, keys, hit_ratio, trace_id, span_id, trace_sampled,
+			route, query, host, user_agent, content_type, content_length,
+			attributes, response_payload
 		FROM http_logs
-		WHERE ` + strings.Join(conditions, " AND ") + `
+		WHERE ` + where + `
 		ORDER BY timestamp DESC
 		LIMIT ? OFFSET ?`
 
@@ -212,6 +379,28 @@ func (db *DB) QueryLogs(ctx context.Context, f QueryFilter) ([]telemetry.LogEntr
 			&entry.Pod,
 			&entry.Container,
 			&entry.ContainerID,
+			&entry.Family,
+			&entry.LocalIP,
+			&entry.LocalPort,
+			&entry.RemoteIP,
+			&entry.RemotePort,
+			&entry.Role,
+			&entry.Error,
+			&entry.ErrorCode,
+			&entry.Rows,
+			&entry.Keys,
+			&entry.HitRatio,
+			&entry.TraceID,
+			&entry.SpanID,
+			&entry.TraceSampled,
+			&entry.Route,
+			&entry.Query,
+			&entry.Host,
+			&entry.UserAgent,
+			&entry.ContentType,
+			&entry.ContentLength,
+			&entry.Attributes,
+			&entry.ResponsePayload,
 		); err != nil {
 			return nil, err
 		}
@@ -220,3 +409,83 @@ func (db *DB) QueryLogs(ctx context.Context, f QueryFilter) ([]telemetry.LogEntr
 
 	return entries, rows.Err()
 }
+
+type ErrorCodeCount struct {
+	Type      string `json:"type"`
+	ErrorCode string `json:"error_code"`
+	Count     uint64 `json:"count"`
+}
+
+// CountErrorCodes counts the entries matching f per protocol and error code,
+// such as NXDOMAIN and SERVFAIL answers for DNS. Limit and Offset are
+// ignored.
+func (db *DB) CountErrorCodes(ctx context.Context, f QueryFilter) ([]ErrorCodeCount, error) {
+	where, args := f.where()
+	query := `
+		SELECT type, error_code, count()
+		FROM http_logs
+		WHERE ` + where + ` AND error_code != ''
+		GROUP BY type, error_code
+		ORDER BY count() DESC`
+
+	rows, err := db.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []ErrorCodeCount
+	for rows.Next() {
+		var c ErrorCodeCount
+		if err := rows.Scan(&c.Type, &c.ErrorCode, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+type RouteStats struct {
+	Type   string  `json:"type"`
+	Method string  `json:"method"`
+	Route  string  `json:"route"`
+	Count  uint64  `json:"count"`
+	Errors uint64  `json:"errors"`
+	P50Ns  float64 `json:"p50_ns"`
+	P95Ns  float64 `json:"p95_ns"`
+}
+
+// RouteStats groups the entries matching f by method and route template,
+// busiest first. Entries without a route, which are the non-HTTP protocols,
+// are left out; Offset is ignored.
+func (db *DB) RouteStats(ctx context.Context, f QueryFilter) ([]RouteStats, error) {
+	where, args := f.where()
+	query := `
+		SELECT
+			type, method, route, count(),
+			countIf(error OR status >= 500),
+			quantile(0.5)(duration_ns), quantile(0.95)(duration_ns)
+		FROM http_logs
+		WHERE ` + where + ` AND route != ''
+		GROUP BY type, method, route
+		ORDER BY count() DESC
+		LIMIT ?`
+
+	args = append(args, f.Limit)
+
+	rows, err := db.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []RouteStats
+	for rows.Next() {
+		var s RouteStats
+		if err := rows.Scan(&s.Type, &s.Method, &s.Route, &s.Count, &s.Errors, &s.P50Ns, &s.P95Ns); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
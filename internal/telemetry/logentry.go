@@ -3,20 +3,42 @@ package telemetry
 import "time"
 
 type LogEntry struct {
-	Timestamp   time.Time `json:"timestamp"`
-	Pid         uint32    `json:"pid"`
-	Tid         uint32    `json:"tid"`
-	Fd          int32     `json:"fd"`
-	CgroupID    uint64    `json:"cgroup_id"`
-	Type        string    `json:"type"`
-	Status      uint32    `json:"status"`
-	Method      string    `json:"method"`
-	Path        string    `json:"path"`
-	Payload     string    `json:"payload"`
-	DurationNs  uint64    `json:"duration_ns"`
-	Node        string    `json:"node"`
-	Namespace   string    `json:"namespace"`
-	Pod         string    `json:"pod"`
-	Container   string    `json:"container"`
-	ContainerID string    `json:"container_id"`
+	Timestamp       time.Time         `json:"timestamp"`
+	Pid             uint32            `json:"pid"`
+	Tid             uint32            `json:"tid"`
+	Fd              int32             `json:"fd"`
+	CgroupID        uint64            `json:"cgroup_id"`
+	Type            string            `json:"type"`
+	Role            string            `json:"role"`
+	Status          uint32            `json:"status"`
+	Method          string            `json:"method"`
+	Path            string            `json:"path"`
+	Payload         string            `json:"payload"`
+	DurationNs      uint64            `json:"duration_ns"`
+	Node            string            `json:"node"`
+	Namespace       string            `json:"namespace"`
+	Pod             string            `json:"pod"`
+	Container       string            `json:"container"`
+	ContainerID     string            `json:"container_id"`
+	Family          string            `json:"family"`
+	LocalIP         string            `json:"local_ip"`
+	LocalPort       uint16            `json:"local_port"`
+	RemoteIP        string            `json:"remote_ip"`
+	RemotePort      uint16            `json:"remote_port"`
+	Error           bool              `json:"error"`
+	ErrorCode       string            `json:"error_code"`
+	Rows            uint64            `json:"rows"`
+	Keys            uint32            `json:"keys"`
+	HitRatio        float64           `json:"hit_ratio"`
+	TraceID         string            `json:"trace_id"`
+	SpanID          string            `json:"span_id"`
+	TraceSampled    bool              `json:"trace_sampled"`
+	Route           string            `json:"route"`
+	Query           string            `json:"query"`
+	Host            string            `json:"host"`
+	UserAgent       string            `json:"user_agent"`
+	ContentType     string            `json:"content_type"`
+	ContentLength   uint64            `json:"content_length"`
+	Attributes      map[string]string `json:"attributes"`
+	ResponsePayload string            `json:"response_payload"`
 }
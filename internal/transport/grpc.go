@@ -25,22 +25,44 @@ func (s *GRPCSender) Send(ctx context.Context, batch []telemetry.LogEntry) error
 	entries := make([]*pb.LogEntry, 0, len(batch))
 	for _, entry := range batch {
 		entries = append(entries, &pb.LogEntry{
-			Timestamp:   timestamppb.New(entry.Timestamp),
-			Pid:         entry.Pid,
-			Tid:         entry.Tid,
-			Fd:          entry.Fd,
-			CgroupId:    entry.CgroupID,
-			Type:        entry.Type,
-			Payload:     entry.Payload,
-			DurationNs:  entry.DurationNs,
-			Status:      entry.Status,
-			Method:      entry.Method,
-			Path:        entry.Path,
-			Node:        entry.Node,
-			Namespace:   entry.Namespace,
-			Pod:         entry.Pod,
-			Container:   entry.Container,
-			ContainerId: entry.ContainerID,
+			Timestamp:       timestamppb.New(entry.Timestamp),
+			Pid:             entry.Pid,
+			Tid:             entry.Tid,
+			Fd:              entry.Fd,
+			CgroupId:        entry.CgroupID,
+			Type:            entry.Type,
+			Role:            entry.Role,
+			Payload:         entry.Payload,
+			DurationNs:      entry.DurationNs,
+			Status:          entry.Status,
+			Method:          entry.Method,
+			Path:            entry.Path,
+			Node:            entry.Node,
+			Namespace:       entry.Namespace,
+			Pod:             entry.Pod,
+			Container:       entry.Container,
+			ContainerId:     entry.ContainerID,
+			Family:          entry.Family,
+			LocalIp:         entry.LocalIP,
+			LocalPort:       uint32(entry.LocalPort),
+			RemoteIp:        entry.RemoteIP,
+			RemotePort:      uint32(entry.RemotePort),
+			Error:           entry.Error,
+			ErrorCode:       entry.ErrorCode,
+			Rows:            entry.Rows,
+			Keys:            entry.Keys,
+			HitRatio:        entry.HitRatio,
+			TraceId:         entry.TraceID,
+			SpanId:          entry.SpanID,
+			TraceSampled:    entry.TraceSampled,
+			Route:           entry.Route,
+			Query:           entry.Query,
+			Host:            entry.Host,
+			UserAgent:       entry.UserAgent,
+			ContentType:     entry.ContentType,
+			ContentLength:   entry.ContentLength,
+			Attributes:      entry.Attributes,
+			ResponsePayload: entry.ResponsePayload,
 		})
 	}
 
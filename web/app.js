@@ -152,7 +152,7 @@ function renderStats(entries) {
   const p95 = latenciesMs[p95Index] || 0;
   statP95.textContent = `${p95.toFixed(1)} ms`;
 
-  const errors = entries.filter((entry) => Number(entry.status) >= 400).length;
+  const errors = entries.filter((entry) => entry.error || Number(entry.status) >= 400).length;
   const errorRate = (errors / entries.length) * 100;
   statError.textContent = `${errorRate.toFixed(1)}%`;
 }
@@ -192,8 +192,8 @@ function renderTable(entries) {
 
     const statusCell = document.createElement("td");
     const badge = document.createElement("span");
-    badge.className = `badge ${statusBadgeClass(entry.status)}`;
-    badge.textContent = entry.status ? String(entry.status) : "-";
+    badge.className = `badge ${entry.error ? "err" : statusBadgeClass(entry.status)}`;
+    badge.textContent = entry.status ? String(entry.status) : entry.error ? "ERR" : "-";
     statusCell.appendChild(badge);
 
     const durationCell = document.createElement("td");